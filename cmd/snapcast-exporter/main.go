@@ -0,0 +1,71 @@
+// Command snapcast-exporter serves the state of a snapserver as Prometheus metrics on /metrics
+//
+//	snapcast-exporter --host snapserver:1780 --listen :9731
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/internal/runloop"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapmetrics"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	var (
+		host   = flag.String("host", "localhost:1780", "snapserver host:port of the HTTP control API")
+		tls    = flag.Bool("tls", false, "use https and wss")
+		listen = flag.String("listen", ":9731", "address to serve metrics on")
+		path   = flag.String("path", "/metrics", "path to serve metrics on")
+	)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		collector = snapmetrics.NewCollector()
+		client    = snapclient.New(&snapclient.Options{
+			Host:             *host,
+			SecureConnection: *tls,
+			Reconnect:        &snapclient.ReconnectPolicy{Jitter: 0.2},
+			OnResponse:       collector.ObserveRequest,
+		})
+		state    = snapstate.New(client)
+		registry = prometheus.NewRegistry()
+	)
+	defer client.Close()
+	collector.Watch(client, state)
+	registry.MustRegister(
+		collector,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	var mux = http.NewServeMux()
+	mux.Handle(*path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	var srv = &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
+
+	runloop.Run(ctx, state.Run)
+}
@@ -0,0 +1,122 @@
+// Command snapcast-mqtt bridges a snapserver to an MQTT broker, with Home Assistant discovery.
+// See package snapmqtt for the topics
+//
+//	snapcast-mqtt --host snapserver:1780 --broker tcp://mosquitto:1883
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/internal/runloop"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapmqtt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// timeout for a publish or subscribe to be acknowledged
+const timeout = 10 * time.Second
+
+// pahoBroker adapts a paho client to snapmqtt.Broker. Subscriptions are made again after a
+// reconnect, paho only keeps them with persistent sessions
+type pahoBroker struct {
+	client mqtt.Client
+
+	mu   sync.Mutex
+	subs map[string]mqtt.MessageHandler
+}
+
+func wait(t mqtt.Token) error {
+	if !t.WaitTimeout(timeout) {
+		return errors.New("mqtt: timed out")
+	}
+	return t.Error()
+}
+
+func (b *pahoBroker) Publish(topic string, payload []byte, retained bool) error {
+	return wait(b.client.Publish(topic, 1, retained, payload))
+}
+
+func (b *pahoBroker) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	var h = func(_ mqtt.Client, msg mqtt.Message) {
+		handler(msg.Topic(), msg.Payload())
+	}
+	b.mu.Lock()
+	b.subs[filter] = h
+	b.mu.Unlock()
+	return wait(b.client.Subscribe(filter, 1, h))
+}
+
+func (b *pahoBroker) resubscribe(c mqtt.Client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for filter, h := range b.subs {
+		if err := wait(c.Subscribe(filter, 1, h)); err != nil {
+			log.Printf("mqtt: subscribe %s: %v", filter, err)
+		}
+	}
+}
+
+func main() {
+	var (
+		host      = flag.String("host", "localhost:1780", "snapserver host:port of the HTTP control API")
+		tls       = flag.Bool("tls", false, "use https and wss")
+		broker    = flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
+		clientID  = flag.String("client-id", "snapcast-mqtt", "MQTT client id")
+		username  = flag.String("username", "", "MQTT username")
+		password  = flag.String("password", os.Getenv("MQTT_PASSWORD"), "MQTT password, defaults to $MQTT_PASSWORD")
+		prefix    = flag.String("prefix", snapmqtt.DefaultPrefix, "topic prefix")
+		discovery = flag.String("discovery-prefix", snapmqtt.DefaultDiscoveryPrefix, "Home Assistant discovery prefix")
+		noDisc    = flag.Bool("no-discovery", false, "don't publish Home Assistant discovery configs")
+	)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var client = snapclient.New(&snapclient.Options{
+		Host:             *host,
+		SecureConnection: *tls,
+		Reconnect:        &snapclient.ReconnectPolicy{Jitter: 0.2},
+	})
+	defer client.Close()
+
+	var (
+		pb     = &pahoBroker{subs: make(map[string]mqtt.MessageHandler)}
+		bridge = snapmqtt.New(client, pb, &snapmqtt.Options{
+			Prefix:           *prefix,
+			DiscoveryPrefix:  *discovery,
+			DisableDiscovery: *noDisc,
+			OnError:          func(err error) { log.Println(err) },
+		})
+	)
+
+	var opts = mqtt.NewClientOptions().
+		AddBroker(*broker).
+		SetClientID(*clientID).
+		SetUsername(*username).
+		SetPassword(*password).
+		SetWill(bridge.StatusTopic(), snapmqtt.Offline, 1, true).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			pb.resubscribe(c)
+			bridge.PublishStatus()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Println("mqtt: connection lost:", err)
+		})
+	pb.client = mqtt.NewClient(opts)
+	if err := wait(pb.client.Connect()); err != nil {
+		log.Fatalln("mqtt:", err)
+	}
+	defer pb.client.Disconnect(250)
+
+	runloop.Run(ctx, bridge.Run)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapmqtt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Runs against a real broker, e.g. MQTT_TEST_BROKER=tcp://localhost:1883 with a local mosquitto
+func TestPahoBroker(t *testing.T) {
+	var url = os.Getenv("MQTT_TEST_BROKER")
+	if url == "" {
+		t.Skip("MQTT_TEST_BROKER not set")
+	}
+
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var pb = &pahoBroker{subs: make(map[string]mqtt.MessageHandler)}
+	pb.client = mqtt.NewClient(mqtt.NewClientOptions().AddBroker(url).SetClientID("snapcast-mqtt-test"))
+	if err := wait(pb.client.Connect()); err != nil {
+		t.Fatal(err)
+	}
+	defer pb.client.Disconnect(250)
+
+	var (
+		client = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		prefix = "snapcast-test-" + time.Now().Format("150405.000")
+		bridge = snapmqtt.New(client, pb, &snapmqtt.Options{Prefix: prefix, DisableDiscovery: true})
+		states = make(chan snapmqtt.ClientState, 16)
+	)
+	defer client.Close()
+	go bridge.Run(ctx)
+
+	if err := pb.Subscribe(prefix+"/client/+", func(_ string, payload []byte) {
+		var s snapmqtt.ClientState
+		if json.Unmarshal(payload, &s) == nil {
+			states <- s
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pb.Publish(prefix+"/client/00:21:6a:7d:74:fc/volume/set", []byte("21"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		select {
+		case s := <-states:
+			if s.ID == "00:21:6a:7d:74:fc" && s.Volume == 21 {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("volume change never published")
+		}
+	}
+}
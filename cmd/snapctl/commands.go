@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// ok is printed for commands whose response has nothing in it
+const ok = "ok"
+
+// checkArgs checks the number of arguments of a subcommand, a max of -1 allows any number
+func checkArgs(cmd string, args []string, min, max int, usage string) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return usagef("usage: %s %s", cmd, usage)
+	}
+	return nil
+}
+
+func parseBool(arg string) (bool, error) {
+	v, err := strconv.ParseBool(arg)
+	if err != nil {
+		return false, usagef("%q is not true or false", arg)
+	}
+	return v, nil
+}
+
+func parseInt(arg string) (int, error) {
+	v, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, usagef("%q is not a number", arg)
+	}
+	return v, nil
+}
+
+func parseSeconds(arg string) (time.Duration, error) {
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, usagef("%q is not a number of seconds", arg)
+	}
+	return time.Duration(v * float64(time.Second)), nil
+}
+
+// optionalBool is the bool at args[i], true if it's missing
+func optionalBool(args []string, i int) (bool, error) {
+	if len(args) <= i {
+		return true, nil
+	}
+	return parseBool(args[i])
+}
+
+func clientCommand(ctx context.Context, c *snapclient.Client, out *printer, args []string) error {
+	if len(args) == 0 {
+		return usagef("usage: client volume|mute|latency|name|delete <id> ...")
+	}
+	var sub, rest = args[0], args[1:]
+
+	switch sub {
+	case "volume":
+		if err := checkArgs("client volume", rest, 2, 2, "<id> <percent>"); err != nil {
+			return err
+		}
+		percent, err := parseInt(rest[1])
+		if err != nil {
+			return err
+		}
+		if percent < 0 || percent > 100 {
+			return usagef("volume %d is not between 0 and 100", percent)
+		}
+		// Keep the mute state, snapserver sets both at once
+		status, err := c.ClientGetStatus(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		var volume = status.Client.Config.Volume
+		volume.Percent = percent
+		res, err := c.ClientSetVolume(ctx, rest[0], volume)
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "mute":
+		if err := checkArgs("client mute", rest, 1, 2, "<id> [true|false]"); err != nil {
+			return err
+		}
+		muted, err := optionalBool(rest, 1)
+		if err != nil {
+			return err
+		}
+		status, err := c.ClientGetStatus(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		var volume = status.Client.Config.Volume
+		volume.Muted = muted
+		res, err := c.ClientSetVolume(ctx, rest[0], volume)
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "latency":
+		if err := checkArgs("client latency", rest, 2, 2, "<id> <ms>"); err != nil {
+			return err
+		}
+		latency, err := parseInt(rest[1])
+		if err != nil {
+			return err
+		}
+		res, err := c.ClientSetLatency(ctx, rest[0], latency)
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "name":
+		if err := checkArgs("client name", rest, 2, 2, "<id> <name>"); err != nil {
+			return err
+		}
+		res, err := c.ClientSetName(ctx, rest[0], rest[1])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "delete":
+		if err := checkArgs("client delete", rest, 1, 1, "<id>"); err != nil {
+			return err
+		}
+		res, err := c.ServerDeleteClient(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+	}
+	return usagef("unknown client command %q", sub)
+}
+
+func groupCommand(ctx context.Context, c *snapclient.Client, out *printer, args []string) error {
+	if len(args) == 0 {
+		return usagef("usage: group mute|stream|clients|name <id> ...")
+	}
+	var sub, rest = args[0], args[1:]
+
+	switch sub {
+	case "mute":
+		if err := checkArgs("group mute", rest, 1, 2, "<id> [true|false]"); err != nil {
+			return err
+		}
+		muted, err := optionalBool(rest, 1)
+		if err != nil {
+			return err
+		}
+		res, err := c.GroupSetMute(ctx, rest[0], muted)
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "stream":
+		if err := checkArgs("group stream", rest, 2, 2, "<id> <stream>"); err != nil {
+			return err
+		}
+		res, err := c.GroupSetStream(ctx, rest[0], rest[1])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "clients":
+		if err := checkArgs("group clients", rest, 1, -1, "<id> [client...]"); err != nil {
+			return err
+		}
+		res, err := c.GroupSetClients(ctx, rest[0], append([]string{}, rest[1:]...))
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "name":
+		if err := checkArgs("group name", rest, 2, 2, "<id> <name>"); err != nil {
+			return err
+		}
+		res, err := c.GroupSetName(ctx, rest[0], rest[1])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+	}
+	return usagef("unknown group command %q", sub)
+}
+
+func streamCommand(ctx context.Context, c *snapclient.Client, out *printer, args []string) error {
+	if len(args) == 0 {
+		return usagef("usage: stream add|remove|control|set ...")
+	}
+	var sub, rest = args[0], args[1:]
+
+	switch sub {
+	case "add":
+		if err := checkArgs("stream add", rest, 1, 1, "<uri>"); err != nil {
+			return err
+		}
+		// Only checked, the URI is sent as given
+		uri, err := snapcast.ParseStreamURI(rest[0])
+		if err == nil {
+			err = uri.Validate()
+		}
+		if err != nil {
+			return usagef("%v", err)
+		}
+		res, err := c.StreamAddStream(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "remove":
+		if err := checkArgs("stream remove", rest, 1, 1, "<id>"); err != nil {
+			return err
+		}
+		res, err := c.StreamRemoveStream(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+
+	case "control":
+		if err := checkArgs("stream control", rest, 2, 3, "<id> <command> [seconds]"); err != nil {
+			return err
+		}
+		if err := streamControl(ctx, c, rest[0], snapcast.StreamCommand(rest[1]), rest[2:]); err != nil {
+			return err
+		}
+		return out.print(ok)
+
+	case "set":
+		if err := checkArgs("stream set", rest, 3, 3, "<id> <property> <value>"); err != nil {
+			return err
+		}
+		if err := streamSet(ctx, c, rest[0], rest[1], rest[2]); err != nil {
+			return err
+		}
+		return out.print(ok)
+	}
+	return usagef("unknown stream command %q", sub)
+}
+
+func streamControl(ctx context.Context, c *snapclient.Client, id string, command snapcast.StreamCommand, args []string) error {
+	var noArgs = func(do func(context.Context, string) error) error {
+		if len(args) != 0 {
+			return usagef("%s takes no arguments", command)
+		}
+		return do(ctx, id)
+	}
+	var seconds = func(do func(context.Context, string, time.Duration) error) error {
+		if len(args) != 1 {
+			return usagef("%s takes a number of seconds", command)
+		}
+		d, err := parseSeconds(args[0])
+		if err != nil {
+			return err
+		}
+		return do(ctx, id, d)
+	}
+
+	switch command {
+	case snapcast.StreamCommandPlay:
+		return noArgs(c.StreamPlay)
+	case snapcast.StreamCommandPause:
+		return noArgs(c.StreamPause)
+	case snapcast.StreamCommandPlayPause:
+		return noArgs(c.StreamPlayPause)
+	case snapcast.StreamCommandStop:
+		return noArgs(c.StreamStop)
+	case snapcast.StreamCommandNext:
+		return noArgs(c.StreamNext)
+	case snapcast.StreamCommandPrevious:
+		return noArgs(c.StreamPrevious)
+	case snapcast.StreamCommandSeek:
+		return seconds(c.StreamSeek)
+	case snapcast.StreamCommandSetPosition:
+		return seconds(c.StreamSetPosition)
+	}
+	return usagef("unknown stream command %q", command)
+}
+
+func streamSet(ctx context.Context, c *snapclient.Client, id, property, value string) error {
+	switch property {
+	case "loopStatus":
+		return c.StreamSetLoopStatus(ctx, id, snapcast.LoopStatus(value))
+	case "shuffle":
+		v, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		return c.StreamSetShuffle(ctx, id, v)
+	case "volume":
+		v, err := parseInt(value)
+		if err != nil {
+			return err
+		}
+		return c.StreamSetVolume(ctx, id, v)
+	case "mute":
+		v, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		return c.StreamSetMute(ctx, id, v)
+	case "rate":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return usagef("%q is not a number", value)
+		}
+		return c.StreamSetRate(ctx, id, v)
+	}
+	return usagef("unknown stream property %q", property)
+}
+
+// watch prints notifications until ctx is done or the connection drops
+func watch(ctx context.Context, c *snapclient.Client, out *printer) error {
+	var notifications = make(chan *snapcast.Notification, 16)
+	c.OnNotification(func(_ context.Context, msg *snapcast.Notification) {
+		select {
+		case notifications <- msg:
+		case <-ctx.Done():
+		}
+	})
+
+	closed, err := c.Listen(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-closed:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case msg := <-notifications:
+			if err := out.notification(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Command snapctl controls a snapserver from the command line, see snapctl -h for the commands
+//
+//	snapctl --host snapserver:1780 client volume 00:21:6a:7d:74:fc 40
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+const defaultHost = "localhost:1780"
+
+// usageError is a mistake on the command line, the usage is printed with it
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+const usage = `usage: snapctl [flags] <command> [args]
+
+commands:
+  status
+  client volume <id> <percent>
+  client mute <id> [true|false]
+  client latency <id> <ms>
+  client name <id> <name>
+  client delete <id>
+  group mute <id> [true|false]
+  group stream <id> <stream>
+  group clients <id> [client...]
+  group name <id> <name>
+  stream add <uri>
+  stream remove <id>
+  stream control <id> play|pause|playPause|stop|next|previous|seek <seconds>|setPosition <seconds>
+  stream set <id> loopStatus|shuffle|volume|mute|rate <value>
+  watch
+
+flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var code = run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run is main without the process around it, it returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	var fs = flag.NewFlagSet("snapctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		host   = fs.String("host", defaultHost, "snapserver host:port of the HTTP control API")
+		tls    = fs.Bool("tls", false, "use https and wss")
+		output = fs.String("output", "table", "output format, table or json")
+	)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	var out = &printer{w: stdout, json: *output == "json"}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "snapctl: unknown output %q\n", *output)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var c = snapclient.New(&snapclient.Options{Host: *host, SecureConnection: *tls})
+	defer c.Close()
+
+	if err := dispatch(ctx, c, out, fs.Arg(0), fs.Args()[1:]); err != nil {
+		fmt.Fprintf(stderr, "snapctl: %v\n", err)
+		var u *usageError
+		if errors.As(err, &u) {
+			fmt.Fprint(stderr, "run snapctl -h for usage\n")
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func dispatch(ctx context.Context, c *snapclient.Client, out *printer, cmd string, args []string) error {
+	switch cmd {
+	case "status":
+		if len(args) != 0 {
+			return usagef("status takes no arguments")
+		}
+		res, err := c.ServerGetStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return out.print(res)
+	case "client":
+		return clientCommand(ctx, c, out, args)
+	case "group":
+		return groupCommand(ctx, c, out, args)
+	case "stream":
+		return streamCommand(ctx, c, out, args)
+	case "watch":
+		if len(args) != 0 {
+			return usagef("watch takes no arguments")
+		}
+		return watch(ctx, c, out)
+	}
+	return usagef("unknown command %q", cmd)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+func snapctl(t *testing.T, srv *snapcasttest.Server, args ...string) (int, string, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	var code = run(ctx, append([]string{"--host", srv.Host()}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestStatus(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	code, out, errOut := snapctl(t, srv, "status")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	for _, want := range []string{"Snapserver 0.29.0", "default", "Living Room", "00:21:6a:7d:74:fc", "volume 74%", "Bedroom", "bedroom", "disconnected", "volume 30% muted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status has no %q:\n%s", want, out)
+		}
+	}
+
+	code, out, errOut = snapctl(t, srv, "--output", "json", "status")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	var res snapcast.ServerGetStatusResponse
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Server.Groups) != 2 || len(res.Server.Streams) != 1 {
+		t.Errorf("status = %+v", res.Server)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	for _, args := range [][]string{
+		{"client", "volume", "dc:a6:32:0b:44:12", "55"},
+		{"client", "latency", "b8:27:eb:5f:1e:77", "40"},
+		{"client", "name", "b8:27:eb:5f:1e:77", "Pantry"},
+		{"group", "mute", "c4b3a1f2-5e6d-4c7b-8a9f-0e1d2c3b4a59"},
+		{"group", "name", "c4b3a1f2-5e6d-4c7b-8a9f-0e1d2c3b4a59", "Upstairs"},
+		{"stream", "control", "default", "setPosition", "12.5"},
+		{"stream", "set", "default", "volume", "30"},
+		{"stream", "set", "default", "shuffle", "true"},
+		{"stream", "add", "pipe:///tmp/announce?sampleformat=48000:16:2&name=Announcements"},
+	} {
+		if code, _, errOut := snapctl(t, srv, args...); code != 0 {
+			t.Fatalf("%v: exit %d: %s", args, code, errOut)
+		}
+	}
+
+	var state = srv.State()
+	var bedroom = state.Groups[1]
+	if c := bedroom.Clients[0]; c.Config.Volume.Percent != 55 || !c.Config.Volume.Muted {
+		t.Errorf("volume = %+v, mute should be kept", c.Config.Volume)
+	}
+	if c := state.Groups[0].Clients[1]; c.Config.Latency != 40 || c.Config.Name != "Pantry" {
+		t.Errorf("client = %+v", c.Config)
+	}
+	if !bedroom.Muted || bedroom.Name != "Upstairs" {
+		t.Errorf("group = %+v", bedroom)
+	}
+	if p := state.Streams[0].Properties; p.Position != 12.5 || p.Volume != 30 || !p.Shuffle {
+		t.Errorf("stream properties = %+v", p)
+	}
+	if len(state.Streams) != 2 || state.Streams[1].ID != "Announcements" {
+		t.Errorf("streams = %+v", state.Streams)
+	}
+	for _, req := range srv.Requests() {
+		if *req.Method != snapcast.MethodStreamAddStream {
+			continue
+		}
+		var (
+			raw, _ = json.Marshal(req.Params)
+			params snapcast.StreamAddStream
+		)
+		json.Unmarshal(raw, &params)
+		if want := "pipe:///tmp/announce?sampleformat=48000:16:2&name=Announcements"; params.StreamUri != want {
+			t.Errorf("sent %s, want the URI as given", params.StreamUri)
+		}
+	}
+
+	code, out, _ := snapctl(t, srv, "client", "latency", "b8:27:eb:5f:1e:77", "10")
+	if code != 0 || !strings.HasPrefix(out, "latency") || !strings.Contains(out, "10") {
+		t.Errorf("exit %d, output %q", code, out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, 2},
+		{[]string{"reboot"}, 2},
+		{[]string{"client", "volume", "00:21:6a:7d:74:fc"}, 2},
+		{[]string{"client", "volume", "00:21:6a:7d:74:fc", "loud"}, 2},
+		{[]string{"client", "volume", "00:21:6a:7d:74:fc", "101"}, 2},
+		{[]string{"stream", "control", "default", "rewind"}, 2},
+		{[]string{"--output", "yaml", "status"}, 2},
+		{[]string{"stream", "add", "/tmp/announce"}, 2},
+		{[]string{"stream", "add", "pipe:///tmp/announce"}, 2},
+		{[]string{"stream", "add", "pipe:///tmp/announce?name=x&sampleformat=loud"}, 2},
+		// From the server
+		{[]string{"client", "name", "missing", "x"}, 1},
+		{[]string{"stream", "set", "default", "loopStatus", "forever"}, 1},
+	} {
+		if code, _, _ := snapctl(t, srv, tc.args...); code != tc.code {
+			t.Errorf("%v: exit %d, want %d", tc.args, code, tc.code)
+		}
+	}
+}
+
+// syncBuffer is written by the watch goroutine while the test reads it
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestWatch(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watchCtx, stop := context.WithCancel(ctx)
+	var (
+		stdout syncBuffer
+		stderr bytes.Buffer
+		done   = make(chan int, 1)
+	)
+	go func() {
+		done <- run(watchCtx, []string{"--host", srv.Host(), "--output", "json", "watch"}, &stdout, &stderr)
+	}()
+
+	// Changes are made by another control client, the one that makes a change isn't notified
+	var c = snapclient.New(&snapclient.Options{Host: srv.Host()})
+	defer c.Close()
+	for !strings.Contains(stdout.String(), "Client.OnVolumeChanged") {
+		if _, err := c.ClientSetVolume(ctx, "00:21:6a:7d:74:fc", snapcast.Volume{Percent: 12}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no notification printed, stderr: %s", stderr.String())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	stop()
+	if code := <-done; code != 0 {
+		t.Errorf("exit %d: %s", code, stderr.String())
+	}
+
+	var line struct {
+		Method string                         `json:"method"`
+		Params snapcast.ClientOnVolumeChanged `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(strings.SplitN(stdout.String(), "\n", 2)[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Method != "Client.OnVolumeChanged" || line.Params.Volume.Percent != 12 {
+		t.Errorf("printed %+v", line)
+	}
+}
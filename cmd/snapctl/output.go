@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// printer writes results as indented JSON or as text meant for people
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) print(v interface{}) error {
+	if p.json {
+		var enc = json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	switch v := v.(type) {
+	case *snapcast.ServerGetStatusResponse:
+		return p.server(&v.Server)
+	case *snapcast.ServerDeleteClientResponse:
+		return p.server(&v.Server)
+	case *snapcast.GroupSetClientsResponse:
+		return p.server(&v.Server)
+	case string:
+		_, err := fmt.Fprintln(p.w, v)
+		return err
+	}
+	return p.fields(v)
+}
+
+// fields prints the top level fields of a response, one per line
+func (p *printer) fields(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		_, err = fmt.Fprintln(p.w, string(b))
+		return err
+	}
+
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tw = tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, k := range keys {
+		var value = string(m[k])
+		var s string
+		if json.Unmarshal(m[k], &s) == nil {
+			value = s
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", k, value)
+	}
+	return tw.Flush()
+}
+
+// server prints streams and then each group with its clients
+func (p *printer) server(s *snapcast.Server) error {
+	var tw = tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%s %s on %s\n", s.Snapserver.Name, s.Snapserver.Version, s.Host.Name)
+	fmt.Fprintf(tw, "streams\n")
+	for _, st := range s.Streams {
+		var playback string
+		if st.Properties != nil {
+			playback = string(st.Properties.PlaybackStatus)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", st.ID, st.Status, playback, st.URI.Raw)
+	}
+
+	fmt.Fprintf(tw, "groups\n")
+	for _, g := range s.Groups {
+		var name = g.Name
+		if name == "" {
+			name = g.ID
+		}
+		fmt.Fprintf(tw, "  %s\tstream %s%s\n", name, g.StreamID, mutedSuffix(g.Muted))
+		for _, c := range g.Clients {
+			var connected = "disconnected"
+			if c.Connected {
+				connected = "connected"
+			}
+			fmt.Fprintf(tw, "    %s\t%s\t%s\tvolume %d%%%s\tlatency %dms\n",
+				c.Name(), c.ID, connected, c.Config.Volume.Percent, mutedSuffix(c.Config.Volume.Muted), c.Config.Latency)
+		}
+	}
+	return tw.Flush()
+}
+
+// notification prints one line per notification, JSON lines with --output json
+func (p *printer) notification(msg *snapcast.Notification) error {
+	if p.json {
+		return json.NewEncoder(p.w).Encode(struct {
+			Time   string      `json:"time"`
+			Method string      `json:"method"`
+			Params interface{} `json:"params"`
+		}{msg.ReceivedAt.Format("2006-01-02T15:04:05.000Z07:00"), string(*msg.Method), msg.Params})
+	}
+
+	params, err := json.Marshal(msg.Params)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s %s %s\n", msg.ReceivedAt.Format("15:04:05.000"), *msg.Method, params)
+	return err
+}
+
+func mutedSuffix(m bool) string {
+	if m {
+		return " muted"
+	}
+	return ""
+}
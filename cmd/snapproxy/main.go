@@ -0,0 +1,137 @@
+// Command snapproxy multiplexes control connections onto a single connection to snapserver.
+// Downstreams outside --trusted get the guest policy of --allow, --deny, --rate and --burst.
+// Guests may not delete clients or add and remove streams unless --deny says otherwise
+//
+//	snapproxy --host snapserver:1780 --listen :1790 --tcp :1795 --trusted 192.168.1.10/32 --rate 5
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/internal/runloop"
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapproxy"
+	"golang.org/x/time/rate"
+)
+
+// Methods that destroy configuration, denied to guests by default
+var destructive = []string{
+	string(snapcast.MethodServerDeleteClient),
+	string(snapcast.MethodStreamAddStream),
+	string(snapcast.MethodStreamRemoveStream),
+}
+
+func methods(l string) []snapcast.RequestMethod {
+	var ms []snapcast.RequestMethod
+	for _, m := range list(l) {
+		ms = append(ms, snapcast.RequestMethod(m))
+	}
+	return ms
+}
+
+// list splits a comma separated flag, skipping empty entries
+func list(l string) []string {
+	var items []string
+	for _, s := range strings.Split(l, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+func prefixes(l string) []netip.Prefix {
+	var ps []netip.Prefix
+	for _, s := range list(l) {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			log.Fatalf("--trusted: %v", err)
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func main() {
+	var (
+		host    = flag.String("host", "localhost:1780", "snapserver host:port of the HTTP control API")
+		tls     = flag.Bool("tls", false, "use https and wss")
+		listen  = flag.String("listen", ":1790", "address to serve HTTP and WebSocket downstreams on, at /jsonrpc")
+		tcp     = flag.String("tcp", ":1795", "address to serve TCP downstreams on, empty to disable")
+		trusted = flag.String("trusted", "127.0.0.1/32,::1/128", "comma separated networks that may call everything")
+		allow   = flag.String("allow", "", "comma separated methods guests may call, all if empty")
+		deny    = flag.String("deny", strings.Join(destructive, ","), "comma separated methods guests may not call, empty to deny none")
+		rps     = flag.Float64("rate", 0, "requests per second of a guest, 0 is unlimited")
+		burst   = flag.Int("burst", 10, "request burst of a guest")
+		origins = flag.String("origins", "", "comma separated hosts of web pages besides the proxy's own that may connect over WebSocket, e.g. snapweb.local:1780")
+	)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		trustedNets = prefixes(*trusted)
+		guest       = &snapproxy.Policy{
+			Allow: methods(*allow),
+			Deny:  methods(*deny),
+			Rate:  rate.Limit(*rps),
+			Burst: *burst,
+		}
+		client = snapclient.New(&snapclient.Options{
+			Host:             *host,
+			SecureConnection: *tls,
+			Reconnect:        &snapclient.ReconnectPolicy{Jitter: 0.2},
+			// Downstreams have their own limits
+			RateLimiter: rate.NewLimiter(rate.Inf, 0),
+		})
+		proxy = snapproxy.New(client, &snapproxy.Options{
+			Policy: func(d *snapproxy.Downstream) *snapproxy.Policy {
+				if ap, err := netip.ParseAddrPort(d.RemoteAddr); err == nil {
+					for _, p := range trustedNets {
+						if p.Contains(ap.Addr().Unmap()) {
+							return &snapproxy.Policy{}
+						}
+					}
+				}
+				return guest
+			},
+			OriginPatterns: list(*origins),
+		})
+	)
+	defer client.Close()
+	defer proxy.Close()
+
+	var mux = http.NewServeMux()
+	mux.Handle("/jsonrpc", proxy)
+	var srv = &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
+	defer srv.Close()
+
+	if *tcp != "" {
+		l, err := net.Listen("tcp", *tcp)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer l.Close()
+		go proxy.ServeTCP(l)
+	}
+
+	runloop.Run(ctx, proxy.Run)
+}
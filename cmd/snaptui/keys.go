@@ -0,0 +1,87 @@
+package main
+
+import "io"
+
+type key int
+
+const (
+	keyUp key = iota
+	keyDown
+	keyVolumeUp
+	keyVolumeDown
+	keyMute
+	keyStream
+	keyMoveClient
+	keyQuit
+)
+
+// Escape sequences of the arrow keys, in normal and application cursor mode
+var arrows = map[string]key{
+	"\x1b[A": keyUp,
+	"\x1b[B": keyDown,
+	"\x1b[C": keyVolumeUp,
+	"\x1b[D": keyVolumeDown,
+	"\x1bOA": keyUp,
+	"\x1bOB": keyDown,
+	"\x1bOC": keyVolumeUp,
+	"\x1bOD": keyVolumeDown,
+}
+
+var letters = map[byte]key{
+	'k':  keyUp,
+	'j':  keyDown,
+	'l':  keyVolumeUp,
+	'+':  keyVolumeUp,
+	'=':  keyVolumeUp,
+	'h':  keyVolumeDown,
+	'-':  keyVolumeDown,
+	'm':  keyMute,
+	's':  keyStream,
+	'g':  keyMoveClient,
+	'q':  keyQuit,
+	0x03: keyQuit, // Ctrl-C, raw mode doesn't turn it into a signal
+}
+
+// parseKeys splits what the terminal sent into keys, anything unknown is skipped
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == 0x1b && len(b) >= 3 && (b[1] == '[' || b[1] == 'O') {
+			if k, ok := arrows[string(b[:3])]; ok {
+				keys = append(keys, k)
+			}
+			b = b[escapeLen(b):]
+			continue
+		}
+		if k, ok := letters[b[0]]; ok {
+			keys = append(keys, k)
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// escapeLen is the length of the escape sequence at the start of b, up to its final byte
+func escapeLen(b []byte) int {
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// readKeys sends the keys read from r until it fails
+func readKeys(r io.Reader, keys chan<- key) {
+	var buf = make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+		if err != nil {
+			close(keys)
+			return
+		}
+	}
+}
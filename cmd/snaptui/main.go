@@ -0,0 +1,135 @@
+// Command snaptui is a full screen terminal mixer for snapserver. It shows every group with its
+// clients, volumes and what their stream is playing, and updates live from notifications
+//
+//	snaptui --host snapserver:1780
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+	"golang.org/x/term"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultHost = "localhost:1780"
+	// Terminal control sequences
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	home        = "\x1b[H\x1b[2J"
+)
+
+// requestTimeout for the change made by one key press
+var requestTimeout = 5 * time.Second
+
+func main() {
+	var (
+		host = flag.String("host", defaultHost, "snapserver host:port of the HTTP control API")
+		tls  = flag.Bool("tls", false, "use https and wss")
+	)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *host, *tls); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "snaptui: %v\n", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, host string, tls bool) error {
+	var fd = int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("stdin is not a terminal")
+	}
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, old)
+
+	fmt.Fprint(os.Stdout, enterScreen)
+	defer fmt.Fprint(os.Stdout, leaveScreen)
+
+	var c = snapclient.New(&snapclient.Options{
+		Host:             host,
+		SecureConnection: tls,
+		// Holding down a volume key sends a request per repeat
+		RateLimiter: rate.NewLimiter(rate.Every(20*time.Millisecond), 20),
+		Reconnect:   &snapclient.ReconnectPolicy{},
+	})
+	defer c.Close()
+
+	var keys = make(chan key, 16)
+	go readKeys(os.Stdin, keys)
+
+	return mixer(ctx, c, host, keys, os.Stdout, terminalSize)
+}
+
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return width, height
+}
+
+// mixer draws the state to out and runs key presses until q or ctx is done
+func mixer(ctx context.Context, c *snapclient.Client, host string, keys <-chan key, out io.Writer, size func() (int, int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		state  = snapstate.New(c)
+		runErr = make(chan error, 1)
+		m      = &model{host: host}
+		// Picks up terminal resizes
+		ticker = time.NewTicker(time.Second)
+	)
+	defer ticker.Stop()
+	go func() { runErr <- state.Run(ctx) }()
+
+	for {
+		var changed = state.Changed()
+		if state.Synced() {
+			m.setServer(state.Server())
+		}
+		width, height := size()
+		if _, err := fmt.Fprint(out, home+m.view(width, height)); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-runErr:
+			return err
+		case <-changed:
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok || k == keyQuit {
+				return nil
+			}
+			if do := m.key(k); do != nil {
+				m.status = ""
+				reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+				if err := do(reqCtx, c, state); err != nil {
+					m.status = "error: " + err.Error()
+				}
+				cancel()
+			}
+		}
+	}
+}
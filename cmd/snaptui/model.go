@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+)
+
+// volumeStep is how much the volume keys change a client's volume by
+const volumeStep = 5
+
+// row of the mixer, a group or one of its clients
+type row struct {
+	group  int
+	client int // -1 for the group itself
+}
+
+// action is a change requested with the keyboard. It applies the result to state right away,
+// so a key pressed before the notification arrives starts from the new values
+type action func(ctx context.Context, c *snapclient.Client, state *snapstate.State) error
+
+// model is what the mixer shows, it only changes through setServer and key
+type model struct {
+	server snapcast.Server
+	host   string
+	rows   []row
+	cursor int
+	// ID of the selected group or client, keeps the selection when rows move around
+	selected string
+	status   string
+}
+
+func (m *model) setServer(s snapcast.Server) {
+	m.server = s
+	m.rows = m.rows[:0]
+	for gi, g := range s.Groups {
+		m.rows = append(m.rows, row{group: gi, client: -1})
+		for ci := range g.Clients {
+			m.rows = append(m.rows, row{group: gi, client: ci})
+		}
+	}
+
+	for i, r := range m.rows {
+		if m.rowID(r) == m.selected {
+			m.cursor = i
+			return
+		}
+	}
+	m.cursor = min(m.cursor, len(m.rows)-1)
+	m.cursor = max(m.cursor, 0)
+	m.selected = ""
+	if len(m.rows) > 0 {
+		m.selected = m.rowID(m.rows[m.cursor])
+	}
+}
+
+func (m *model) rowID(r row) string {
+	var g = &m.server.Groups[r.group]
+	if r.client < 0 {
+		return g.ID
+	}
+	return g.Clients[r.client].ID
+}
+
+func (m *model) moveCursor(delta int) {
+	if len(m.rows) == 0 {
+		return
+	}
+	m.cursor = (m.cursor + delta + len(m.rows)) % len(m.rows)
+	m.selected = m.rowID(m.rows[m.cursor])
+}
+
+// current group and client under the cursor, client is nil on a group row
+func (m *model) current() (*snapcast.Group, *snapcast.Client) {
+	if len(m.rows) == 0 {
+		return nil, nil
+	}
+	var (
+		r = m.rows[m.cursor]
+		g = &m.server.Groups[r.group]
+	)
+	if r.client < 0 {
+		return g, nil
+	}
+	return g, &g.Clients[r.client]
+}
+
+// key moves the cursor or returns the action for the key, nil if there's nothing to do
+func (m *model) key(k key) action {
+	switch k {
+	case keyUp:
+		m.moveCursor(-1)
+		return nil
+	case keyDown:
+		m.moveCursor(1)
+		return nil
+	}
+
+	group, client := m.current()
+	if group == nil {
+		return nil
+	}
+
+	switch k {
+	case keyVolumeUp, keyVolumeDown:
+		var delta = volumeStep
+		if k == keyVolumeDown {
+			delta = -volumeStep
+		}
+		var clients = group.Clients
+		if client != nil {
+			clients = []snapcast.Client{*client}
+		}
+		return setVolumes(clients, delta)
+
+	case keyMute:
+		if client != nil {
+			var id, volume = client.ID, client.Config.Volume
+			volume.Muted = !volume.Muted
+			return func(ctx context.Context, c *snapclient.Client, state *snapstate.State) error {
+				return setVolume(ctx, c, state, id, volume)
+			}
+		}
+		var id, muted = group.ID, !group.Muted
+		return func(ctx context.Context, c *snapclient.Client, state *snapstate.State) error {
+			res, err := c.GroupSetMute(ctx, id, muted)
+			if err != nil {
+				return err
+			}
+			state.ApplyGroupOnMute(&snapcast.GroupOnMute{ID: id, Mute: res.Muted})
+			return nil
+		}
+
+	case keyStream:
+		var next = m.nextStream(group.StreamID)
+		if next == "" || next == group.StreamID {
+			return nil
+		}
+		var id = group.ID
+		return func(ctx context.Context, c *snapclient.Client, state *snapstate.State) error {
+			res, err := c.GroupSetStream(ctx, id, next)
+			if err != nil {
+				return err
+			}
+			state.ApplyGroupOnStreamChanged(&snapcast.GroupOnStreamChanged{ID: id, StreamId: res.StreamID})
+			return nil
+		}
+
+	case keyMoveClient:
+		if client == nil || len(m.server.Groups) < 2 {
+			return nil
+		}
+		var (
+			target  = m.server.Groups[(m.rows[m.cursor].group+1)%len(m.server.Groups)]
+			clients = []string{client.ID}
+		)
+		for _, c := range target.Clients {
+			clients = append(clients, c.ID)
+		}
+		// Groups may be removed or created, the state follows with the Server.OnUpdate that comes next
+		return func(ctx context.Context, c *snapclient.Client, _ *snapstate.State) error {
+			_, err := c.GroupSetClients(ctx, target.ID, clients)
+			return err
+		}
+	}
+	return nil
+}
+
+// setVolumes changes the volume of every client by delta, keeping it within 0 to 100
+func setVolumes(clients []snapcast.Client, delta int) action {
+	return func(ctx context.Context, c *snapclient.Client, state *snapstate.State) error {
+		for _, client := range clients {
+			var volume = client.Config.Volume
+			volume.Percent = max(0, min(100, volume.Percent+delta))
+			if volume.Percent == client.Config.Volume.Percent {
+				continue
+			}
+			if err := setVolume(ctx, c, state, client.ID, volume); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func setVolume(ctx context.Context, c *snapclient.Client, state *snapstate.State, id string, volume snapcast.Volume) error {
+	res, err := c.ClientSetVolume(ctx, id, volume)
+	if err != nil {
+		return err
+	}
+	state.ApplyClientOnVolumeChanged(&snapcast.ClientOnVolumeChanged{ID: id, Volume: res.Volume})
+	return nil
+}
+
+// nextStream after id in the order the server lists them
+func (m *model) nextStream(id string) string {
+	var streams = m.server.Streams
+	if len(streams) == 0 {
+		return ""
+	}
+	for i, st := range streams {
+		if st.ID == id {
+			return streams[(i+1)%len(streams)].ID
+		}
+	}
+	return streams[0].ID
+}
+
+func (m *model) stream(id string) *snapcast.Stream {
+	for i := range m.server.Streams {
+		if m.server.Streams[i].ID == id {
+			return &m.server.Streams[i]
+		}
+	}
+	return nil
+}
+
+const help = "↑/↓ select  ←/→ volume  m mute  s stream  g move to next group  q quit"
+
+// view renders the mixer to fit width and height, lines end in \r\n for a terminal in raw mode
+func (m *model) view(width, height int) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("snaptui  %s  %s %s", m.host, m.server.Snapserver.Name, m.server.Snapserver.Version), "")
+
+	var body []string
+	for i, r := range m.rows {
+		var (
+			g      = &m.server.Groups[r.group]
+			cursor = "  "
+		)
+		if i == m.cursor {
+			cursor = "> "
+		}
+		if r.client < 0 {
+			body = append(body, cursor+m.groupLine(g))
+		} else {
+			body = append(body, cursor+"    "+clientLine(&g.Clients[r.client]))
+		}
+	}
+	if len(m.rows) == 0 {
+		body = append(body, "  waiting for the server...")
+	}
+
+	// Scroll so the cursor stays on screen between the header and footer
+	var room = max(height-len(lines)-3, 1)
+	var offset = max(m.cursor-room+1, 0)
+	body = body[offset:]
+	if len(body) > room {
+		body = body[:room]
+	}
+	lines = append(lines, body...)
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, m.status, help)
+
+	for i := range lines {
+		lines[i] = truncate(lines[i], width)
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+func (m *model) groupLine(g *snapcast.Group) string {
+	var line = fmt.Sprintf("%-24s stream %s", g.DisplayName(), g.StreamID)
+	if g.Muted {
+		line += "  muted"
+	}
+	if st := m.stream(g.StreamID); st != nil && st.Properties != nil {
+		var p = st.Properties
+		if p.PlaybackStatus != "" {
+			line += fmt.Sprintf("  [%s]", p.PlaybackStatus)
+		}
+		if np := nowPlaying(p.Metadata); np != "" {
+			line += "  " + np
+		}
+	}
+	return line
+}
+
+// nowPlaying is "title - artist", whichever of them is known
+func nowPlaying(md *snapcast.Metadata) string {
+	if md == nil {
+		return ""
+	}
+	var parts []string
+	if md.Title != "" {
+		parts = append(parts, md.Title)
+	}
+	if len(md.Artist) > 0 {
+		parts = append(parts, strings.Join(md.Artist, ", "))
+	}
+	return strings.Join(parts, " - ")
+}
+
+func clientLine(c *snapcast.Client) string {
+	var line = fmt.Sprintf("%-20s %s %3d%%", c.Name(), volumeBar(c.Config.Volume.Percent, 20), c.Config.Volume.Percent)
+	if c.Config.Volume.Muted {
+		line += "  muted"
+	}
+	if !c.Connected {
+		line += "  disconnected"
+	}
+	return line
+}
+
+func volumeBar(percent, width int) string {
+	var filled = max(0, min(width, (percent*width+50)/100))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// truncate cuts s to width runes
+func truncate(s string, width int) string {
+	var n int
+	for i := range s {
+		if n == width {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
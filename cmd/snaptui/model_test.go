@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+func TestParseKeys(t *testing.T) {
+	var got = parseKeys([]byte("\x1b[A\x1bOBjk+-\x1b[1;5Cmsgx\x1b[Dq\x03"))
+	var want = []key{keyUp, keyDown, keyDown, keyUp, keyVolumeUp, keyVolumeDown, keyMute, keyStream, keyMoveClient, keyVolumeDown, keyQuit, keyQuit}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestView(t *testing.T) {
+	var m = &model{host: "snapserver:1780"}
+	m.setServer(*snapcasttest.DefaultState())
+
+	var lines = strings.Split(m.view(100, 12), "\r\n")
+	if len(lines) != 12 {
+		t.Fatalf("%d lines", len(lines))
+	}
+	for _, want := range []string{"> Group 4dcc4e3b", "Test Track - Test Artist", "Living Room", "[███████████████░░░░░]  74%", "Bedroom", "muted  disconnected"} {
+		if !strings.Contains(strings.Join(lines, "\n"), want) {
+			t.Errorf("view has no %q:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+	for _, l := range lines {
+		if len([]rune(l)) > 100 {
+			t.Errorf("line longer than the terminal: %q", l)
+		}
+	}
+
+	// The selection follows the client when the rows change
+	m.key(keyDown)
+	m.key(keyDown)
+	var s = *snapcasttest.DefaultState()
+	s.Groups[0], s.Groups[1] = s.Groups[1], s.Groups[0]
+	m.setServer(s)
+	if _, c := m.current(); c == nil || c.Config.Name != "Kitchen" {
+		t.Errorf("selected %+v", c)
+	}
+
+	// Small terminals scroll to the cursor
+	m.key(keyDown)
+	if view := m.view(100, 6); !strings.Contains(view, "> ") {
+		t.Errorf("cursor scrolled off:\n%s", view)
+	}
+}
+
+// syncBuffer is written by the mixer while the test reads it
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestMixer(t *testing.T) {
+	var state = snapcasttest.DefaultState()
+	var radio = state.Streams[0]
+	radio.ID = "radio"
+	radio.Properties = nil
+	state.Streams = append(state.Streams, radio)
+
+	var srv = snapcasttest.NewServer(state)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		c    = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		keys = make(chan key)
+		out  syncBuffer
+		done = make(chan error, 1)
+	)
+	defer c.Close()
+	go func() {
+		done <- mixer(ctx, c, srv.Host(), keys, &out, func() (int, int) { return 120, 30 })
+	}()
+
+	var waitFor = func(what string, ok func(s snapcast.Server) bool) {
+		t.Helper()
+		for !ok(srv.State()) {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("%s, screen:\n%s", what, out.String())
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	var press = func(ks ...key) {
+		for _, k := range ks {
+			keys <- k
+		}
+	}
+
+	for !strings.Contains(out.String(), "Living Room") {
+		select {
+		case <-ctx.Done():
+			t.Fatal("never drew the state")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Living Room up a step and muted
+	press(keyDown, keyVolumeUp, keyMute)
+	waitFor("volume", func(s snapcast.Server) bool {
+		var v = s.Groups[0].Clients[0].Config.Volume
+		return v.Percent == 74+volumeStep && v.Muted
+	})
+
+	// First group to the next stream
+	press(keyUp, keyStream)
+	waitFor("stream", func(s snapcast.Server) bool { return s.Groups[0].StreamID == "radio" })
+
+	// Kitchen into the Bedroom group
+	press(keyDown, keyDown, keyMoveClient)
+	waitFor("move", func(s snapcast.Server) bool {
+		return len(s.Groups[0].Clients) == 1 && len(s.Groups[1].Clients) == 2
+	})
+
+	// Changes from elsewhere show up
+	var other = snapclient.New(&snapclient.Options{Host: srv.Host()})
+	defer other.Close()
+	if _, err := other.ClientSetName(ctx, "00:21:6a:7d:74:fc", "Lounge"); err != nil {
+		t.Fatal(err)
+	}
+	for !strings.Contains(out.String(), "Lounge") {
+		select {
+		case <-ctx.Done():
+			t.Fatal("rename not drawn")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	press(keyQuit)
+	if err := <-done; err != nil {
+		t.Errorf("mixer returned %v", err)
+	}
+}
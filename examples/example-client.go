@@ -48,13 +48,7 @@ func main() {
 		}
 	}()
 
-	res, err := client.Send(context.Background(), snapcast.MethodServerGetStatus, struct{}{})
-	check(err)
-	if res.Error != nil {
-		log.Fatalln(res.Error)
-	}
-
-	initialState, err := snapcast.ParseResult[snapcast.ServerGetStatusResponse](res.Result)
+	initialState, err := client.ServerGetStatus(context.Background())
 	check(err)
 	fmt.Println("Initial state", initialState)
 
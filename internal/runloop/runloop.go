@@ -0,0 +1,28 @@
+// Package runloop keeps the long running commands going while the snapserver comes and goes
+package runloop
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Between a run ending and the next
+var pause = 5 * time.Second
+
+// Run calls run until ctx is done. A run only ends when the server stays away past the
+// reconnect policy, so Run logs why and starts over after a pause
+func Run(ctx context.Context, run func(ctx context.Context) error) {
+	for {
+		err := run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("snapserver:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package runloop
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	pause = time.Millisecond
+	defer func() { pause = 5 * time.Second }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int
+	var done = make(chan struct{})
+	go func() {
+		Run(ctx, func(ctx context.Context) error {
+			if runs++; runs == 3 {
+				cancel()
+				return ctx.Err()
+			}
+			return errors.New("gave up reconnecting")
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return once ctx was done")
+	}
+	if runs != 3 {
+		t.Errorf("%d runs, want 3", runs)
+	}
+}
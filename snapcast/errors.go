@@ -0,0 +1,85 @@
+package snapcast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Snapserver error codes for Stream.Control and Stream.SetProperty
+const (
+	CodeCanNotControl        = 1
+	CodeCanGoNextIsFalse     = 2
+	CodeCanGoPreviousIsFalse = 3
+	CodeCanPlayIsFalse       = 4
+	CodeCanPauseIsFalse      = 5
+	CodeCanSeekIsFalse       = 6
+	CodeCanControlIsFalse    = 7
+)
+
+// RPCError is a JSON-RPC error returned by snapserver.
+// Compare with errors.Is against the Err* sentinels
+type RPCError struct {
+	Method  RequestMethod
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+// Sentinels without a message match on code alone, the others also require the message to match
+var (
+	ErrParse          = &RPCError{Code: CodeParseError}
+	ErrInvalidRequest = &RPCError{Code: CodeInvalidRequest}
+	ErrMethodNotFound = &RPCError{Code: CodeMethodNotFound}
+	ErrInvalidParams  = &RPCError{Code: CodeInvalidParams}
+	ErrInternal       = &RPCError{Code: CodeInternalError}
+
+	// Snapserver reports unknown IDs as internal errors
+	ErrClientNotFound = &RPCError{Code: CodeInternalError, Message: "Client not found"}
+	ErrGroupNotFound  = &RPCError{Code: CodeInternalError, Message: "Group not found"}
+	ErrStreamNotFound = &RPCError{Code: CodeInternalError, Message: "Stream not found"}
+
+	ErrCanNotControl        = &RPCError{Code: CodeCanNotControl}
+	ErrCanGoNextIsFalse     = &RPCError{Code: CodeCanGoNextIsFalse}
+	ErrCanGoPreviousIsFalse = &RPCError{Code: CodeCanGoPreviousIsFalse}
+	ErrCanPlayIsFalse       = &RPCError{Code: CodeCanPlayIsFalse}
+	ErrCanPauseIsFalse      = &RPCError{Code: CodeCanPauseIsFalse}
+	ErrCanSeekIsFalse       = &RPCError{Code: CodeCanSeekIsFalse}
+	ErrCanControlIsFalse    = &RPCError{Code: CodeCanControlIsFalse}
+)
+
+func NewRPCError(method RequestMethod, e *Error) *RPCError {
+	return &RPCError{
+		Method:  method,
+		Code:    e.Code,
+		Message: e.Message,
+		Data:    e.Data,
+	}
+}
+
+func (e *RPCError) Error() string {
+	var msg = e.Message
+	if msg == "" {
+		msg = "error"
+	}
+	if e.Method == "" {
+		return fmt.Sprintf("%s (code %d)", msg, e.Code)
+	}
+	return fmt.Sprintf("%s: %s (code %d)", e.Method, msg, e.Code)
+}
+
+func (e *RPCError) Is(target error) bool {
+	t, ok := target.(*RPCError)
+	if !ok || t.Code != e.Code {
+		return false
+	}
+	return t.Message == "" || strings.EqualFold(t.Message, e.Message)
+}
@@ -0,0 +1,31 @@
+package snapcast
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRPCErrorIs(t *testing.T) {
+	var err error = NewRPCError(MethodClientSetVolume, &Error{Code: CodeInternalError, Message: "Client not found"})
+	err = fmt.Errorf("wrapped: %w", err)
+
+	if !errors.Is(err, ErrClientNotFound) {
+		t.Error("expected ErrClientNotFound")
+	}
+	if !errors.Is(err, ErrInternal) {
+		t.Error("expected ErrInternal")
+	}
+	if errors.Is(err, ErrGroupNotFound) || errors.Is(err, ErrMethodNotFound) {
+		t.Error("matched the wrong sentinel")
+	}
+
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Method != MethodClientSetVolume {
+		t.Errorf("errors.As: %v", rpcErr)
+	}
+
+	if got, want := rpcErr.Error(), "Client.SetVolume: Client not found (code -32603)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
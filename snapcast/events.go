@@ -47,11 +47,11 @@ type (
 
 	GroupSetMuteRequest struct {
 		ID    string `json:"id"`
-		Muted bool   `json:"muted"`
+		Muted bool   `json:"mute"`
 	}
 
 	GroupSetMuteResponse struct {
-		Muted bool `json:"muted"`
+		Muted bool `json:"mute"`
 	}
 
 	GroupSetStreamRequest struct {
@@ -69,7 +69,7 @@ type (
 	}
 
 	GroupSetClientsResponse struct {
-		Clients []*Client `json:"clients"`
+		Server Server `json:"server"`
 	}
 
 	GroupSetNameRequest struct {
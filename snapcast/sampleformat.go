@@ -0,0 +1,69 @@
+package snapcast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleFormat of a PCM stream, written as "rate:bits:channels" e.g. "48000:16:2"
+type SampleFormat struct {
+	Rate     int
+	Bits     int
+	Channels int
+}
+
+func ParseSampleFormat(s string) (SampleFormat, error) {
+	var parts = strings.Split(s, ":")
+	if len(parts) != 3 {
+		return SampleFormat{}, fmt.Errorf("invalid sample format %q, want rate:bits:channels", s)
+	}
+
+	var values [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v <= 0 {
+			return SampleFormat{}, fmt.Errorf("invalid sample format %q, want rate:bits:channels", s)
+		}
+		values[i] = v
+	}
+
+	var sf = SampleFormat{Rate: values[0], Bits: values[1], Channels: values[2]}
+	switch sf.Bits {
+	case 8, 16, 24, 32:
+	default:
+		return SampleFormat{}, fmt.Errorf("invalid sample format %q, bits must be 8, 16, 24 or 32", s)
+	}
+	return sf, nil
+}
+
+func (f SampleFormat) String() string {
+	return fmt.Sprintf("%d:%d:%d", f.Rate, f.Bits, f.Channels)
+}
+
+// SampleSize in bytes, 24 bit samples are stored in 4 bytes like snapserver does
+func (f SampleFormat) SampleSize() int {
+	if f.Bits == 24 {
+		return 4
+	}
+	return f.Bits / 8
+}
+
+// FrameSize is the size in bytes of one sample for every channel
+func (f SampleFormat) FrameSize() int {
+	return f.SampleSize() * f.Channels
+}
+
+// Duration of n bytes of interleaved PCM
+func (f SampleFormat) Duration(n int) time.Duration {
+	if f.Rate == 0 || f.FrameSize() == 0 {
+		return 0
+	}
+	return time.Duration(n/f.FrameSize()) * time.Second / time.Duration(f.Rate)
+}
+
+// Bytes of interleaved PCM needed for d, rounded down to whole frames
+func (f SampleFormat) Bytes(d time.Duration) int {
+	return int(d*time.Duration(f.Rate)/time.Second) * f.FrameSize()
+}
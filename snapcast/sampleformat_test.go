@@ -0,0 +1,25 @@
+package snapcast
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampleFormat(t *testing.T) {
+	sf, err := ParseSampleFormat("48000:16:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf != (SampleFormat{Rate: 48000, Bits: 16, Channels: 2}) || sf.String() != "48000:16:2" {
+		t.Errorf("parsed %+v", sf)
+	}
+	if sf.FrameSize() != 4 || sf.Bytes(20*time.Millisecond) != 3840 || sf.Duration(3840) != 20*time.Millisecond {
+		t.Errorf("sizes %d %d %s", sf.FrameSize(), sf.Bytes(20*time.Millisecond), sf.Duration(3840))
+	}
+
+	for _, s := range []string{"", "48000:16", "48000:12:2", "48000:16:0", "a:16:2"} {
+		if _, err := ParseSampleFormat(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
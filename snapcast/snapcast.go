@@ -147,17 +147,20 @@ func ParseResult[T any](result interface{}) (*T, error) {
 
 type (
 	Stream struct {
-		ID     string       `json:"id"`
-		Status StreamStatus `json:"status"`
-		URI    struct {
-			Fragment string            `json:"fragment"`
-			Host     string            `json:"host"`
-			Path     string            `json:"path"`
-			Query    map[string]string `json:"query"`
-			Raw      string            `json:"raw"`
-			Scheme   string            `json:"scheme"`
-		} `json:"uri"`
-		Properties *Properties `json:"properties,omitempty"`
+		ID         string       `json:"id"`
+		Status     StreamStatus `json:"status"`
+		URI        URI          `json:"uri"`
+		Properties *Properties  `json:"properties,omitempty"`
+	}
+
+	// URI of a stream as the server reports it, see StreamURI to build one
+	URI struct {
+		Fragment string            `json:"fragment"`
+		Host     string            `json:"host"`
+		Path     string            `json:"path"`
+		Query    map[string]string `json:"query"`
+		Raw      string            `json:"raw"`
+		Scheme   string            `json:"scheme"`
 	}
 
 	Properties struct {
@@ -252,3 +255,19 @@ type (
 		Streams    []Stream   `json:"streams"`
 	}
 )
+
+// Name is the configured name, falling back to the host name like snapweb does
+func (c *Client) Name() string {
+	if c.Config.Name != "" {
+		return c.Config.Name
+	}
+	return c.Host.Name
+}
+
+// DisplayName is the configured name, falling back to the start of the ID for an unnamed group
+func (g *Group) DisplayName() string {
+	if g.Name != "" {
+		return g.Name
+	}
+	return "Group " + g.ID[:min(8, len(g.ID))]
+}
@@ -0,0 +1,412 @@
+package snapcast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StreamType is the scheme of a stream URI
+type StreamType string
+
+const (
+	StreamPipe      StreamType = "pipe"
+	StreamLibrespot StreamType = "librespot"
+	StreamAirplay   StreamType = "airplay"
+	StreamFile      StreamType = "file"
+	StreamProcess   StreamType = "process"
+	StreamTCP       StreamType = "tcp"
+	StreamALSA      StreamType = "alsa"
+	StreamMeta      StreamType = "meta"
+	StreamJack      StreamType = "jack"
+)
+
+var ErrInvalidStreamURI = errors.New("invalid stream uri")
+
+// StreamURI is a stream source as configured in snapserver.conf or passed to Stream.AddStream,
+// e.g. pipe:///tmp/snapfifo?name=default&sampleformat=48000:16:2
+type StreamURI struct {
+	Type StreamType
+	// host:port of tcp streams
+	Host string
+	// FIFO, binary, file or device depending on Type, the sources of meta streams
+	Path     string
+	Query    map[string]string
+	Fragment string
+}
+
+func newStreamURI(t StreamType, host, path, name string) *StreamURI {
+	return &StreamURI{Type: t, Host: host, Path: path, Query: map[string]string{"name": name}}
+}
+
+// NewPipeURI reads PCM from the FIFO at path
+func NewPipeURI(path, name string) *StreamURI {
+	return newStreamURI(StreamPipe, "", path, name)
+}
+
+// NewLibrespotURI runs the librespot binary at path
+func NewLibrespotURI(path, name string) *StreamURI {
+	return newStreamURI(StreamLibrespot, "", path, name)
+}
+
+// NewAirplayURI runs the shairport-sync binary at path
+func NewAirplayURI(path, name string) *StreamURI {
+	return newStreamURI(StreamAirplay, "", path, name)
+}
+
+// NewFileURI plays the WAV file at path
+func NewFileURI(path, name string) *StreamURI {
+	return newStreamURI(StreamFile, "", path, name)
+}
+
+// NewProcessURI runs the binary at path and reads PCM from its stdout
+func NewProcessURI(path, name string) *StreamURI {
+	return newStreamURI(StreamProcess, "", path, name)
+}
+
+// NewTCPURI reads PCM from a socket, host is where snapserver listens or connects to depending on mode
+func NewTCPURI(host, name string) *StreamURI {
+	return newStreamURI(StreamTCP, host, "", name)
+}
+
+// NewALSAURI captures from an ALSA device, e.g. hw:0,0
+func NewALSAURI(device, name string) *StreamURI {
+	var u = newStreamURI(StreamALSA, "", "/", name)
+	u.Query["device"] = device
+	return u
+}
+
+// NewMetaURI plays the first of the sources that is playing, in order
+func NewMetaURI(name string, sources ...string) *StreamURI {
+	return newStreamURI(StreamMeta, "", "/"+strings.Join(sources, "/"), name)
+}
+
+// NewJackURI captures from a JACK server
+func NewJackURI(name string) *StreamURI {
+	return newStreamURI(StreamJack, "", "/", name)
+}
+
+// Set a query parameter, an empty value removes it
+func (u *StreamURI) Set(key, value string) *StreamURI {
+	if u.Query == nil {
+		u.Query = make(map[string]string)
+	}
+	if value == "" {
+		delete(u.Query, key)
+	} else {
+		u.Query[key] = value
+	}
+	return u
+}
+
+func (u *StreamURI) Name() string {
+	return u.Query["name"]
+}
+
+func (u *StreamURI) WithSampleFormat(sf SampleFormat) *StreamURI {
+	return u.Set("sampleformat", sf.String())
+}
+
+// WithCodec sets the codec snapserver encodes the stream with: pcm, flac, ogg, opus or null
+func (u *StreamURI) WithCodec(codec string) *StreamURI {
+	return u.Set("codec", codec)
+}
+
+func (u *StreamURI) WithChunkMs(ms int) *StreamURI {
+	return u.Set("chunk_ms", strconv.Itoa(ms))
+}
+
+// WithControlScript makes snapserver run script to control the stream, params are passed as is
+func (u *StreamURI) WithControlScript(script, params string) *StreamURI {
+	return u.Set("controlscript", script).Set("controlscriptparams", params)
+}
+
+// Sources of a meta stream
+func (u *StreamURI) Sources() []string {
+	if u.Type != StreamMeta {
+		return nil
+	}
+	var path = strings.Trim(u.Path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// String encodes the URI for Stream.AddStream, query keys sorted with name first
+func (u *StreamURI) String() string {
+	var b strings.Builder
+	b.WriteString(string(u.Type))
+	b.WriteString("://")
+	b.WriteString(u.Host)
+
+	var path = (&url.URL{Path: u.Path}).EscapedPath()
+	if path != "" && !strings.HasPrefix(path, "/") {
+		b.WriteByte('/')
+	}
+	b.WriteString(path)
+
+	var keys = make([]string, 0, len(u.Query))
+	for k := range u.Query {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "name") != (keys[j] == "name") {
+			return keys[i] == "name"
+		}
+		return keys[i] < keys[j]
+	})
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(queryEscape(k))
+		b.WriteByte('=')
+		b.WriteString(queryEscape(u.Query[k]))
+	}
+
+	if u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(url.PathEscape(u.Fragment))
+	}
+	return b.String()
+}
+
+// queryEscape leaves the characters snapserver URIs commonly use readable, e.g. 48000:16:2.
+// snapserver doesn't decode + in the query, a space is %20 like in the path
+var queryUnescaper = strings.NewReplacer("%3A", ":", "%2F", "/", "%2C", ",", "+", "%20")
+
+func queryEscape(s string) string {
+	return queryUnescaper.Replace(url.QueryEscape(s))
+}
+
+func ParseStreamURI(raw string) (*StreamURI, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStreamURI, err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("%w: %q has no scheme", ErrInvalidStreamURI, raw)
+	}
+
+	var s = &StreamURI{
+		Type:     StreamType(u.Scheme),
+		Host:     u.Host,
+		Path:     u.Path,
+		Query:    make(map[string]string),
+		Fragment: u.Fragment,
+	}
+	for k, v := range u.Query() {
+		s.Query[k] = v[0]
+	}
+	return s, nil
+}
+
+// StreamURI of a stream reported by the server
+func (u URI) StreamURI() *StreamURI {
+	var s = &StreamURI{
+		Type:     StreamType(u.Scheme),
+		Host:     u.Host,
+		Path:     u.Path,
+		Query:    make(map[string]string, len(u.Query)),
+		Fragment: u.Fragment,
+	}
+	for k, v := range u.Query {
+		s.Query[k] = v
+	}
+	return s
+}
+
+// URI the way the server reports it
+func (u *StreamURI) URI() URI {
+	var uri = URI{
+		Fragment: u.Fragment,
+		Host:     u.Host,
+		Path:     u.Path,
+		Query:    make(map[string]string, len(u.Query)),
+		Raw:      u.String(),
+		Scheme:   string(u.Type),
+	}
+	for k, v := range u.Query {
+		uri.Query[k] = v
+	}
+	return uri
+}
+
+// queryKind checks a query value
+type queryKind func(v string) error
+
+func isInt(v string) error {
+	if _, err := strconv.Atoi(v); err != nil {
+		return fmt.Errorf("%q is not an integer", v)
+	}
+	return nil
+}
+
+func isFloat(v string) error {
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return fmt.Errorf("%q is not a number", v)
+	}
+	return nil
+}
+
+func isBool(v string) error {
+	if v != "true" && v != "false" {
+		return fmt.Errorf("%q is not true or false", v)
+	}
+	return nil
+}
+
+func isString(string) error { return nil }
+
+func oneOf(values ...string) queryKind {
+	return func(v string) error {
+		for _, ok := range values {
+			if v == ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", v, strings.Join(values, ", "))
+	}
+}
+
+func isSampleFormat(v string) error {
+	_, err := ParseSampleFormat(v)
+	return err
+}
+
+// Query keys every stream takes
+var commonQueryKeys = map[string]queryKind{
+	"name":                isString,
+	"sampleformat":        isSampleFormat,
+	"codec":               oneOf("pcm", "flac", "ogg", "opus", "null"),
+	"chunk_ms":            isInt,
+	"controlscript":       isString,
+	"controlscriptparams": isString,
+}
+
+// Query keys per stream type
+var streamQueryKeys = map[StreamType]map[string]queryKind{
+	StreamPipe: {
+		"mode":      oneOf("create", "read"),
+		"dryout_ms": isInt,
+	},
+	StreamLibrespot: {
+		"username":            isString,
+		"password":            isString,
+		"devicename":          isString,
+		"bitrate":             oneOf("96", "160", "320"),
+		"wd_timeout":          isInt,
+		"volume":              isInt,
+		"onevent":             isString,
+		"normalize":           isBool,
+		"autoplay":            isBool,
+		"cache":               isString,
+		"disable_audio_cache": isBool,
+		"killall":             isBool,
+		"params":              isString,
+	},
+	StreamAirplay: {
+		"devicename": isString,
+		"port":       isInt,
+		"password":   isString,
+		"params":     isString,
+	},
+	StreamFile: {},
+	StreamProcess: {
+		"params":     isString,
+		"wd_timeout": isInt,
+		"log_stderr": isBool,
+		"dryout_ms":  isInt,
+	},
+	StreamTCP: {
+		"mode":      oneOf("server", "client"),
+		"dryout_ms": isInt,
+	},
+	StreamALSA: {
+		"device":                    isString,
+		"send_silence":              isBool,
+		"idle_threshold":            isInt,
+		"silence_threshold_percent": isFloat,
+	},
+	StreamMeta: {},
+	StreamJack: {
+		"autoconnect":               isString,
+		"autoconnect_skip":          isInt,
+		"send_silence":              isBool,
+		"idle_threshold":            isInt,
+		"silence_threshold_percent": isFloat,
+	},
+}
+
+// Validate checks the type, that the stream has a name and the values of the query keys
+// snapserver knows for the type. Other keys are let through, e.g. for a newer snapserver
+func (u *StreamURI) Validate() error {
+	keys, ok := streamQueryKeys[u.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown stream type %q", ErrInvalidStreamURI, u.Type)
+	}
+	if u.Name() == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidStreamURI)
+	}
+
+	switch u.Type {
+	case StreamTCP:
+		if _, _, err := splitHostPort(u.Host); err != nil {
+			return fmt.Errorf("%w: tcp host: %v", ErrInvalidStreamURI, err)
+		}
+	case StreamMeta:
+		if len(u.Sources()) == 0 {
+			return fmt.Errorf("%w: meta stream has no sources", ErrInvalidStreamURI)
+		}
+	case StreamALSA:
+		if u.Query["device"] == "" {
+			return fmt.Errorf("%w: alsa stream has no device", ErrInvalidStreamURI)
+		}
+	case StreamJack:
+	default:
+		if strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("%w: %s stream has no path", ErrInvalidStreamURI, u.Type)
+		}
+	}
+
+	var errs []error
+	for k, v := range u.Query {
+		check, ok := commonQueryKeys[k]
+		if !ok {
+			check, ok = keys[k]
+		}
+		if !ok {
+			continue
+		}
+		if err := check(v); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidStreamURI, k, err))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// splitHostPort allows a missing port, snapserver defaults it to 4953
+func splitHostPort(host string) (string, string, error) {
+	if host == "" {
+		return "", "", errors.New("missing")
+	}
+	if !strings.Contains(host, ":") {
+		return host, "", nil
+	}
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", "", fmt.Errorf("port %q is not a number", port)
+	}
+	return h, port, nil
+}
@@ -0,0 +1,92 @@
+package snapcast
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStreamURIString(t *testing.T) {
+	var sf = SampleFormat{Rate: 48000, Bits: 16, Channels: 2}
+	for _, tc := range []struct {
+		uri  *StreamURI
+		want string
+	}{
+		{NewPipeURI("/tmp/snapfifo", "default").WithSampleFormat(sf).WithCodec("flac"), "pipe:///tmp/snapfifo?name=default&codec=flac&sampleformat=48000:16:2"},
+		{NewLibrespotURI("/usr/bin/librespot", "Spotify").Set("bitrate", "320"), "librespot:///usr/bin/librespot?name=Spotify&bitrate=320"},
+		{NewAirplayURI("/usr/bin/shairport-sync", "AirPlay").Set("port", "5000"), "airplay:///usr/bin/shairport-sync?name=AirPlay&port=5000"},
+		{NewFileURI("/music/Some wave file.wav", "file"), "file:///music/Some%20wave%20file.wav?name=file"},
+		{NewProcessURI("/usr/bin/mpv", "Radio").Set("params", "--no-video http://radio"), "process:///usr/bin/mpv?name=Radio&params=--no-video%20http://radio"},
+		{NewTCPURI("0.0.0.0:4953", "TCP").Set("mode", "server"), "tcp://0.0.0.0:4953?name=TCP&mode=server"},
+		{NewALSAURI("hw:0,0", "Line In"), "alsa:///?name=Line%20In&device=hw:0,0"},
+		{NewMetaURI("Mix", "Spotify", "AirPlay"), "meta:///Spotify/AirPlay?name=Mix"},
+		{NewJackURI("Jack").Set("autoconnect", "system:capture_"), "jack:///?name=Jack&autoconnect=system:capture_"},
+		{NewPipeURI("/tmp/x", "A+B").Set("future_key", "on"), "pipe:///tmp/x?name=A%2BB&future_key=on"},
+	} {
+		if got := tc.uri.String(); got != tc.want {
+			t.Errorf("got  %s\nwant %s", got, tc.want)
+		}
+		if err := tc.uri.Validate(); err != nil {
+			t.Errorf("%s: %v", tc.want, err)
+		}
+
+		parsed, err := ParseStreamURI(tc.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parsed, tc.uri) {
+			t.Errorf("parsed %+v, want %+v", parsed, tc.uri)
+		}
+	}
+
+	if sources := NewMetaURI("Mix", "Spotify", "AirPlay").Sources(); !reflect.DeepEqual(sources, []string{"Spotify", "AirPlay"}) {
+		t.Errorf("sources = %v", sources)
+	}
+}
+
+func TestStreamURIRoundTrip(t *testing.T) {
+	var stream Stream
+	if err := json.Unmarshal([]byte(`{
+		"id": "default",
+		"status": "idle",
+		"uri": {
+			"fragment": "",
+			"host": "",
+			"path": "/tmp/snapfifo",
+			"query": {"chunk_ms": "20", "codec": "flac", "name": "default", "sampleformat": "48000:16:2"},
+			"raw": "pipe:///tmp/snapfifo?name=default&chunk_ms=20&codec=flac&sampleformat=48000:16:2",
+			"scheme": "pipe"
+		}
+	}`), &stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var uri = stream.URI.StreamURI()
+	if uri.Type != StreamPipe || uri.Name() != "default" || uri.Query["chunk_ms"] != "20" {
+		t.Errorf("uri = %+v", uri)
+	}
+	if back := uri.URI(); !reflect.DeepEqual(back, stream.URI) {
+		t.Errorf("round trip\n%+v\nwant\n%+v", back, stream.URI)
+	}
+}
+
+func TestStreamURIValidate(t *testing.T) {
+	for name, uri := range map[string]*StreamURI{
+		"unknown type":     {Type: "spotify", Path: "/x", Query: map[string]string{"name": "x"}},
+		"no name":          NewPipeURI("/tmp/x", ""),
+		"no path":          NewPipeURI("", "x"),
+		"bad codec":        NewPipeURI("/tmp/x", "x").WithCodec("mp3"),
+		"bad format":       NewPipeURI("/tmp/x", "x").Set("sampleformat", "48000:16"),
+		"bad chunk":        NewPipeURI("/tmp/x", "x").Set("chunk_ms", "fast"),
+		"bad mode":         NewTCPURI("0.0.0.0:4953", "x").Set("mode", "create"),
+		"bad port":         NewTCPURI("0.0.0.0:http", "x"),
+		"no sources":       NewMetaURI("x"),
+		"no device":        NewALSAURI("", "x"),
+		"bad send_silence": NewALSAURI("hw:0", "x").Set("send_silence", "yes"),
+	} {
+		if err := uri.Validate(); !errors.Is(err, ErrInvalidStreamURI) {
+			t.Errorf("%s: expected ErrInvalidStreamURI, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package snapcasttest
+
+import (
+	"encoding/json"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+var (
+	errInvalidParams  = &snapcast.RPCError{Code: snapcast.CodeInvalidParams, Message: "Invalid params"}
+	errMethodNotFound = &snapcast.RPCError{Code: snapcast.CodeMethodNotFound, Message: "Method not found"}
+	errClientNotFound = &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: "Client not found"}
+	errGroupNotFound  = &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: "Group not found"}
+	errStreamNotFound = &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: "Stream not found"}
+)
+
+type notification struct {
+	method snapcast.NotificationMethod
+	params interface{}
+}
+
+// call runs a request against the model and sends the resulting notifications
+func (s *Server) call(from *session, method snapcast.RequestMethod, params json.RawMessage) (interface{}, *snapcast.RPCError) {
+	s.mu.Lock()
+	result, notifications, err := s.apply(method, params)
+	s.mu.Unlock()
+
+	for _, n := range notifications {
+		s.notify(from, n.method, n.params)
+
+		// Stream clients learn about their own volume and latency from ServerSettings
+		switch params := n.params.(type) {
+		case *snapcast.ClientOnVolumeChanged:
+			s.sendSettings(params.ID)
+		case *snapcast.ClientOnLatencyChanged:
+			s.sendSettings(params.ID)
+		}
+	}
+	return result, err
+}
+
+// apply must be called with s.mu held
+func (s *Server) apply(method snapcast.RequestMethod, params json.RawMessage) (interface{}, []notification, *snapcast.RPCError) {
+	var state = &s.state
+
+	switch method {
+	// --- Client
+	case snapcast.MethodClientGetStatus:
+		var p snapcast.ClientGetStatusRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		c := findClient(state, p.ID)
+		if c == nil {
+			return nil, nil, errClientNotFound
+		}
+		return &snapcast.ClientGetStatusResponse{Client: clone(*c)}, nil, nil
+
+	case snapcast.MethodClientSetVolume:
+		// Either field of the volume may be left out
+		var p struct {
+			ID     string `json:"id"`
+			Volume struct {
+				Muted   *bool `json:"muted"`
+				Percent *int  `json:"percent"`
+			} `json:"volume"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		if p.Volume.Percent != nil && (*p.Volume.Percent < 0 || *p.Volume.Percent > 100) {
+			return nil, nil, errInvalidParams
+		}
+		c := findClient(state, p.ID)
+		if c == nil {
+			return nil, nil, errClientNotFound
+		}
+		if p.Volume.Muted != nil {
+			c.Config.Volume.Muted = *p.Volume.Muted
+		}
+		if p.Volume.Percent != nil {
+			c.Config.Volume.Percent = *p.Volume.Percent
+		}
+		return &snapcast.ClientSetVolumeResponse{Volume: c.Config.Volume}, []notification{{
+			snapcast.MethodClientOnVolumeChanged, &snapcast.ClientOnVolumeChanged{ID: c.ID, Volume: c.Config.Volume},
+		}}, nil
+
+	case snapcast.MethodClientSetLatency:
+		var p snapcast.ClientSetLatencyRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		c := findClient(state, p.ID)
+		if c == nil {
+			return nil, nil, errClientNotFound
+		}
+		c.Config.Latency = p.Latency
+		return &snapcast.ClientSetLatencyResponse{Latency: p.Latency}, []notification{{
+			snapcast.MethodClientOnLatencyChanged, &snapcast.ClientOnLatencyChanged{ID: c.ID, Latency: p.Latency},
+		}}, nil
+
+	case snapcast.MethodClientSetName:
+		var p snapcast.ClientSetNameRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		c := findClient(state, p.ID)
+		if c == nil {
+			return nil, nil, errClientNotFound
+		}
+		c.Config.Name = p.Name
+		return &snapcast.ClientSetNameResponse{Name: p.Name}, []notification{{
+			snapcast.MethodClientOnNameChanged, &snapcast.ClientOnNameChanged{ID: c.ID, Name: p.Name},
+		}}, nil
+
+	// --- Group
+	case snapcast.MethodGroupGetStatus:
+		var p snapcast.GroupGetStatusRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		g := findGroup(state, p.ID)
+		if g == nil {
+			return nil, nil, errGroupNotFound
+		}
+		return &snapcast.GroupGetStatusResponse{Group: clone(*g)}, nil, nil
+
+	case snapcast.MethodGroupSetMute:
+		var p struct {
+			ID   string `json:"id"`
+			Mute *bool  `json:"mute"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Mute == nil {
+			return nil, nil, errInvalidParams
+		}
+		g := findGroup(state, p.ID)
+		if g == nil {
+			return nil, nil, errGroupNotFound
+		}
+		g.Muted = *p.Mute
+		return &snapcast.GroupSetMuteResponse{Muted: g.Muted}, []notification{{
+			snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: g.ID, Mute: g.Muted},
+		}}, nil
+
+	case snapcast.MethodGroupSetStream:
+		var p snapcast.GroupSetStreamRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		g := findGroup(state, p.ID)
+		if g == nil {
+			return nil, nil, errGroupNotFound
+		}
+		if findStream(state, p.StreamID) == nil {
+			return nil, nil, errStreamNotFound
+		}
+		g.StreamID = p.StreamID
+		return &snapcast.GroupSetStreamResponse{StreamID: p.StreamID}, []notification{{
+			snapcast.MethodGroupOnStreamChanged, &snapcast.GroupOnStreamChanged{ID: g.ID, StreamId: p.StreamID},
+		}}, nil
+
+	case snapcast.MethodGroupSetClients:
+		var p snapcast.GroupSetClientsRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		return s.setGroupClients(p)
+
+	case snapcast.MethodGroupSetName:
+		var p snapcast.GroupSetNameRequest
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		g := findGroup(state, p.ID)
+		if g == nil {
+			return nil, nil, errGroupNotFound
+		}
+		g.Name = p.Name
+		return &snapcast.GroupSetNameResponse{Name: p.Name}, []notification{{
+			snapcast.MethodGroupOnNameChanged, &snapcast.GroupOnNameChanged{ID: g.ID, Name: p.Name},
+		}}, nil
+
+	// --- Server
+	case snapcast.MethodServerGetRPCVersion:
+		return &snapcast.ServerGetRPCVersionResponse{Major: 2, Minor: 0, Patch: 0}, nil, nil
+
+	case snapcast.MethodServerGetStatus:
+		return &snapcast.ServerGetStatusResponse{Server: clone(*state)}, nil, nil
+
+	case snapcast.MethodServerDeleteClient:
+		var p snapcast.ServerDeleteClient
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		if _, ok := removeClient(state, p.ID); !ok {
+			return nil, nil, errClientNotFound
+		}
+		return &snapcast.ServerDeleteClientResponse{Server: clone(*state)}, s.serverUpdate(), nil
+
+	// --- Stream
+	case snapcast.MethodStreamAddStream:
+		var p snapcast.StreamAddStream
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		stream, ok := parseStream(p.StreamUri)
+		if !ok {
+			return nil, nil, errInvalidParams
+		}
+		if findStream(state, stream.ID) != nil {
+			return nil, nil, &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: "Stream already exists"}
+		}
+		state.Streams = append(state.Streams, stream)
+		return &snapcast.StreamAddStreamResponse{StreamId: stream.ID}, s.serverUpdate(), nil
+
+	case snapcast.MethodStreamRemoveStream:
+		var p snapcast.StreamRemoveStream
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		for si := range state.Streams {
+			if state.Streams[si].ID == p.ID {
+				state.Streams = append(state.Streams[:si], state.Streams[si+1:]...)
+				return &snapcast.StreamRemoveStreamResponse{StreamId: p.ID}, s.serverUpdate(), nil
+			}
+		}
+		return nil, nil, errStreamNotFound
+
+	case snapcast.MethodStreamControl:
+		var p struct {
+			ID      string                 `json:"id"`
+			Command snapcast.StreamCommand `json:"command"`
+			Params  struct {
+				Offset   *float64 `json:"offset"`
+				Position *float64 `json:"position"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		st := findStream(state, p.ID)
+		if st == nil {
+			return nil, nil, errStreamNotFound
+		}
+		if err := control(st, p.Command, p.Params.Offset, p.Params.Position); err != nil {
+			return nil, nil, err
+		}
+		return "ok", []notification{{
+			snapcast.MethodStreamOnProperties, &snapcast.StreamOnProperties{ID: st.ID, Properties: clone(*st.Properties)},
+		}}, nil
+
+	case snapcast.MethodStreamSetProperty:
+		var p struct {
+			ID       string          `json:"id"`
+			Property string          `json:"property"`
+			Value    json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, nil, errInvalidParams
+		}
+		st := findStream(state, p.ID)
+		if st == nil {
+			return nil, nil, errStreamNotFound
+		}
+		if err := setProperty(st, p.Property, p.Value); err != nil {
+			return nil, nil, err
+		}
+		return "ok", []notification{{
+			snapcast.MethodStreamOnProperties, &snapcast.StreamOnProperties{ID: st.ID, Properties: clone(*st.Properties)},
+		}}, nil
+	}
+
+	return nil, nil, errMethodNotFound
+}
+
+func (s *Server) serverUpdate() []notification {
+	return []notification{{snapcast.MethodServerOnUpdate, &snapcast.ServerOnUpdate{Server: clone(s.state)}}}
+}
+
+// setGroupClients moves the listed clients into the group,
+// clients dropped from the group get a group of their own like snapserver does
+func (s *Server) setGroupClients(p snapcast.GroupSetClientsRequest) (interface{}, []notification, *snapcast.RPCError) {
+	var state = &s.state
+
+	g := findGroup(state, p.ID)
+	if g == nil {
+		return nil, nil, errGroupNotFound
+	}
+	for _, id := range p.Clients {
+		if findClient(state, id) == nil {
+			return nil, nil, errClientNotFound
+		}
+	}
+
+	var (
+		groupID  = g.ID
+		streamID = g.StreamID
+		wanted   = make(map[string]bool, len(p.Clients))
+		dropped  []snapcast.Client
+		moved    []snapcast.Client
+	)
+	for _, id := range p.Clients {
+		wanted[id] = true
+	}
+	for _, c := range g.Clients {
+		if !wanted[c.ID] {
+			dropped = append(dropped, c)
+		}
+	}
+	for _, c := range dropped {
+		removeClient(state, c.ID)
+	}
+	for _, id := range p.Clients {
+		c, _ := removeClient(state, id)
+		moved = append(moved, c)
+	}
+
+	// The group may have been dropped while it was empty
+	if g = findGroup(state, groupID); g == nil {
+		state.Groups = append(state.Groups, snapcast.Group{ID: groupID, StreamID: streamID})
+		g = &state.Groups[len(state.Groups)-1]
+	}
+	g.Clients = moved
+	if len(g.Clients) == 0 {
+		removeGroup(state, groupID)
+	}
+	for _, c := range dropped {
+		state.Groups = append(state.Groups, newGroup(state, c))
+	}
+
+	return &snapcast.GroupSetClientsResponse{Server: clone(*state)}, s.serverUpdate(), nil
+}
+
+func removeGroup(state *snapcast.Server, id string) {
+	for gi := range state.Groups {
+		if state.Groups[gi].ID == id {
+			state.Groups = append(state.Groups[:gi], state.Groups[gi+1:]...)
+			return
+		}
+	}
+}
+
+// parseStream builds an idle stream from a stream URI, the name query parameter is its ID
+func parseStream(raw string) (snapcast.Stream, bool) {
+	uri, err := snapcast.ParseStreamURI(raw)
+	if err != nil {
+		return snapcast.Stream{}, false
+	}
+
+	var stream = snapcast.Stream{Status: snapcast.StreamIdle, URI: uri.URI()}
+	stream.URI.Raw = raw
+	stream.ID = stream.URI.Query["name"]
+	if stream.ID == "" {
+		return snapcast.Stream{}, false
+	}
+	stream.Properties = &snapcast.Properties{}
+	return stream, true
+}
+
+func control(st *snapcast.Stream, command snapcast.StreamCommand, offset, position *float64) *snapcast.RPCError {
+	var props = st.Properties
+	if props == nil || !props.CanControl {
+		return &snapcast.RPCError{Code: snapcast.CodeCanControlIsFalse, Message: "Stream property canControl is false"}
+	}
+
+	switch command {
+	case snapcast.StreamCommandNext:
+		if !props.CanGoNext {
+			return &snapcast.RPCError{Code: snapcast.CodeCanGoNextIsFalse, Message: "Stream property canGoNext is false"}
+		}
+		props.Position = 0
+	case snapcast.StreamCommandPrevious:
+		if !props.CanGoPrevious {
+			return &snapcast.RPCError{Code: snapcast.CodeCanGoPreviousIsFalse, Message: "Stream property canGoPrevious is false"}
+		}
+		props.Position = 0
+	case snapcast.StreamCommandPlay:
+		if !props.CanPlay {
+			return &snapcast.RPCError{Code: snapcast.CodeCanPlayIsFalse, Message: "Stream property canPlay is false"}
+		}
+		props.PlaybackStatus = snapcast.PlaybackPlaying
+	case snapcast.StreamCommandPause:
+		if !props.CanPause {
+			return &snapcast.RPCError{Code: snapcast.CodeCanPauseIsFalse, Message: "Stream property canPause is false"}
+		}
+		props.PlaybackStatus = snapcast.PlaybackPaused
+	case snapcast.StreamCommandPlayPause:
+		if props.PlaybackStatus == snapcast.PlaybackPlaying {
+			if !props.CanPause {
+				return &snapcast.RPCError{Code: snapcast.CodeCanPauseIsFalse, Message: "Stream property canPause is false"}
+			}
+			props.PlaybackStatus = snapcast.PlaybackPaused
+		} else {
+			if !props.CanPlay {
+				return &snapcast.RPCError{Code: snapcast.CodeCanPlayIsFalse, Message: "Stream property canPlay is false"}
+			}
+			props.PlaybackStatus = snapcast.PlaybackPlaying
+		}
+	case snapcast.StreamCommandStop:
+		props.PlaybackStatus = snapcast.PlaybackStopped
+		props.Position = 0
+	case snapcast.StreamCommandSeek:
+		if !props.CanSeek {
+			return &snapcast.RPCError{Code: snapcast.CodeCanSeekIsFalse, Message: "Stream property canSeek is false"}
+		}
+		if offset == nil {
+			return errInvalidParams
+		}
+		props.Position = clampPosition(props, props.Position+*offset)
+	case snapcast.StreamCommandSetPosition:
+		if !props.CanSeek {
+			return &snapcast.RPCError{Code: snapcast.CodeCanSeekIsFalse, Message: "Stream property canSeek is false"}
+		}
+		if position == nil {
+			return errInvalidParams
+		}
+		props.Position = clampPosition(props, *position)
+	default:
+		return errInvalidParams
+	}
+
+	if props.PlaybackStatus == snapcast.PlaybackPlaying {
+		st.Status = snapcast.StreamPlaying
+	} else {
+		st.Status = snapcast.StreamIdle
+	}
+	return nil
+}
+
+func clampPosition(props *snapcast.Properties, position float64) float64 {
+	if position < 0 {
+		return 0
+	}
+	if props.Metadata != nil && props.Metadata.Duration > 0 && position > props.Metadata.Duration {
+		return props.Metadata.Duration
+	}
+	return position
+}
+
+func setProperty(st *snapcast.Stream, property string, value json.RawMessage) *snapcast.RPCError {
+	var props = st.Properties
+	if props == nil || !props.CanControl {
+		return &snapcast.RPCError{Code: snapcast.CodeCanControlIsFalse, Message: "Stream property canControl is false"}
+	}
+
+	var err error
+	switch property {
+	case "loopStatus":
+		var v snapcast.LoopStatus
+		if err = json.Unmarshal(value, &v); err == nil {
+			switch v {
+			case snapcast.LoopNone, snapcast.LoopTrack, snapcast.LoopPlaylist:
+				props.LoopStatus = v
+			default:
+				return errInvalidParams
+			}
+		}
+	case "shuffle":
+		err = json.Unmarshal(value, &props.Shuffle)
+	case "volume":
+		var v int
+		if err = json.Unmarshal(value, &v); err == nil {
+			if v < 0 || v > 100 {
+				return errInvalidParams
+			}
+			props.Volume = v
+		}
+	case "mute":
+		err = json.Unmarshal(value, &props.Mute)
+	case "rate":
+		var v float64
+		if err = json.Unmarshal(value, &v); err == nil {
+			if v <= 0 {
+				return errInvalidParams
+			}
+			props.Rate = v
+		}
+	default:
+		return errInvalidParams
+	}
+	if err != nil {
+		return errInvalidParams
+	}
+
+	return nil
+}
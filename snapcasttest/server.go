@@ -0,0 +1,377 @@
+// Package snapcasttest provides an in-process snapserver for testing code built on snapclient
+package snapcasttest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+	"github.com/coder/websocket"
+)
+
+// Server speaks the JSON-RPC control API on /jsonrpc over HTTP and WebSocket,
+// and as newline delimited JSON over TCP
+type Server struct {
+	HTTP *httptest.Server
+
+	tcp      net.Listener
+	stream   net.Listener
+	mu       sync.Mutex
+	state    snapcast.Server
+	sessions map[*session]struct{}
+	players  map[*player]struct{}
+	header   *snapproto.CodecHeader
+	requests []snapcast.Request
+	wg       sync.WaitGroup
+}
+
+// session is a WebSocket or TCP control connection that receives notifications
+type session struct {
+	mu    sync.Mutex
+	write func(msg []byte) error
+	close func()
+}
+
+func (s *session) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(msg)
+}
+
+// NewServer starts a server holding a copy of state, DefaultState is used if nil
+func NewServer(state *snapcast.Server) *Server {
+	if state == nil {
+		state = DefaultState()
+	}
+
+	var s = &Server{
+		state:    clone(*state),
+		sessions: make(map[*session]struct{}),
+		players:  make(map[*player]struct{}),
+		header:   snapproto.PCMHeader(DefaultSampleFormat),
+	}
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/jsonrpc", s.serveHTTP)
+	s.HTTP = httptest.NewServer(mux)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("snapcasttest: failed to listen on tcp: " + err.Error())
+	}
+	s.tcp = tcp
+	s.wg.Add(1)
+	go s.acceptTCP()
+
+	stream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("snapcasttest: failed to listen on tcp: " + err.Error())
+	}
+	s.stream = stream
+	s.wg.Add(1)
+	go s.acceptStream()
+
+	return s
+}
+
+// Host of the HTTP and WebSocket endpoint, for snapclient.Options.Host
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.HTTP.URL, "http://")
+}
+
+// TCPHost of the raw TCP endpoint, for snapclient.TransportTCP
+func (s *Server) TCPHost() string {
+	return s.tcp.Addr().String()
+}
+
+func (s *Server) Close() {
+	s.tcp.Close()
+	s.stream.Close()
+	s.CloseConnections()
+	s.HTTP.Close()
+	s.wg.Wait()
+}
+
+// CloseConnections drops every WebSocket, TCP and stream connection, e.g. to test reconnecting
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	var sessions = make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	var players = make([]*player, 0, len(s.players))
+	for p := range s.players {
+		players = append(players, p)
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.close()
+	}
+	for _, p := range players {
+		p.conn.Close()
+	}
+}
+
+// State returns a copy of the current model
+func (s *Server) State() snapcast.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return clone(s.state)
+}
+
+// Update mutates the model without sending notifications
+func (s *Server) Update(fn func(state *snapcast.Server)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []snapcast.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]snapcast.Request(nil), s.requests...)
+}
+
+// Notify sends a notification to every connected session
+func (s *Server) Notify(method snapcast.NotificationMethod, params interface{}) {
+	s.notify(nil, method, params)
+}
+
+// notify sends to every session but the one that caused it, like snapserver does
+func (s *Server) notify(from *session, method snapcast.NotificationMethod, params interface{}) {
+	msg, err := json.Marshal(&snapcast.Notification{
+		JsonRPC: "2.0",
+		Method:  &method,
+		Params:  params,
+	})
+	if err != nil {
+		panic("snapcasttest: failed to marshal notification: " + err.Error())
+	}
+
+	s.mu.Lock()
+	var sessions = make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		if sess != from {
+			sessions = append(sessions, sess)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.send(msg)
+	}
+}
+
+func (s *Server) addSession(sess *session) {
+	s.mu.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *Server) removeSession(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		s.serveWebSocket(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if res := s.handleMessage(nil, body); res != nil {
+		w.Write(res)
+	}
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Registered before the handshake completes so no notification sent after the client dialed is missed
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		ready       = make(chan struct{})
+		ws          *websocket.Conn
+		sess        = &session{
+			write: func(msg []byte) error {
+				<-ready
+				if ws == nil {
+					return net.ErrClosed
+				}
+				return ws.Write(ctx, websocket.MessageText, msg)
+			},
+			close: func() {
+				<-ready
+				if ws != nil {
+					ws.CloseNow()
+				}
+			},
+		}
+	)
+	defer cancel()
+
+	s.addSession(sess)
+	defer s.removeSession(sess)
+
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		ws = nil
+		close(ready)
+		return
+	}
+	ws.SetReadLimit(-1)
+	close(ready)
+
+	for {
+		_, raw, err := ws.Read(ctx)
+		if err != nil {
+			return
+		}
+		if res := s.handleMessage(sess, raw); res != nil {
+			sess.send(res)
+		}
+	}
+}
+
+func (s *Server) acceptTCP() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.serveTCP(nc)
+	}
+}
+
+func (s *Server) serveTCP(nc net.Conn) {
+	defer s.wg.Done()
+	defer nc.Close()
+
+	var sess = &session{
+		write: func(msg []byte) error {
+			_, err := nc.Write(append(msg, '\r', '\n'))
+			return err
+		},
+		close: func() { nc.Close() },
+	}
+	s.addSession(sess)
+	defer s.removeSession(sess)
+
+	var scanner = bufio.NewScanner(nc)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var line = bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if res := s.handleMessage(sess, line); res != nil {
+			sess.send(res)
+		}
+	}
+}
+
+// handleMessage answers a single request or a batch, nil if there is nothing to answer
+func (s *Server) handleMessage(from *session, raw []byte) []byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+			return marshal(errorResponse(nil, snapcast.CodeParseError, "Parse error"))
+		}
+
+		var responses []*snapcast.Response
+		for _, item := range batch {
+			if res := s.handleRequest(from, item); res != nil {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return marshal(responses)
+	}
+
+	if res := s.handleRequest(from, raw); res != nil {
+		return marshal(res)
+	}
+	return nil
+}
+
+func (s *Server) handleRequest(from *session, raw []byte) *snapcast.Response {
+	var req snapcast.Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, snapcast.CodeParseError, "Parse error")
+	}
+	if req.Method == nil {
+		return errorResponse(req.ID, snapcast.CodeInvalidRequest, "Invalid request")
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return errorResponse(req.ID, snapcast.CodeInvalidParams, "Invalid params")
+	}
+
+	result, rpcErr := s.call(from, *req.Method, params)
+
+	// Requests without an ID are notifications and get no response
+	if req.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return errorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+	}
+	return &snapcast.Response{ID: req.ID, JsonRPC: "2.0", Result: result}
+}
+
+func errorResponse(id *int, code int, message string) *snapcast.Response {
+	return &snapcast.Response{
+		ID:      id,
+		JsonRPC: "2.0",
+		Error:   &snapcast.Error{Code: code, Message: message},
+	}
+}
+
+func marshal(v interface{}) []byte {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic("snapcasttest: failed to marshal response: " + err.Error())
+	}
+	return raw
+}
+
+// clone deep copies through JSON so the model never shares memory with callers
+func clone[T any](v T) T {
+	var out T
+	if err := json.Unmarshal(marshal(v), &out); err != nil {
+		panic("snapcasttest: failed to clone: " + err.Error())
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package snapcasttest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+func TestGroupSetClients(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		c       = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		state   = srv.State()
+		groupID = state.Groups[0].ID
+		bedroom = state.Groups[1].Clients[0].ID
+		kitchen = state.Groups[0].Clients[1].ID
+	)
+
+	// Move the bedroom in and the kitchen out
+	res, err := c.GroupSetClients(ctx, groupID, []string{state.Groups[0].Clients[0].ID, bedroom})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state = srv.State()
+	if !reflect.DeepEqual(res.Server, state) {
+		t.Errorf("answered with %+v, want the whole server", res.Server)
+	}
+	if len(state.Groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(state.Groups))
+	}
+	if g := state.Groups[0]; g.ID != groupID || len(g.Clients) != 2 || g.Clients[1].ID != bedroom {
+		t.Errorf("unexpected group %+v", g)
+	}
+	if g := state.Groups[1]; len(g.Clients) != 1 || g.Clients[0].ID != kitchen {
+		t.Errorf("kitchen should be alone in a new group, got %+v", g)
+	}
+}
+
+func TestGroupSetMute(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		c       = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		groupID = srv.State().Groups[0].ID
+	)
+
+	res, err := c.GroupSetMute(ctx, groupID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Muted || !srv.State().Groups[0].Muted {
+		t.Errorf("group not muted, answered %+v", res)
+	}
+
+	// Like snapserver, only "mute" is accepted
+	raw, err := c.Send(ctx, snapcast.MethodGroupSetMute, map[string]interface{}{"id": groupID, "muted": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Error == nil || raw.Error.Code != snapcast.CodeInvalidParams {
+		t.Errorf("expected invalid params for muted, got %+v", raw.Error)
+	}
+}
+
+func TestStreamControl(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var c = snapclient.New(&snapclient.Options{Host: srv.Host()})
+
+	if _, err := c.StreamControl(ctx, "default", snapcast.StreamCommandSetPosition, map[string]float64{"position": 42}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StreamControl(ctx, "default", snapcast.StreamCommandPlay, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st := srv.State().Streams[0]
+	if st.Properties.Position != 42 || st.Properties.PlaybackStatus != snapcast.PlaybackPlaying || !st.Status.IsPlaying() {
+		t.Errorf("unexpected stream %+v", st.Properties)
+	}
+
+	srv.SetStreamProperties("default", snapcast.Properties{CanControl: true})
+	if _, err := c.StreamControl(ctx, "default", snapcast.StreamCommandNext, nil); !errors.Is(err, snapcast.ErrCanGoNextIsFalse) {
+		t.Errorf("expected ErrCanGoNextIsFalse, got %v", err)
+	}
+}
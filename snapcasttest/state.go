@@ -0,0 +1,202 @@
+package snapcasttest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Two groups on one pipe stream, three clients of which one is disconnected
+const defaultState = `{
+	"groups": [
+		{
+			"id": "4dcc4e3b-c699-a04b-7f0c-8260d23c43e1",
+			"muted": false,
+			"name": "",
+			"stream_id": "default",
+			"clients": [
+				{
+					"id": "00:21:6a:7d:74:fc",
+					"connected": true,
+					"config": {"instance": 1, "latency": 0, "name": "Living Room", "volume": {"muted": false, "percent": 74}},
+					"host": {"arch": "x86_64", "ip": "192.168.0.54", "mac": "00:21:6a:7d:74:fc", "name": "living-room", "os": "Debian GNU/Linux 10 (buster)"},
+					"lastSeen": {"sec": 1700000000, "usec": 517575},
+					"snapclient": {"name": "Snapclient", "protocolVersion": 2, "version": "0.29.0"}
+				},
+				{
+					"id": "b8:27:eb:5f:1e:77",
+					"connected": true,
+					"config": {"instance": 1, "latency": 20, "name": "Kitchen", "volume": {"muted": false, "percent": 48}},
+					"host": {"arch": "armv7l", "ip": "192.168.0.61", "mac": "b8:27:eb:5f:1e:77", "name": "kitchen", "os": "Raspbian GNU/Linux 11 (bullseye)"},
+					"lastSeen": {"sec": 1700000000, "usec": 602211},
+					"snapclient": {"name": "Snapclient", "protocolVersion": 2, "version": "0.29.0"}
+				}
+			]
+		},
+		{
+			"id": "c4b3a1f2-5e6d-4c7b-8a9f-0e1d2c3b4a59",
+			"muted": false,
+			"name": "Bedroom",
+			"stream_id": "default",
+			"clients": [
+				{
+					"id": "dc:a6:32:0b:44:12",
+					"connected": false,
+					"config": {"instance": 1, "latency": 0, "name": "", "volume": {"muted": true, "percent": 30}},
+					"host": {"arch": "aarch64", "ip": "192.168.0.77", "mac": "dc:a6:32:0b:44:12", "name": "bedroom", "os": "Raspbian GNU/Linux 12 (bookworm)"},
+					"lastSeen": {"sec": 1699990000, "usec": 1234},
+					"snapclient": {"name": "Snapclient", "protocolVersion": 2, "version": "0.28.0"}
+				}
+			]
+		}
+	],
+	"host": {"arch": "x86_64", "ip": "", "mac": "", "name": "snapserver", "os": "Debian GNU/Linux 12 (bookworm)"},
+	"snapserver": {"controlProtocolVersion": 1, "name": "Snapserver", "protocolVersion": 1, "version": "0.29.0"},
+	"streams": [
+		{
+			"id": "default",
+			"status": "idle",
+			"uri": {
+				"fragment": "",
+				"host": "",
+				"path": "/tmp/snapfifo",
+				"query": {"chunk_ms": "20", "codec": "flac", "name": "default", "sampleformat": "48000:16:2"},
+				"raw": "pipe:///tmp/snapfifo?chunk_ms=20&codec=flac&name=default&sampleformat=48000:16:2",
+				"scheme": "pipe"
+			},
+			"properties": {
+				"playbackStatus": "stopped",
+				"loopStatus": "none",
+				"volume": 100,
+				"rate": 1,
+				"canGoNext": true,
+				"canGoPrevious": true,
+				"canPlay": true,
+				"canPause": true,
+				"canSeek": true,
+				"canControl": true,
+				"metadata": {"title": "Test Track", "artist": ["Test Artist"], "duration": 180}
+			}
+		}
+	]
+}`
+
+// DefaultState is a small install with two groups, three clients and one stream
+func DefaultState() *snapcast.Server {
+	var state = &snapcast.Server{}
+	if err := json.Unmarshal([]byte(defaultState), state); err != nil {
+		panic("snapcasttest: invalid default state: " + err.Error())
+	}
+	return state
+}
+
+// ConnectClient adds the client to the group, or to a new group if groupID isn't found,
+// and sends Client.OnConnect
+func (s *Server) ConnectClient(client snapcast.Client, groupID string) {
+	var now = time.Now()
+	client.Connected = true
+	client.LastSeen.Sec = int(now.Unix())
+	client.LastSeen.USec = now.Nanosecond() / 1000
+
+	s.mu.Lock()
+	removeClient(&s.state, client.ID)
+	if g := findGroup(&s.state, groupID); g != nil {
+		g.Clients = append(g.Clients, client)
+	} else {
+		s.state.Groups = append(s.state.Groups, newGroup(&s.state, client))
+	}
+	s.mu.Unlock()
+
+	s.Notify(snapcast.MethodClientOnConnect, &snapcast.ClientOnConnect{ID: client.ID, Client: &client})
+}
+
+// DisconnectClient marks the client disconnected and sends Client.OnDisconnect
+func (s *Server) DisconnectClient(id string) {
+	s.mu.Lock()
+	c := findClient(&s.state, id)
+	if c == nil {
+		s.mu.Unlock()
+		return
+	}
+	c.Connected = false
+	var client = clone(*c)
+	s.mu.Unlock()
+
+	s.Notify(snapcast.MethodClientOnDisconnect, &snapcast.ClientOnDisconnect{ID: id, Client: &client})
+}
+
+// SetStreamProperties replaces the properties of a stream, e.g. as its control script would,
+// and sends Stream.OnProperties
+func (s *Server) SetStreamProperties(id string, props snapcast.Properties) {
+	s.mu.Lock()
+	st := findStream(&s.state, id)
+	if st == nil {
+		s.mu.Unlock()
+		return
+	}
+	st.Properties = &props
+	s.mu.Unlock()
+
+	s.Notify(snapcast.MethodStreamOnProperties, &snapcast.StreamOnProperties{ID: id, Properties: props})
+}
+
+func findClient(state *snapcast.Server, id string) *snapcast.Client {
+	for gi := range state.Groups {
+		for ci := range state.Groups[gi].Clients {
+			if c := &state.Groups[gi].Clients[ci]; c.ID == id {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func findGroup(state *snapcast.Server, id string) *snapcast.Group {
+	for gi := range state.Groups {
+		if state.Groups[gi].ID == id {
+			return &state.Groups[gi]
+		}
+	}
+	return nil
+}
+
+func findStream(state *snapcast.Server, id string) *snapcast.Stream {
+	for si := range state.Streams {
+		if state.Streams[si].ID == id {
+			return &state.Streams[si]
+		}
+	}
+	return nil
+}
+
+// removeClient takes the client out of its group and drops the group if it ends up empty
+func removeClient(state *snapcast.Server, id string) (snapcast.Client, bool) {
+	for gi := range state.Groups {
+		var g = &state.Groups[gi]
+		for ci, c := range g.Clients {
+			if c.ID != id {
+				continue
+			}
+			g.Clients = append(g.Clients[:ci], g.Clients[ci+1:]...)
+			if len(g.Clients) == 0 {
+				state.Groups = append(state.Groups[:gi], state.Groups[gi+1:]...)
+			}
+			return c, true
+		}
+	}
+	return snapcast.Client{}, false
+}
+
+// newGroup creates a group for a single client playing the first stream
+func newGroup(state *snapcast.Server, client snapcast.Client) snapcast.Group {
+	var streamID string
+	if len(state.Streams) > 0 {
+		streamID = state.Streams[0].ID
+	}
+	return snapcast.Group{
+		ID:       "group-" + client.ID,
+		StreamID: streamID,
+		Clients:  []snapcast.Client{client},
+	}
+}
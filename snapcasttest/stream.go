@@ -0,0 +1,209 @@
+package snapcasttest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+)
+
+// DefaultSampleFormat of the pcm codec header sent to stream clients
+var DefaultSampleFormat = snapcast.SampleFormat{Rate: 48000, Bits: 16, Channels: 2}
+
+// DefaultBufferMs is the end to end latency sent in ServerSettings, like snapserver's default
+const DefaultBufferMs = 1000
+
+// player is a connection on the binary stream endpoint
+type player struct {
+	conn net.Conn
+	mu   sync.Mutex
+	id   string
+}
+
+func (p *player) send(msg snapproto.Message, h snapproto.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h.Sent = snapproto.TimevalFromTime(time.Now())
+	return snapproto.WriteMessage(p.conn, h, msg)
+}
+
+// StreamHost of the binary stream endpoint, what snapserver serves on port 1704
+func (s *Server) StreamHost() string {
+	return s.stream.Addr().String()
+}
+
+// SetCodecHeader replaces the codec header, connected stream clients receive it right away
+func (s *Server) SetCodecHeader(h *snapproto.CodecHeader) {
+	s.mu.Lock()
+	s.header = h
+	var players = s.playerSnapshot()
+	s.mu.Unlock()
+
+	for _, p := range players {
+		p.send(h, snapproto.Header{})
+	}
+}
+
+// WriteChunk sends encoded audio captured at timestamp to every stream client
+func (s *Server) WriteChunk(timestamp time.Time, payload []byte) {
+	var chunk = &snapproto.WireChunk{
+		Timestamp: snapproto.TimevalFromTime(timestamp),
+		Payload:   payload,
+	}
+
+	s.mu.Lock()
+	var players = s.playerSnapshot()
+	s.mu.Unlock()
+
+	for _, p := range players {
+		p.send(chunk, snapproto.Header{})
+	}
+}
+
+// playerSnapshot must be called with s.mu held
+func (s *Server) playerSnapshot() []*player {
+	var players = make([]*player, 0, len(s.players))
+	for p := range s.players {
+		players = append(players, p)
+	}
+	return players
+}
+
+func (s *Server) acceptStream() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.stream.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.serveStream(nc)
+	}
+}
+
+func (s *Server) serveStream(nc net.Conn) {
+	defer s.wg.Done()
+	defer nc.Close()
+
+	_, msg, err := snapproto.ReadMessage(nc)
+	if err != nil {
+		return
+	}
+	hello, ok := msg.(*snapproto.Hello)
+	if !ok {
+		return
+	}
+
+	var p = &player{conn: nc, id: hello.ID}
+	if p.id == "" {
+		p.id = hello.MAC
+	}
+
+	// Known clients keep their config and group, new ones get a group of their own
+	s.mu.Lock()
+	var (
+		client  snapcast.Client
+		groupID string
+	)
+	if c := findClient(&s.state, p.id); c != nil {
+		client = clone(*c)
+		groupID = groupOfClient(&s.state, p.id)
+	} else {
+		client.ID = p.id
+		client.Config.Instance = hello.Instance
+		client.Config.Volume.Percent = 100
+	}
+	s.mu.Unlock()
+
+	client.Host.Arch = hello.Arch
+	client.Host.MAC = hello.MAC
+	client.Host.Name = hello.HostName
+	client.Host.OS = hello.OS
+	if addr, ok := nc.RemoteAddr().(*net.TCPAddr); ok {
+		client.Host.IP = addr.IP.String()
+	}
+	client.Snapclient.Name = hello.ClientName
+	client.Snapclient.ProtocolVersion = hello.SnapStreamProtocolVersion
+	client.Snapclient.Version = hello.Version
+	s.ConnectClient(client, groupID)
+
+	s.mu.Lock()
+	var header = s.header
+	s.players[p] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.players, p)
+		s.mu.Unlock()
+		s.DisconnectClient(p.id)
+	}()
+
+	s.sendSettings(p.id)
+	p.send(header, snapproto.Header{})
+
+	for {
+		h, msg, err := snapproto.ReadMessage(nc)
+		if err != nil {
+			return
+		}
+		h.Received = snapproto.TimevalFromTime(time.Now())
+
+		switch msg := msg.(type) {
+		case *snapproto.Time:
+			// Latency is how long the request took to get here including the clock offset
+			var latency = h.Received.Time().Sub(h.Sent.Time())
+			p.send(&snapproto.Time{Latency: snapproto.TimevalFromDuration(latency)}, snapproto.Header{
+				RefersTo: h.ID,
+				Received: h.Received,
+			})
+		case *snapproto.ClientInfo:
+			var volume = snapcast.Volume{Muted: msg.Muted, Percent: msg.Volume}
+			s.mu.Lock()
+			if c := findClient(&s.state, p.id); c != nil {
+				c.Config.Volume = volume
+			}
+			s.mu.Unlock()
+			s.Notify(snapcast.MethodClientOnVolumeChanged, &snapcast.ClientOnVolumeChanged{ID: p.id, Volume: volume})
+		}
+	}
+}
+
+// sendSettings sends the client's config to its stream connection, if it has one
+func (s *Server) sendSettings(id string) {
+	s.mu.Lock()
+	var (
+		settings snapproto.ServerSettings
+		target   *player
+	)
+	if c := findClient(&s.state, id); c != nil {
+		settings = snapproto.ServerSettings{
+			BufferMs: DefaultBufferMs,
+			Latency:  c.Config.Latency,
+			Muted:    c.Config.Volume.Muted,
+			Volume:   c.Config.Volume.Percent,
+		}
+	}
+	for p := range s.players {
+		if p.id == id {
+			target = p
+		}
+	}
+	s.mu.Unlock()
+
+	if target != nil {
+		target.send(&settings, snapproto.Header{})
+	}
+}
+
+func groupOfClient(state *snapcast.Server, id string) string {
+	for _, g := range state.Groups {
+		for _, c := range g.Clients {
+			if c.ID == id {
+				return g.ID
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+package snapclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Batch queues calls and sends them as a single JSON-RPC batch, using one token of the rate limiter
+type Batch struct {
+	ctx   context.Context
+	c     *Client
+	calls []*batchCall
+	sent  bool
+}
+
+type batchCall struct {
+	req    *snapcast.Request
+	result *BatchResult
+}
+
+// BatchResult is the outcome of a single call of a batch
+type BatchResult struct {
+	Method   snapcast.RequestMethod
+	Response *snapcast.Response
+	// Set when the server answered the call with an error or never answered it
+	Err error
+}
+
+// BatchCall is a typed handle to a queued call, its result is available once the batch is sent
+type BatchCall[T any] struct {
+	call *batchCall
+}
+
+func (c *Client) Batch(ctx context.Context) *Batch {
+	return &Batch{ctx: ctx, c: c}
+}
+
+func queue[T any](b *Batch, method snapcast.RequestMethod, params interface{}) *BatchCall[T] {
+	var call = &batchCall{
+		req:    b.c.newRequest(method, params),
+		result: &BatchResult{Method: method},
+	}
+	b.calls = append(b.calls, call)
+	return &BatchCall[T]{call: call}
+}
+
+// Add queues an untyped call, see Client.Send
+func (b *Batch) Add(method snapcast.RequestMethod, params interface{}) *BatchCall[interface{}] {
+	return queue[interface{}](b, method, params)
+}
+
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Send the queued calls. Results are returned in the order the calls were queued,
+// the error is only set when the batch as a whole failed
+func (b *Batch) Send() ([]*BatchResult, error) {
+	if b.sent {
+		return nil, fmt.Errorf("batch already sent")
+	}
+	b.sent = true
+	if b.c.closed() {
+		return nil, ErrClientClosed
+	}
+
+	var results = make([]*BatchResult, len(b.calls))
+	for i, call := range b.calls {
+		results[i] = call.result
+	}
+	if len(b.calls) == 0 {
+		return results, nil
+	}
+
+	var (
+		reqs = make([]*snapcast.Request, len(b.calls))
+		ids  = make([]int, len(b.calls))
+	)
+	for i, call := range b.calls {
+		reqs[i] = call.req
+		ids[i] = *call.req.ID
+	}
+
+	// Limit requests/sec so we don't DOS the poor server
+	if err := b.c.limiter.Wait(b.ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		start     = time.Now()
+		responses []*snapcast.Response
+		err       error
+	)
+	if b.c.transport != TransportHTTP {
+		responses, err = b.c.exchangeConn(b.ctx, reqs, ids)
+	} else {
+		responses, err = b.sendHTTP(reqs, ids)
+	}
+	var d = time.Since(start)
+	if err != nil {
+		for _, call := range b.calls {
+			b.observe(call, d, &snapcast.Response{}, err)
+		}
+		return nil, err
+	}
+
+	for i, call := range b.calls {
+		call.result.Response = responses[i]
+		if responses[i] == nil {
+			call.result.Err = fmt.Errorf("%s: no response in batch", call.result.Method)
+			b.observe(call, d, &snapcast.Response{}, call.result.Err)
+			continue
+		}
+		call.result.Err = responseError(call.result.Method, responses[i])
+		b.observe(call, d, responses[i], nil)
+	}
+
+	return results, nil
+}
+
+// observe reports a call to Options.OnResponse like a Send of its own
+func (b *Batch) observe(call *batchCall, d time.Duration, res *snapcast.Response, err error) {
+	if b.c.onResponse != nil {
+		b.c.onResponse(call.result.Method, d, res, err)
+	}
+}
+
+func (b *Batch) sendHTTP(reqs []*snapcast.Request, ids []int) ([]*snapcast.Response, error) {
+	body, err := b.c.post(b.ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch []*snapcast.Response
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %v", err)
+		}
+	} else {
+		// The whole batch was rejected, e.g. a parse error
+		var res = &snapcast.Response{}
+		if err := json.Unmarshal(body, res); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %v", err)
+		}
+		if res.Error == nil {
+			return nil, fmt.Errorf("unexpected batch response: %s", body)
+		}
+		batch = make([]*snapcast.Response, len(ids))
+		for i := range batch {
+			batch[i] = res
+		}
+		return batch, nil
+	}
+
+	// Responses may come back in any order
+	var byID = make(map[int]*snapcast.Response, len(batch))
+	for _, res := range batch {
+		if res.ID != nil {
+			byID[*res.ID] = res
+		}
+	}
+
+	var responses = make([]*snapcast.Response, len(ids))
+	for i, id := range ids {
+		responses[i] = byID[id]
+	}
+	return responses, nil
+}
+
+// Result of the call, only valid after Batch.Send
+func (bc *BatchCall[T]) Result() (*T, error) {
+	var result = bc.call.result
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	if result.Response == nil {
+		return nil, fmt.Errorf("%s: batch not sent", result.Method)
+	}
+	return snapcast.ParseResult[T](result.Response.Result)
+}
+
+// --- Client
+
+func (b *Batch) ClientGetStatus(id string) *BatchCall[snapcast.ClientGetStatusResponse] {
+	return queue[snapcast.ClientGetStatusResponse](b, snapcast.MethodClientGetStatus, &snapcast.ClientGetStatusRequest{
+		ID: id,
+	})
+}
+
+func (b *Batch) ClientSetVolume(id string, volume snapcast.Volume) *BatchCall[snapcast.ClientSetVolumeResponse] {
+	return queue[snapcast.ClientSetVolumeResponse](b, snapcast.MethodClientSetVolume, &snapcast.ClientSetVolumeRequest{
+		ID:     id,
+		Volume: volume,
+	})
+}
+
+func (b *Batch) ClientSetLatency(id string, latency int) *BatchCall[snapcast.ClientSetLatencyResponse] {
+	return queue[snapcast.ClientSetLatencyResponse](b, snapcast.MethodClientSetLatency, &snapcast.ClientSetLatencyRequest{
+		ID:      id,
+		Latency: latency,
+	})
+}
+
+func (b *Batch) ClientSetName(id string, name string) *BatchCall[snapcast.ClientSetNameResponse] {
+	return queue[snapcast.ClientSetNameResponse](b, snapcast.MethodClientSetName, &snapcast.ClientSetNameRequest{
+		ID:   id,
+		Name: name,
+	})
+}
+
+// --- Group
+
+func (b *Batch) GroupGetStatus(id string) *BatchCall[snapcast.GroupGetStatusResponse] {
+	return queue[snapcast.GroupGetStatusResponse](b, snapcast.MethodGroupGetStatus, &snapcast.GroupGetStatusRequest{
+		ID: id,
+	})
+}
+
+func (b *Batch) GroupSetMute(id string, muted bool) *BatchCall[snapcast.GroupSetMuteResponse] {
+	return queue[snapcast.GroupSetMuteResponse](b, snapcast.MethodGroupSetMute, &snapcast.GroupSetMuteRequest{
+		ID:    id,
+		Muted: muted,
+	})
+}
+
+func (b *Batch) GroupSetStream(id string, streamID string) *BatchCall[snapcast.GroupSetStreamResponse] {
+	return queue[snapcast.GroupSetStreamResponse](b, snapcast.MethodGroupSetStream, &snapcast.GroupSetStreamRequest{
+		ID:       id,
+		StreamID: streamID,
+	})
+}
+
+func (b *Batch) GroupSetClients(id string, clients []string) *BatchCall[snapcast.GroupSetClientsResponse] {
+	return queue[snapcast.GroupSetClientsResponse](b, snapcast.MethodGroupSetClients, &snapcast.GroupSetClientsRequest{
+		ID:      id,
+		Clients: clients,
+	})
+}
+
+func (b *Batch) GroupSetName(id string, name string) *BatchCall[snapcast.GroupSetNameResponse] {
+	return queue[snapcast.GroupSetNameResponse](b, snapcast.MethodGroupSetName, &snapcast.GroupSetNameRequest{
+		ID:   id,
+		Name: name,
+	})
+}
+
+// --- Server
+
+func (b *Batch) ServerGetRPCVersion() *BatchCall[snapcast.ServerGetRPCVersionResponse] {
+	return queue[snapcast.ServerGetRPCVersionResponse](b, snapcast.MethodServerGetRPCVersion, &snapcast.ServerGetRPCVersion{})
+}
+
+func (b *Batch) ServerGetStatus() *BatchCall[snapcast.ServerGetStatusResponse] {
+	return queue[snapcast.ServerGetStatusResponse](b, snapcast.MethodServerGetStatus, &snapcast.ServerGetStatusRequest{})
+}
+
+func (b *Batch) ServerDeleteClient(id string) *BatchCall[snapcast.ServerDeleteClientResponse] {
+	return queue[snapcast.ServerDeleteClientResponse](b, snapcast.MethodServerDeleteClient, &snapcast.ServerDeleteClient{
+		ID: id,
+	})
+}
+
+// --- Stream
+
+func (b *Batch) StreamAddStream(streamURI string) *BatchCall[snapcast.StreamAddStreamResponse] {
+	return queue[snapcast.StreamAddStreamResponse](b, snapcast.MethodStreamAddStream, &snapcast.StreamAddStream{
+		StreamUri: streamURI,
+	})
+}
+
+func (b *Batch) StreamRemoveStream(id string) *BatchCall[snapcast.StreamRemoveStreamResponse] {
+	return queue[snapcast.StreamRemoveStreamResponse](b, snapcast.MethodStreamRemoveStream, &snapcast.StreamRemoveStream{
+		ID: id,
+	})
+}
+
+func (b *Batch) StreamControl(id string, command snapcast.StreamCommand, params interface{}) *BatchCall[snapcast.StreamControlResponse] {
+	return queue[snapcast.StreamControlResponse](b, snapcast.MethodStreamControl, &snapcast.StreamControl{
+		ID:      id,
+		Command: command,
+		Params:  params,
+	})
+}
+
+func (b *Batch) StreamSetProperty(id string, property string, value interface{}) *BatchCall[snapcast.StreamSetPropertyResponse] {
+	return queue[snapcast.StreamSetPropertyResponse](b, snapcast.MethodStreamSetProperty, &snapcast.StreamSetProperty{
+		ID:       id,
+		Property: property,
+		Value:    value,
+	})
+}
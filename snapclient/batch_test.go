@@ -0,0 +1,122 @@
+package snapclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// batchServer answers every line on a TCP connection with reply, given the IDs of the batch
+func batchServer(t *testing.T, reply func(ids []int) string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		var scanner = bufio.NewScanner(nc)
+		for scanner.Scan() {
+			var reqs []struct {
+				ID int `json:"id"`
+			}
+			json.Unmarshal(scanner.Bytes(), &reqs)
+			var ids []int
+			for _, r := range reqs {
+				ids = append(ids, r.ID)
+			}
+			fmt.Fprintln(nc, reply(ids))
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestBatchRejected(t *testing.T) {
+	var (
+		ctx  = testContext(t)
+		host = batchServer(t, func([]int) string {
+			return `{"jsonrpc": "2.0", "id": null, "error": {"code": -32700, "message": "Parse error"}}`
+		})
+		c = New(&Options{Host: host, Transport: TransportTCP})
+		b = c.Batch(ctx)
+	)
+	defer c.Close()
+
+	var calls = []*BatchCall[snapcast.GroupSetNameResponse]{b.GroupSetName("a", "A"), b.GroupSetName("b", "B")}
+	if _, err := b.Send(); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range calls {
+		if _, err := call.Result(); !errors.Is(err, snapcast.ErrParse) {
+			t.Errorf("call of a rejected batch = %v", err)
+		}
+	}
+}
+
+func TestBatchMissingCall(t *testing.T) {
+	var (
+		ctx  = testContext(t)
+		host = batchServer(t, func(ids []int) string {
+			// Only the first call is answered
+			return fmt.Sprintf(`[{"jsonrpc": "2.0", "id": %d, "result": {"name": "A"}}]`, ids[0])
+		})
+		observed []error
+		c        = New(&Options{Host: host, Transport: TransportTCP, OnResponse: func(_ snapcast.RequestMethod, _ time.Duration, _ *snapcast.Response, err error) {
+			observed = append(observed, err)
+		}})
+		b = c.Batch(ctx)
+	)
+	defer c.Close()
+
+	var (
+		first  = b.GroupSetName("a", "A")
+		second = b.GroupSetName("b", "B")
+	)
+	if _, err := b.Send(); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := first.Result(); err != nil || res.Name != "A" {
+		t.Errorf("first = %+v, %v", res, err)
+	}
+	if _, err := second.Result(); err == nil || !strings.Contains(err.Error(), "no response in batch") {
+		t.Errorf("second = %v", err)
+	}
+	// Every call is observed like a Send of its own
+	if len(observed) != 2 || observed[0] != nil || observed[1] == nil {
+		t.Errorf("observed %v", observed)
+	}
+}
+
+func TestResolveUnattributedError(t *testing.T) {
+	var (
+		c    = New(&Options{})
+		a, b = make(chan *snapcast.Response, 1), make(chan *snapcast.Response, 1)
+		res  = &snapcast.Response{Error: &snapcast.Error{Code: -32700, Message: "Parse error"}}
+	)
+	c.state.pending[1], c.state.pending[2] = a, b
+	c.state.inFlight = []*exchange{{ids: []int{1}}, {ids: []int{2}}}
+
+	// Either exchange may have caused it, both are left to their timeout
+	c.resolve(res)
+	if len(a) != 0 || len(b) != 0 {
+		t.Fatal("error without ID attributed with two exchanges in flight")
+	}
+
+	c.state.inFlight = c.state.inFlight[1:]
+	c.resolve(res)
+	if len(b) != 1 {
+		t.Error("error without ID not attributed to the only exchange in flight")
+	}
+}
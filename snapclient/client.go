@@ -5,12 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/ConnorsApps/snapcast-go/snapcast"
-	"github.com/coder/websocket"
 	"golang.org/x/time/rate"
 )
 
@@ -19,29 +19,71 @@ var (
 	DefaultRequestRate  = rate.Every(time.Second / 2)
 )
 
+type Transport int
+
+const (
+	// Requests are sent as HTTP POSTs, notifications are read from a WebSocket
+	TransportHTTP Transport = iota
+	// Requests, responses and notifications share a single WebSocket.
+	// Responses are matched to requests by ID, guaranteeing ordering relative to notifications:
+	// a Send returns only after the notifications read before its response were queued for the listeners
+	TransportWebSocket
+	// Newline delimited JSON over a plain TCP socket, snapserver listens on port 1705 by default.
+	// Requests and notifications share the connection like TransportWebSocket
+	TransportTCP
+)
+
 type state struct {
 	sync.Mutex
-	reqCount uint
+	reqCount  uint
+	conn      conn
+	pending   map[int]chan *snapcast.Response
+	inFlight  []*exchange
+	listeners map[*listener]struct{}
+	// Cancels a running reconnect loop
+	stopReconnect context.CancelFunc
+	// Set by Close, no connection is dialed after it
+	closed bool
 }
 
 type Client struct {
 	limiter          *rate.Limiter
-	ws               *websocket.Conn
 	host             string
 	state            state
+	dialMu           sync.Mutex
+	writeMu          sync.Mutex
 	secureConnection bool
 	httpClient       *http.Client
+	transport        Transport
+	reconnect        *ReconnectPolicy
+	subs             subscriptions
+	dispatchPolicy   DispatchPolicy
+	streams          streamCache
+	onResponse       func(method snapcast.RequestMethod, d time.Duration, res *snapcast.Response, err error)
+	onFrame          func(raw []byte, receivedAt time.Time)
 }
 
 type Options struct {
 	Host        string
 	RateLimiter *rate.Limiter
-	// if secure then https & wss and used else http & ws protocols
+	// if secure then https & wss and used else http & ws protocols, TransportTCP dials with TLS
 	SecureConnection bool
 	// HTTPClient is used for JSON-RPC requests and for the WebSocket upgrade
 	// handshake. Supply an instrumented client (e.g. otelhttp) to add tracing.
 	// If nil, a default client is used.
 	HTTPClient *http.Client
+	// Transport used by Send, defaults to TransportHTTP
+	Transport Transport
+	// If set, Listen re-dials a dropped connection instead of ending
+	Reconnect *ReconnectPolicy
+	// How handlers registered with the On* methods are called, defaults to DispatchSerial
+	Dispatch DispatchPolicy
+	// Called after every Send and for every call of a Batch with the time from sending the request to
+	// its response, excluding the rate limiter, e.g. to record metrics. err is set if no response arrived
+	OnResponse func(method snapcast.RequestMethod, d time.Duration, res *snapcast.Response, err error)
+	// Called with every frame read from the WebSocket or TCP connection before it is parsed,
+	// e.g. to record traffic with snaprecord. raw must not be kept after it returns
+	OnFrame func(raw []byte, receivedAt time.Time)
 }
 
 func New(o *Options) *Client {
@@ -61,12 +103,25 @@ func New(o *Options) *Client {
 		limiter:          o.RateLimiter,
 		httpClient:       httpClient,
 		secureConnection: o.SecureConnection,
+		transport:        o.Transport,
+		reconnect:        o.Reconnect,
+		dispatchPolicy:   o.Dispatch,
+		onResponse:       o.OnResponse,
+		onFrame:          o.OnFrame,
+		state: state{
+			pending:   make(map[int]chan *snapcast.Response),
+			listeners: make(map[*listener]struct{}),
+		},
 	}
 }
 
 type Notifications struct {
 	MsgReaderErr chan error
 
+	// Connection lifecycle, only sent when Options.Reconnect is set
+	Disconnected chan error
+	Connected    chan struct{}
+
 	// Client
 	ClientOnConnect        chan *snapcast.ClientOnConnect
 	ClientOnDisconnect     chan *snapcast.ClientOnDisconnect
@@ -84,57 +139,98 @@ type Notifications struct {
 	ServerOnUpdate chan *snapcast.ServerOnUpdate
 }
 
-// Passes a websocket closer channel or an error on initial setup
+// Passes a connection closer channel or an error on initial setup.
+// With TransportWebSocket or TransportTCP the connection is shared with Send.
+// With Options.Reconnect the closer only fires once the reconnect policy gives up.
+// Notifications may be nil when only handlers registered with the On* methods are used.
 func (c *Client) Listen(ctx context.Context, n *Notifications) (chan error, error) {
-	var (
-		msgChan = make(chan *snapcast.Notification, 5)
-		wsClose = make(chan error)
-	)
+	if n == nil {
+		n = &Notifications{}
+	}
 
-	if err := c.wsConnect(ctx); err != nil {
+	if _, err := c.connect(ctx); err != nil {
+		var wsClose = make(chan error, 1)
 		return wsClose, err
 	}
-	go c.readNotifications(ctx, n, msgChan, wsClose)
+	var l = newListener(n)
+	c.addListener(l)
 
 	go func() {
-		for {
-			msg, ok := <-msgChan
-			if !ok { // Websocket closed
-				return
-			}
-
-			n.handleNotification(msg)
+		select {
+		case <-ctx.Done():
+			c.removeListener(l, ctx.Err())
+		case <-l.done:
 		}
 	}()
 
-	return wsClose, nil
+	return l.wsClose, nil
 }
 
-func (c *Client) Send(ctx context.Context, method snapcast.RequestMethod, params interface{}) (*snapcast.Response, error) {
+func (c *Client) nextID() int {
 	c.state.Lock()
+	defer c.state.Unlock()
 	c.state.reqCount += 1
-	c.state.Unlock()
+	return int(c.state.reqCount)
+}
+
+func (c *Client) newRequest(method snapcast.RequestMethod, params interface{}) *snapcast.Request {
+	var id = c.nextID()
+	return &snapcast.Request{
+		ID:      &id,
+		JsonRPC: "2.0",
+		Method:  &method,
+		Params:  &params,
+	}
+}
+
+func (c *Client) Send(ctx context.Context, method snapcast.RequestMethod, params interface{}) (*snapcast.Response, error) {
+	if c.closed() {
+		return &snapcast.Response{}, ErrClientClosed
+	}
+	var req = c.newRequest(method, params)
+
+	// Limit requests/sec so we don't DOS the poor server
+	if err := c.limiter.Wait(ctx); err != nil {
+		return &snapcast.Response{}, err
+	}
 
 	var (
-		id  = int(c.state.reqCount)
-		req = snapcast.Request{
-			ID:      &id,
-			JsonRPC: "2.0",
-			Method:  &method,
-			Params:  &params,
-		}
+		start    = time.Now()
+		res, err = c.send(ctx, req)
 	)
+	if c.onResponse != nil {
+		c.onResponse(method, time.Since(start), res, err)
+	}
+	return res, err
+}
+
+func (c *Client) send(ctx context.Context, req *snapcast.Request) (*snapcast.Response, error) {
+	if c.transport != TransportHTTP {
+		return c.sendConn(ctx, req)
+	}
+	return c.sendHTTP(ctx, req)
+}
 
+func (c *Client) sendHTTP(ctx context.Context, req *snapcast.Request) (*snapcast.Response, error) {
 	var response = &snapcast.Response{}
 
-	// Limit requests/sec so we don't DOS the poor server
-	if err := c.limiter.Wait(ctx); err != nil {
+	body, err := c.post(ctx, req)
+	if err != nil {
 		return response, err
 	}
 
+	if err := json.Unmarshal(body, response); err != nil {
+		return response, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+
+	return response, nil
+}
+
+// post sends msg to the JSON-RPC endpoint and returns the raw response body
+func (c *Client) post(ctx context.Context, msg interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	if err := json.NewEncoder(buf).Encode(req); err != nil {
-		return response, fmt.Errorf("json.NewEncoder(buf).Encode(req): %v", err)
+	if err := json.NewEncoder(buf).Encode(msg); err != nil {
+		return nil, fmt.Errorf("json.NewEncoder(buf).Encode(msg): %v", err)
 	}
 
 	proto := "http"
@@ -145,7 +241,7 @@ func (c *Client) Send(ctx context.Context, method snapcast.RequestMethod, params
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, buf)
 	if err != nil {
-		return response, fmt.Errorf("http.NewRequestWithContext: %v", err)
+		return nil, fmt.Errorf("http.NewRequestWithContext: %v", err)
 	}
 
 	httpReq.Header = http.Header{
@@ -155,17 +251,18 @@ func (c *Client) Send(ctx context.Context, method snapcast.RequestMethod, params
 
 	res, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return response, err
+		return nil, err
 	}
 
 	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return response, fmt.Errorf("%s", res.Status)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %v", err)
 	}
 
-	if err := json.NewDecoder(res.Body).Decode(response); err != nil {
-		return response, fmt.Errorf("json.NewDecoder: %v", err)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status, Body: body}
 	}
 
-	return response, nil
+	return body, nil
 }
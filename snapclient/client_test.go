@@ -2,38 +2,550 @@ package snapclient
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/coder/websocket"
+	"golang.org/x/time/rate"
 )
 
+const (
+	livingRoom = "00:21:6a:7d:74:fc"
+	kitchen    = "b8:27:eb:5f:1e:77"
+)
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
 func TestClient(t *testing.T) {
-	c := New(&Options{Host: "audio.connorskees.com:1780", SecureConnection: false})
-	if _, err := c.Send(context.Background(), snapcast.MethodServerGetStatus, &snapcast.ServerGetStatusRequest{}); err != nil {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+	)
+	defer srv.Close()
+
+	c := New(&Options{Host: srv.Host(), SecureConnection: false})
+	status, err := c.ServerGetStatus(ctx)
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(status.Server.Groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(status.Server.Groups))
+	}
 
 	onUpdate := make(chan *snapcast.ClientOnVolumeChanged)
 	n := &Notifications{
 		ClientOnVolumeChanged: onUpdate,
 	}
 
-	go func() {
+	if _, err := c.Listen(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Sent over HTTP, so the websocket isn't the origin and gets the notification
+	if _, err := c.ClientSetVolume(ctx, kitchen, snapcast.Volume{Percent: 12}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-onUpdate:
+		if msg.ID != kitchen || msg.Volume.Percent != 12 {
+			t.Errorf("unexpected notification %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no Client.OnVolumeChanged notification")
+	}
+}
+
+func TestTransports(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	for name, opts := range map[string]*Options{
+		"websocket": {Host: srv.Host(), Transport: TransportWebSocket},
+		"tcp":       {Host: srv.TCPHost(), Transport: TransportTCP},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				ctx   = testContext(t)
+				c     = New(opts)
+				names = make(chan *snapcast.GroupOnNameChanged)
+			)
+			defer c.Close()
+
+			if _, err := c.Listen(ctx, &Notifications{GroupOnNameChanged: names}); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.ClientSetName(ctx, livingRoom, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Name != name {
+				t.Errorf("name = %q, want %q", res.Name, name)
+			}
+
+			// Changes from other connections arrive as notifications
+			other := New(&Options{Host: srv.Host()})
+			status, err := other.ServerGetStatus(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var groupID = status.Server.Groups[0].ID
+			if _, err := other.GroupSetName(ctx, groupID, name); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case msg := <-names:
+				if msg.ID != groupID || msg.Name != name {
+					t.Errorf("unexpected notification %+v", msg)
+				}
+			case <-ctx.Done():
+				t.Fatal("no Group.OnNameChanged notification")
+			}
+		})
+	}
+}
+
+// A consumer calling Send from its notification loop must not block the reader of its response
+func TestSendFromListener(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	for name, opts := range map[string]*Options{
+		"websocket": {Host: srv.Host(), Transport: TransportWebSocket, RateLimiter: rate.NewLimiter(rate.Inf, 0)},
+		"tcp":       {Host: srv.TCPHost(), Transport: TransportTCP, RateLimiter: rate.NewLimiter(rate.Inf, 0)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				ctx   = testContext(t)
+				c     = New(opts)
+				mutes = make(chan *snapcast.GroupOnMute)
+			)
+			defer c.Close()
+
+			if _, err := c.Listen(ctx, &Notifications{GroupOnMute: mutes}); err != nil {
+				t.Fatal(err)
+			}
+			// Answered in order with the notifications that follow, which back up meanwhile
+			if _, err := c.ServerGetRPCVersion(ctx); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 20; i++ {
+				srv.Notify(snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: "g", Mute: i%2 == 0})
+			}
+
+			for i := 0; i < 20; i++ {
+				select {
+				case <-mutes:
+				case <-ctx.Done():
+					t.Fatalf("notification %d never arrived", i)
+				}
+				if _, err := c.ClientGetStatus(ctx, kitchen); err != nil {
+					t.Fatalf("Send from the listener: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// Messages that don't parse are reported on MsgReaderErr and reading goes on
+func TestMsgReaderErr(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.CloseNow()
+		ws.Write(r.Context(), websocket.MessageText, []byte(`not json`))
+		ws.Write(r.Context(), websocket.MessageText, []byte(`{"jsonrpc": "2.0", "method": "Group.OnMute", "params": {"id": "g", "mute": true}}`))
+		ws.Read(r.Context())
+	}))
+	defer srv.Close()
+
+	var (
+		ctx = testContext(t)
+		c   = New(&Options{Host: strings.TrimPrefix(srv.URL, "http://")})
+		n   = &Notifications{MsgReaderErr: make(chan error), GroupOnMute: make(chan *snapcast.GroupOnMute)}
+	)
+	defer c.Close()
+
+	closed, err := c.Listen(ctx, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-n.MsgReaderErr:
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("MsgReaderErr %v", err)
+		}
+	case err := <-closed:
+		t.Fatalf("listener ended: %v", err)
+	case <-ctx.Done():
+		t.Fatal("no MsgReaderErr")
+	}
+	select {
+	case p := <-n.GroupOnMute:
+		if p.ID != "g" || !p.Mute {
+			t.Errorf("notification %+v", p)
+		}
+	case err := <-closed:
+		t.Fatalf("listener ended: %v", err)
+	case <-ctx.Done():
+		t.Fatal("no notification after the bad frame")
+	}
+}
+
+// The notifications read before a response are handed off before its Send returns
+func TestResponseOrder(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.CloseNow()
 		for {
-			msg := <-onUpdate
-			fmt.Println("onUpdate", msg)
+			_, raw, err := ws.Read(r.Context())
+			if err != nil {
+				return
+			}
+			var req snapcast.Request
+			json.Unmarshal(raw, &req)
+			ws.Write(r.Context(), websocket.MessageText, []byte(`{"jsonrpc": "2.0", "method": "Stream.OnProperties", "params": {"id": "default", "properties": {"canControl": true}}}`))
+			ws.Write(r.Context(), websocket.MessageText, []byte(fmt.Sprintf(`{"id": %d, "jsonrpc": "2.0", "result": {"major": 2}}`, *req.ID)))
+		}
+	}))
+	defer srv.Close()
+
+	var (
+		ctx = testContext(t)
+		c   = New(&Options{Host: strings.TrimPrefix(srv.URL, "http://"), Transport: TransportWebSocket})
+		n   = &Notifications{StreamOnProperties: make(chan *snapcast.StreamOnProperties)}
+	)
+	defer c.Close()
+
+	if _, err := c.Listen(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.ServerGetRPCVersion(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if props, ok := c.streams.get("default"); !ok || !props.CanControl {
+			t.Fatalf("response %d resolved before the notification read ahead of it", i)
+		}
+		c.streams.reset()
+		select {
+		case <-n.StreamOnProperties:
+		case <-ctx.Done():
+			t.Fatal("notification not delivered")
+		}
+	}
+}
+
+// errConn fails its first read with an error that isn't a close
+type errConn struct {
+	reads atomic.Int32
+}
+
+func (e *errConn) read(ctx context.Context) ([]byte, error) {
+	if e.reads.Add(1) == 1 {
+		return nil, syscall.ECONNRESET
+	}
+	return nil, net.ErrClosed
+}
+func (e *errConn) write(ctx context.Context, msg []byte) error { return net.ErrClosed }
+func (e *errConn) close() error                                { return nil }
+
+// Any read error is the end of the connection
+func TestReadError(t *testing.T) {
+	var (
+		ctx = testContext(t)
+		c   = New(&Options{Transport: TransportWebSocket})
+		l   = newListener(&Notifications{MsgReaderErr: make(chan error, 1)})
+		cn  = &errConn{}
+	)
+	c.addListener(l)
+	c.state.Lock()
+	c.state.conn = cn
+	c.state.Unlock()
+
+	go c.readMessages(cn)
+	select {
+	case err := <-l.wsClose:
+		if !errors.Is(err, syscall.ECONNRESET) {
+			t.Errorf("closed with %v", err)
 		}
+	case <-ctx.Done():
+		t.Fatal("listener not ended")
+	}
+	if n := cn.reads.Load(); n != 1 {
+		t.Errorf("read the dead connection %d times", n)
+	}
+	if c.connected() {
+		t.Error("still connected")
+	}
+}
+
+// A closed client stays closed, even when Close lands while a connection is being dialed
+func TestClosed(t *testing.T) {
+	var (
+		dialing  = make(chan struct{})
+		release  = make(chan struct{})
+		wsClosed = make(chan error, 1)
+		srv      = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(dialing)
+			<-release
+			ws, err := websocket.Accept(w, r, nil)
+			if err != nil {
+				return
+			}
+			_, _, err = ws.Read(r.Context())
+			wsClosed <- err
+		}))
+		ctx = testContext(t)
+		c   = New(&Options{Host: strings.TrimPrefix(srv.URL, "http://"), Transport: TransportWebSocket})
+	)
+	defer srv.Close()
+
+	var listened = make(chan error, 1)
+	go func() {
+		_, err := c.Listen(ctx, nil)
+		listened <- err
 	}()
+	<-dialing
+	c.Close()
+	close(release)
+
+	if err := <-listened; !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Listen = %v, want ErrClientClosed", err)
+	}
+	select {
+	case <-wsClosed:
+	case <-ctx.Done():
+		t.Fatal("connection dialed during Close left open")
+	}
+	if c.connected() {
+		t.Error("connected after Close")
+	}
+
+	if _, err := c.ServerGetStatus(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Send after Close = %v", err)
+	}
+	if _, err := c.Listen(ctx, nil); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Listen after Close = %v", err)
+	}
+	var b = c.Batch(ctx)
+	b.ServerGetStatus()
+	if _, err := b.Send(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Batch.Send after Close = %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	for name, opts := range map[string]*Options{
+		"http":      {Host: srv.Host()},
+		"websocket": {Host: srv.Host(), Transport: TransportWebSocket},
+		"tcp":       {Host: srv.TCPHost(), Transport: TransportTCP},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				ctx = testContext(t)
+				c   = New(opts)
+				b   = c.Batch(ctx)
+			)
+			defer c.Close()
+
+			living := b.ClientSetVolume(livingRoom, snapcast.Volume{Percent: 1})
+			missing := b.ClientSetVolume("missing", snapcast.Volume{Percent: 2})
+			kitchenVolume := b.ClientSetVolume(kitchen, snapcast.Volume{Percent: 3})
+
+			results, err := b.Send()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 3 {
+				t.Fatalf("got %d results, want 3", len(results))
+			}
+
+			if res, err := living.Result(); err != nil || res.Volume.Percent != 1 {
+				t.Errorf("living room = %+v, %v", res, err)
+			}
+			if _, err := missing.Result(); !errors.Is(err, snapcast.ErrClientNotFound) {
+				t.Errorf("missing client error = %v", err)
+			}
+			if res, err := kitchenVolume.Result(); err != nil || res.Volume.Percent != 3 {
+				t.Errorf("kitchen = %+v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+		c   = New(&Options{Host: srv.Host()})
+	)
+	defer srv.Close()
+
+	_, err := c.ClientSetName(ctx, "missing", "x")
+	if !errors.Is(err, snapcast.ErrClientNotFound) {
+		t.Errorf("expected ErrClientNotFound, got %v", err)
+	}
+
+	var rpcErr *snapcast.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Method != snapcast.MethodClientSetName {
+		t.Errorf("expected *snapcast.RPCError for Client.SetName, got %v", err)
+	}
+
+	bad := New(&Options{Host: srv.Host() + "/missing"})
+	var statusErr *HTTPStatusError
+	if _, err := bad.ServerGetStatus(ctx); !errors.As(err, &statusErr) || statusErr.StatusCode != 404 {
+		t.Errorf("expected *HTTPStatusError 404, got %v", err)
+	}
+}
 
-	if _, err := c.Listen(context.Background(), n); err != nil {
+func TestOnResponse(t *testing.T) {
+	type observed struct {
+		method snapcast.RequestMethod
+		res    *snapcast.Response
+		err    error
+	}
+	var (
+		srv  = snapcasttest.NewServer(nil)
+		ctx  = testContext(t)
+		seen []observed
+		c    = New(&Options{Host: srv.Host(), OnResponse: func(method snapcast.RequestMethod, d time.Duration, res *snapcast.Response, err error) {
+			if d <= 0 {
+				t.Errorf("%s took %s", method, d)
+			}
+			seen = append(seen, observed{method, res, err})
+		}})
+	)
+	defer srv.Close()
+
+	if _, err := c.ServerGetStatus(ctx); err != nil {
 		t.Fatal(err)
 	}
+	c.ClientSetName(ctx, "missing", "x")
+
+	if len(seen) != 2 || seen[0].method != snapcast.MethodServerGetStatus || seen[0].err != nil || seen[0].res.Error != nil {
+		t.Fatalf("observed %+v", seen)
+	}
+	if seen[1].method != snapcast.MethodClientSetName || seen[1].err != nil || seen[1].res.Error == nil {
+		t.Errorf("observed %+v, want the error response", seen[1])
+	}
+}
+
+func TestOnFrame(t *testing.T) {
+	var (
+		srv    = snapcasttest.NewServer(nil)
+		ctx    = testContext(t)
+		frames = make(chan string, 4)
+		c      = New(&Options{Host: srv.Host(), OnFrame: func(raw []byte, receivedAt time.Time) {
+			if receivedAt.IsZero() {
+				t.Error("no receivedAt")
+			}
+			frames <- string(raw)
+		}})
+		n = &Notifications{GroupOnMute: make(chan *snapcast.GroupOnMute, 1)}
+	)
+	defer srv.Close()
+
+	if _, err := c.Listen(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	srv.Notify(snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: "g", Mute: true})
+	select {
+	case raw := <-frames:
+		if !strings.Contains(raw, `"Group.OnMute"`) {
+			t.Errorf("frame %s", raw)
+		}
+	case <-ctx.Done():
+		t.Fatal("no frame")
+	}
+	<-n.GroupOnMute
+
+	// Handle feeds the channels without a connection
+	var method = snapcast.MethodGroupOnMute
+	go n.Handle(&snapcast.Notification{Method: &method, Params: map[string]interface{}{"id": "g2", "mute": true}})
+	if p := <-n.GroupOnMute; p.ID != "g2" || !p.Mute {
+		t.Errorf("handled %+v", p)
+	}
+}
 
+func TestReconnect(t *testing.T) {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+		c   = New(&Options{
+			Host:      srv.Host(),
+			Transport: TransportWebSocket,
+			Reconnect: &ReconnectPolicy{InitialDelay: 10 * time.Millisecond},
+		})
+		n = &Notifications{
+			Disconnected:       make(chan error),
+			Connected:          make(chan struct{}),
+			GroupOnMute:        make(chan *snapcast.GroupOnMute),
+			ClientOnDisconnect: make(chan *snapcast.ClientOnDisconnect),
+		}
+	)
+	defer srv.Close()
 	defer c.Close()
 
-	fmt.Println("Listening")
+	closed, err := c.Listen(ctx, n)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	time.Sleep(500 * time.Second)
+	srv.CloseConnections()
+	select {
+	case <-n.Disconnected:
+	case <-ctx.Done():
+		t.Fatal("no disconnected event")
+	}
+	select {
+	case <-n.Connected:
+	case err := <-closed:
+		t.Fatalf("listener ended: %v", err)
+	case <-ctx.Done():
+		t.Fatal("no connected event")
+	}
+
+	// A round trip on the new websocket makes sure the server registered it
+	if _, err := c.ServerGetRPCVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same channels keep receiving after the reconnect
+	srv.DisconnectClient(kitchen)
+	select {
+	case msg := <-n.ClientOnDisconnect:
+		if msg.ID != kitchen {
+			t.Errorf("unexpected notification %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no notification after reconnect")
+	}
 }
@@ -2,36 +2,396 @@ package snapclient
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 
+	"github.com/ConnorsApps/snapcast-go/snapcast"
 	"github.com/coder/websocket"
 )
 
-func (c *Client) wsConnect(ctx context.Context) error {
+// Large enough for a Server.GetStatus of a big install, the library default is 32KiB
+const wsReadLimit = 4 << 20
+
+// conn is a control connection carrying whole JSON-RPC messages
+type conn interface {
+	read(ctx context.Context) ([]byte, error)
+	write(ctx context.Context, msg []byte) error
+	close() error
+}
+
+type wsConn struct {
+	ws *websocket.Conn
+}
+
+func (w *wsConn) read(ctx context.Context) ([]byte, error) {
+	_, raw, err := w.ws.Read(ctx)
+	return raw, err
+}
+
+func (w *wsConn) write(ctx context.Context, msg []byte) error {
+	return w.ws.Write(ctx, websocket.MessageText, msg)
+}
+
+func (w *wsConn) close() error {
+	return w.ws.Close(websocket.StatusNormalClosure, "")
+}
+
+type listener struct {
+	n *Notifications
+	// Delivers to n on its own goroutine, so a listener busy with a Send doesn't hold up reading
+	queue   *taskQueue
+	wsClose chan error
+	done    chan struct{}
+}
+
+func newListener(n *Notifications) *listener {
+	return &listener{
+		n:       n,
+		queue:   newTaskQueue(notificationQueueSize, DropNone),
+		wsClose: make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func (c *Client) wsConnect(ctx context.Context) (conn, error) {
 	scheme := "ws"
 	if c.secureConnection {
 		scheme = "wss"
 	}
 
-	var (
-		u   = url.URL{Scheme: scheme, Host: c.host, Path: "/jsonrpc"}
-		err error
-	)
+	var u = url.URL{Scheme: scheme, Host: c.host, Path: "/jsonrpc"}
 
-	c.ws, _, err = websocket.Dial(ctx, u.String(), &websocket.DialOptions{
+	ws, _, err := websocket.Dial(ctx, u.String(), &websocket.DialOptions{
 		HTTPClient: c.httpClient,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to snapcast at '%s', err: %w", c.host, err)
+		return nil, fmt.Errorf("failed to connect to snapcast at '%s', err: %w", c.host, err)
 	}
+	ws.SetReadLimit(wsReadLimit)
 
-	return nil
+	return &wsConn{ws: ws}, nil
+}
+
+// connect returns the open connection, dialing and starting a reader if there is none
+func (c *Client) connect(ctx context.Context) (conn, error) {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	c.state.Lock()
+	cn, closed := c.state.conn, c.state.closed
+	c.state.Unlock()
+	if closed {
+		return nil, ErrClientClosed
+	}
+	if cn != nil {
+		return cn, nil
+	}
+
+	var err error
+	if c.transport == TransportTCP {
+		cn, err = c.tcpConnect(ctx)
+	} else {
+		cn, err = c.wsConnect(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Close may have been called while dialing
+	c.state.Lock()
+	if c.state.closed {
+		c.state.Unlock()
+		cn.close()
+		return nil, ErrClientClosed
+	}
+	c.streams.reset()
+	c.state.conn = cn
+	c.state.Unlock()
+
+	go c.readMessages(cn)
+
+	return cn, nil
+}
+
+// sendConn writes the request on the shared connection and waits for the response with the same ID
+func (c *Client) sendConn(ctx context.Context, req *snapcast.Request) (*snapcast.Response, error) {
+	responses, err := c.exchangeConn(ctx, req, []int{*req.ID})
+	if err != nil {
+		return &snapcast.Response{}, err
+	}
+	return responses[0], nil
+}
+
+// exchange is a message written on the shared connection that still awaits responses
+type exchange struct {
+	ids []int
+}
+
+// exchangeConn writes msg on the shared connection and waits for a response to each of ids, in the order of ids.
+// A response is nil if the server answered the batch but left the call out
+func (c *Client) exchangeConn(ctx context.Context, msg interface{}, ids []int) ([]*snapcast.Response, error) {
+	cn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(msg): %v", err)
+	}
+
+	var (
+		ex       = &exchange{ids: ids}
+		resChans = make([]chan *snapcast.Response, len(ids))
+	)
+	c.state.Lock()
+	for i, id := range ids {
+		resChans[i] = make(chan *snapcast.Response, 1)
+		c.state.pending[id] = resChans[i]
+	}
+	c.state.Unlock()
+
+	defer func() {
+		c.state.Lock()
+		for _, id := range ids {
+			delete(c.state.pending, id)
+		}
+		c.removeExchange(ex)
+		c.state.Unlock()
+	}()
+
+	// In flight exchanges are kept in the order they are written
+	c.writeMu.Lock()
+	c.state.Lock()
+	c.state.inFlight = append(c.state.inFlight, ex)
+	c.state.Unlock()
+	err = cn.write(ctx, raw)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var responses = make([]*snapcast.Response, len(ids))
+	for i, resChan := range resChans {
+		select {
+		case res, ok := <-resChan:
+			if !ok {
+				return nil, ErrConnectionClosed
+			}
+			responses[i] = res
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return responses, nil
+}
+
+// removeExchange drops ex from the in flight exchanges, call with the state locked
+func (c *Client) removeExchange(ex *exchange) {
+	for i, e := range c.state.inFlight {
+		if e == ex {
+			c.state.inFlight = append(c.state.inFlight[:i], c.state.inFlight[i+1:]...)
+			return
+		}
+	}
 }
 
+// resolve hands a response to the Send waiting on its ID.
+// An error without an ID, e.g. for a message that didn't parse, fails every call of the exchange
+// in flight. With several in flight it can't be told whose it is, they are left to their timeout
+func (c *Client) resolve(res *snapcast.Response) {
+	c.state.Lock()
+	defer c.state.Unlock()
+
+	if res.ID == nil {
+		if res.Error == nil || len(c.state.inFlight) != 1 {
+			return
+		}
+		var ex = c.state.inFlight[0]
+		c.removeExchange(ex)
+		for _, id := range ex.ids {
+			c.resolvePending(id, res)
+		}
+		return
+	}
+
+	c.resolvePending(*res.ID, res)
+	for _, ex := range c.state.inFlight {
+		if slices.Contains(ex.ids, *res.ID) {
+			if !slices.ContainsFunc(ex.ids, c.isPending) {
+				c.removeExchange(ex)
+			}
+			return
+		}
+	}
+}
+
+// isPending reports whether a call waits on id, call with the state locked
+func (c *Client) isPending(id int) bool {
+	_, ok := c.state.pending[id]
+	return ok
+}
+
+// resolveBatch fails the calls of the exchange answered by a batch reply that aren't in it
+func (c *Client) resolveBatch(answered []int) {
+	if len(answered) == 0 {
+		return
+	}
+
+	c.state.Lock()
+	defer c.state.Unlock()
+
+	for _, ex := range c.state.inFlight {
+		if !slices.Contains(ex.ids, answered[0]) {
+			continue
+		}
+		c.removeExchange(ex)
+		for _, id := range ex.ids {
+			if !slices.Contains(answered, id) {
+				c.resolvePending(id, nil)
+			}
+		}
+		return
+	}
+}
+
+// resolvePending sends res to the call waiting on id, call with the state locked
+func (c *Client) resolvePending(id int, res *snapcast.Response) {
+	if resChan, ok := c.state.pending[id]; ok {
+		delete(c.state.pending, id)
+		resChan <- res
+	}
+}
+
+func (c *Client) addListener(l *listener) {
+	c.state.Lock()
+	c.state.listeners[l] = struct{}{}
+	c.state.Unlock()
+}
+
+func (c *Client) removeListener(l *listener, err error) {
+	c.state.Lock()
+	_, ok := c.state.listeners[l]
+	delete(c.state.listeners, l)
+	var (
+		idle          = len(c.state.listeners) == 0
+		stopReconnect context.CancelFunc
+	)
+	// Send dials again on its own, only listeners need the loop
+	if idle {
+		stopReconnect, c.state.stopReconnect = c.state.stopReconnect, nil
+	}
+	c.state.Unlock()
+
+	if !ok {
+		return
+	}
+	close(l.done)
+	l.queue.close()
+	l.wsClose <- err
+
+	if stopReconnect != nil {
+		stopReconnect()
+	}
+	// Nothing else needs the connection when requests go over HTTP
+	if idle && c.transport == TransportHTTP {
+		c.closeConn()
+	}
+}
+
+func (c *Client) listenerSnapshot() []*listener {
+	c.state.Lock()
+	defer c.state.Unlock()
+
+	var listeners = make([]*listener, 0, len(c.state.listeners))
+	for l := range c.state.listeners {
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// closed reports whether Close was called
+func (c *Client) closed() bool {
+	c.state.Lock()
+	defer c.state.Unlock()
+	return c.state.closed
+}
+
+// connected reports whether a connection is open, reading notifications
+func (c *Client) connected() bool {
+	c.state.Lock()
+	defer c.state.Unlock()
+	return c.state.conn != nil
+}
+
+// disconnected fails in flight requests and, unless reconnecting, ends all listeners of a dead connection
+func (c *Client) disconnected(cn conn, err error) {
+	c.state.Lock()
+	// Close already dropped the connection if it was closed on purpose
+	var unexpected = c.state.conn == cn
+	if unexpected {
+		c.state.conn = nil
+	}
+	for id, resChan := range c.state.pending {
+		close(resChan)
+		delete(c.state.pending, id)
+	}
+	c.state.inFlight = nil
+
+	var (
+		reconnect = unexpected && c.reconnect != nil && len(c.state.listeners) > 0
+		ctx       context.Context
+	)
+	if reconnect {
+		ctx, c.state.stopReconnect = context.WithCancel(context.Background())
+	}
+	c.state.Unlock()
+
+	if reconnect {
+		go c.reconnectLoop(ctx, err)
+		return
+	}
+
+	c.endListeners(err)
+}
+
+func (c *Client) endListeners(err error) {
+	for _, l := range c.listenerSnapshot() {
+		c.removeListener(l, err)
+	}
+}
+
+// Close the client for good, later calls return ErrClientClosed
 func (c *Client) Close() error {
-	if err := c.ws.Close(websocket.StatusNormalClosure, ""); err != nil {
-		return fmt.Errorf("failed to close snapcast websocket, err: %w", err)
+	c.stopSerial()
+
+	c.state.Lock()
+	c.state.closed = true
+	c.state.Unlock()
+
+	return c.closeConn()
+}
+
+// closeConn closes the open connection and stops reconnecting, a later call dials again
+func (c *Client) closeConn() error {
+	c.state.Lock()
+	cn := c.state.conn
+	c.state.conn = nil
+	if c.state.stopReconnect != nil {
+		c.state.stopReconnect()
+		c.state.stopReconnect = nil
+	}
+	c.state.Unlock()
+
+	if cn == nil {
+		return nil
+	}
+
+	if err := cn.close(); err != nil {
+		return fmt.Errorf("failed to close snapcast connection, err: %w", err)
 	}
 	return nil
 }
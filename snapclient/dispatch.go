@@ -0,0 +1,128 @@
+package snapclient
+
+import (
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+type DispatchMode int
+
+const (
+	// Handlers run one at a time in notification order on a single goroutine shared by all subscribers.
+	// Its queue holds QueueSize notifications, a full queue waits like DropNone
+	DispatchSerial DispatchMode = iota
+	// Every handler call runs on its own goroutine, there is no ordering
+	DispatchGoroutine
+	// Each subscriber gets a bounded queue drained by its own goroutine, full queues apply the DropPolicy
+	DispatchQueue
+)
+
+type DropPolicy int
+
+const (
+	// Discard the notification that didn't fit
+	DropNewest DropPolicy = iota
+	// Discard the oldest queued notification to make room
+	DropOldest
+	// Wait for room, slowing down the reader
+	DropNone
+)
+
+var DefaultDispatchQueueSize = 64
+
+type DispatchPolicy struct {
+	Mode DispatchMode
+	// Queue length of DispatchSerial and per subscriber of DispatchQueue, defaults to DefaultDispatchQueueSize
+	QueueSize int
+	Drop      DropPolicy
+	// Called for every notification discarded by a full queue
+	OnDrop func(method snapcast.NotificationMethod)
+}
+
+func (p *DispatchPolicy) queueSize() int {
+	if p.QueueSize <= 0 {
+		return DefaultDispatchQueueSize
+	}
+	return p.QueueSize
+}
+
+// taskQueue runs tasks in order on one goroutine. A size of 0 is unbounded
+type taskQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	tasks  []func()
+	size   int
+	drop   DropPolicy
+	closed bool
+	// Set by finish, the goroutine ends once the queued tasks ran
+	finished bool
+}
+
+func newTaskQueue(size int, drop DropPolicy) *taskQueue {
+	var q = &taskQueue{size: size, drop: drop}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run()
+	return q
+}
+
+// push reports false if a task was dropped
+func (q *taskQueue) push(task func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ok = true
+	for q.size > 0 && len(q.tasks) >= q.size && !q.closed && !q.finished {
+		switch q.drop {
+		case DropNewest:
+			return false
+		case DropOldest:
+			q.tasks = q.tasks[1:]
+			ok = false
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed || q.finished {
+		return false
+	}
+
+	q.tasks = append(q.tasks, task)
+	q.cond.Broadcast()
+	return ok
+}
+
+func (q *taskQueue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.tasks) == 0 && !q.closed && !q.finished {
+			q.cond.Wait()
+		}
+		if q.closed || len(q.tasks) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		task := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		task()
+	}
+}
+
+func (q *taskQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.tasks = nil
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// finish stops taking tasks, those already queued still run
+func (q *taskQueue) finish() {
+	q.mu.Lock()
+	q.finished = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
@@ -0,0 +1,20 @@
+package snapclient
+
+import "errors"
+
+var (
+	ErrConnectionClosed = errors.New("snapcast connection closed")
+	// Returned by calls made after Client.Close, a closed client stays closed
+	ErrClientClosed = errors.New("snapcast client closed")
+)
+
+// HTTPStatusError is returned when the JSON-RPC endpoint answers with a non 2xx status
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "snapcast http status: " + e.Status
+}
@@ -20,6 +20,15 @@ func (n *Notifications) readErr(err error) {
 	}
 }
 
+// Handle sends msg to the channel of its method like Listen does, e.g. to replay recorded
+// notifications. It blocks until the channel is read
+func (n *Notifications) Handle(msg *snapcast.Notification) {
+	if msg.Method == nil {
+		return
+	}
+	n.handleNotification(msg)
+}
+
 func (n *Notifications) handleNotification(msg *snapcast.Notification) {
 	switch *msg.Method {
 	// --- Client
@@ -0,0 +1,154 @@
+package snapclient
+
+import (
+	"context"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// call sends a request and parses the result into T, turning a JSON-RPC error into a Go error
+func call[T any](ctx context.Context, c *Client, method snapcast.RequestMethod, params interface{}) (*T, error) {
+	res, err := c.Send(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(method, res); err != nil {
+		return nil, err
+	}
+
+	return snapcast.ParseResult[T](res.Result)
+}
+
+// responseError turns the JSON-RPC error of a response into a *snapcast.RPCError
+func responseError(method snapcast.RequestMethod, res *snapcast.Response) error {
+	if res.Error == nil {
+		return nil
+	}
+	return snapcast.NewRPCError(method, res.Error)
+}
+
+// --- Client
+
+func (c *Client) ClientGetStatus(ctx context.Context, id string) (*snapcast.ClientGetStatusResponse, error) {
+	return call[snapcast.ClientGetStatusResponse](ctx, c, snapcast.MethodClientGetStatus, &snapcast.ClientGetStatusRequest{
+		ID: id,
+	})
+}
+
+func (c *Client) ClientSetVolume(ctx context.Context, id string, volume snapcast.Volume) (*snapcast.ClientSetVolumeResponse, error) {
+	return call[snapcast.ClientSetVolumeResponse](ctx, c, snapcast.MethodClientSetVolume, &snapcast.ClientSetVolumeRequest{
+		ID:     id,
+		Volume: volume,
+	})
+}
+
+// Latency in milliseconds
+func (c *Client) ClientSetLatency(ctx context.Context, id string, latency int) (*snapcast.ClientSetLatencyResponse, error) {
+	return call[snapcast.ClientSetLatencyResponse](ctx, c, snapcast.MethodClientSetLatency, &snapcast.ClientSetLatencyRequest{
+		ID:      id,
+		Latency: latency,
+	})
+}
+
+func (c *Client) ClientSetName(ctx context.Context, id string, name string) (*snapcast.ClientSetNameResponse, error) {
+	return call[snapcast.ClientSetNameResponse](ctx, c, snapcast.MethodClientSetName, &snapcast.ClientSetNameRequest{
+		ID:   id,
+		Name: name,
+	})
+}
+
+// --- Group
+
+func (c *Client) GroupGetStatus(ctx context.Context, id string) (*snapcast.GroupGetStatusResponse, error) {
+	return call[snapcast.GroupGetStatusResponse](ctx, c, snapcast.MethodGroupGetStatus, &snapcast.GroupGetStatusRequest{
+		ID: id,
+	})
+}
+
+func (c *Client) GroupSetMute(ctx context.Context, id string, muted bool) (*snapcast.GroupSetMuteResponse, error) {
+	return call[snapcast.GroupSetMuteResponse](ctx, c, snapcast.MethodGroupSetMute, &snapcast.GroupSetMuteRequest{
+		ID:    id,
+		Muted: muted,
+	})
+}
+
+func (c *Client) GroupSetStream(ctx context.Context, id string, streamID string) (*snapcast.GroupSetStreamResponse, error) {
+	return call[snapcast.GroupSetStreamResponse](ctx, c, snapcast.MethodGroupSetStream, &snapcast.GroupSetStreamRequest{
+		ID:       id,
+		StreamID: streamID,
+	})
+}
+
+// Clients is the full list of client IDs the group should contain
+func (c *Client) GroupSetClients(ctx context.Context, id string, clients []string) (*snapcast.GroupSetClientsResponse, error) {
+	return call[snapcast.GroupSetClientsResponse](ctx, c, snapcast.MethodGroupSetClients, &snapcast.GroupSetClientsRequest{
+		ID:      id,
+		Clients: clients,
+	})
+}
+
+func (c *Client) GroupSetName(ctx context.Context, id string, name string) (*snapcast.GroupSetNameResponse, error) {
+	return call[snapcast.GroupSetNameResponse](ctx, c, snapcast.MethodGroupSetName, &snapcast.GroupSetNameRequest{
+		ID:   id,
+		Name: name,
+	})
+}
+
+// --- Server
+
+func (c *Client) ServerGetRPCVersion(ctx context.Context) (*snapcast.ServerGetRPCVersionResponse, error) {
+	return call[snapcast.ServerGetRPCVersionResponse](ctx, c, snapcast.MethodServerGetRPCVersion, &snapcast.ServerGetRPCVersion{})
+}
+
+func (c *Client) ServerGetStatus(ctx context.Context) (*snapcast.ServerGetStatusResponse, error) {
+	res, err := call[snapcast.ServerGetStatusResponse](ctx, c, snapcast.MethodServerGetStatus, &snapcast.ServerGetStatusRequest{})
+	if err == nil {
+		c.streams.setServer(&res.Server)
+	}
+	return res, err
+}
+
+func (c *Client) ServerDeleteClient(ctx context.Context, id string) (*snapcast.ServerDeleteClientResponse, error) {
+	return call[snapcast.ServerDeleteClientResponse](ctx, c, snapcast.MethodServerDeleteClient, &snapcast.ServerDeleteClient{
+		ID: id,
+	})
+}
+
+// --- Stream
+
+func (c *Client) StreamAddStream(ctx context.Context, streamURI string) (*snapcast.StreamAddStreamResponse, error) {
+	return call[snapcast.StreamAddStreamResponse](ctx, c, snapcast.MethodStreamAddStream, &snapcast.StreamAddStream{
+		StreamUri: streamURI,
+	})
+}
+
+// StreamAddStreamURI validates uri before adding it
+func (c *Client) StreamAddStreamURI(ctx context.Context, uri *snapcast.StreamURI) (*snapcast.StreamAddStreamResponse, error) {
+	if err := uri.Validate(); err != nil {
+		return nil, err
+	}
+	return c.StreamAddStream(ctx, uri.String())
+}
+
+func (c *Client) StreamRemoveStream(ctx context.Context, id string) (*snapcast.StreamRemoveStreamResponse, error) {
+	return call[snapcast.StreamRemoveStreamResponse](ctx, c, snapcast.MethodStreamRemoveStream, &snapcast.StreamRemoveStream{
+		ID: id,
+	})
+}
+
+// Params is optional and depends on the command, see snapcast.StreamCommand
+func (c *Client) StreamControl(ctx context.Context, id string, command snapcast.StreamCommand, params interface{}) (*snapcast.StreamControlResponse, error) {
+	return call[snapcast.StreamControlResponse](ctx, c, snapcast.MethodStreamControl, &snapcast.StreamControl{
+		ID:      id,
+		Command: command,
+		Params:  params,
+	})
+}
+
+func (c *Client) StreamSetProperty(ctx context.Context, id string, property string, value interface{}) (*snapcast.StreamSetPropertyResponse, error) {
+	return call[snapcast.StreamSetPropertyResponse](ctx, c, snapcast.MethodStreamSetProperty, &snapcast.StreamSetProperty{
+		ID:       id,
+		Property: property,
+		Value:    value,
+	})
+}
@@ -0,0 +1,120 @@
+package snapclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"golang.org/x/time/rate"
+)
+
+// TestMethodsWire checks the params of each typed method and the parsing of its result against
+// what snapserver's control API documents
+func TestMethodsWire(t *testing.T) {
+	var (
+		params = make(chan string, 1)
+		result string
+		srv    = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ID     int             `json:"id"`
+				Params json.RawMessage `json:"params"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			params <- string(req.Params)
+			fmt.Fprintf(w, `{"id": %d, "jsonrpc": "2.0", "result": %s}`, req.ID, result)
+		}))
+		ctx = testContext(t)
+		c   = New(&Options{Host: strings.TrimPrefix(srv.URL, "http://"), RateLimiter: rate.NewLimiter(rate.Inf, 0)})
+	)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name   string
+		call   func() (interface{}, error)
+		params string
+		result string
+		want   interface{}
+	}{
+		{
+			name:   "Client.SetVolume",
+			call:   func() (interface{}, error) { return c.ClientSetVolume(ctx, "a", snapcast.Volume{Percent: 36}) },
+			params: `{"id": "a", "volume": {"muted": false, "percent": 36}}`,
+			result: `{"volume": {"muted": false, "percent": 36}}`,
+			want:   &snapcast.ClientSetVolumeResponse{Volume: snapcast.Volume{Percent: 36}},
+		},
+		{
+			name:   "Client.SetLatency",
+			call:   func() (interface{}, error) { return c.ClientSetLatency(ctx, "a", 10) },
+			params: `{"id": "a", "latency": 10}`,
+			result: `{"latency": 10}`,
+			want:   &snapcast.ClientSetLatencyResponse{Latency: 10},
+		},
+		{
+			name:   "Client.SetName",
+			call:   func() (interface{}, error) { return c.ClientSetName(ctx, "a", "Laptop") },
+			params: `{"id": "a", "name": "Laptop"}`,
+			result: `{"name": "Laptop"}`,
+			want:   &snapcast.ClientSetNameResponse{Name: "Laptop"},
+		},
+		{
+			name:   "Group.SetMute",
+			call:   func() (interface{}, error) { return c.GroupSetMute(ctx, "g", true) },
+			params: `{"id": "g", "mute": true}`,
+			result: `{"mute": true}`,
+			want:   &snapcast.GroupSetMuteResponse{Muted: true},
+		},
+		{
+			name:   "Group.SetStream",
+			call:   func() (interface{}, error) { return c.GroupSetStream(ctx, "g", "stream 1") },
+			params: `{"id": "g", "stream_id": "stream 1"}`,
+			result: `{"stream_id": "stream 1"}`,
+			want:   &snapcast.GroupSetStreamResponse{StreamID: "stream 1"},
+		},
+		{
+			name:   "Group.SetClients",
+			call:   func() (interface{}, error) { return c.GroupSetClients(ctx, "g", []string{"a", "b"}) },
+			params: `{"id": "g", "clients": ["a", "b"]}`,
+			result: `{"server": {"groups": [{"id": "g", "clients": [{"id": "a"}, {"id": "b"}]}]}}`,
+			want: &snapcast.GroupSetClientsResponse{Server: snapcast.Server{Groups: []snapcast.Group{
+				{ID: "g", Clients: []snapcast.Client{{ID: "a"}, {ID: "b"}}},
+			}}},
+		},
+		{
+			name:   "Group.SetName",
+			call:   func() (interface{}, error) { return c.GroupSetName(ctx, "g", "Kitchen") },
+			params: `{"id": "g", "name": "Kitchen"}`,
+			result: `{"name": "Kitchen"}`,
+			want:   &snapcast.GroupSetNameResponse{Name: "Kitchen"},
+		},
+		{
+			name:   "Stream.RemoveStream",
+			call:   func() (interface{}, error) { return c.StreamRemoveStream(ctx, "s") },
+			params: `{"id": "s"}`,
+			result: `{"stream_id": "s"}`,
+			want:   &snapcast.StreamRemoveStreamResponse{StreamId: "s"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result = tc.result
+			res, err := tc.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want interface{}
+			json.Unmarshal([]byte(<-params), &got)
+			json.Unmarshal([]byte(tc.params), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("params %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(res, tc.want) {
+				t.Errorf("result %+v, want %+v", res, tc.want)
+			}
+		})
+	}
+}
@@ -1,47 +1,102 @@
 package snapclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/ConnorsApps/snapcast-go/snapcast"
-	"github.com/coder/websocket"
 )
 
-func (c *Client) readNotifications(ctx context.Context, n *Notifications, msgChan chan *snapcast.Notification, wsClose chan error) {
+// Notifications queued per listener that is busy, e.g. with a Send waiting for its response.
+// Only a listener falling this far behind holds up reading, and with it the responses
+const notificationQueueSize = 1024
+
+// readMessages handles the messages of a connection in the order they arrive. A notification is
+// handed to the queues of every listener and handler before anything read after it, so a response
+// is only resolved once the notifications before it were handed off. Any read error ends the connection
+func (c *Client) readMessages(cn conn) {
 	for {
-		_, raw, err := c.ws.Read(ctx)
+		raw, err := cn.read(context.Background())
 		if err != nil {
-			if status := websocket.CloseStatus(err); status != -1 && status != websocket.StatusNormalClosure {
-				close(msgChan)
-				wsClose <- err
-				return
-			}
+			c.disconnected(cn, err)
+			return
+		}
+		var receivedAt = time.Now()
+		if c.onFrame != nil {
+			c.onFrame(raw, receivedAt)
+		}
 
-			if ctx.Err() != nil {
-				close(msgChan)
-				wsClose <- err
-				return
+		// Batches arrive as an array of responses or notifications
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				c.readErr(err)
+				continue
 			}
-
-			if n.MsgReaderErr != nil {
-				n.MsgReaderErr <- err
+			var answered []int
+			for _, item := range batch {
+				if id := c.handleMessage(item, receivedAt); id != nil {
+					answered = append(answered, *id)
+				}
 			}
+			c.resolveBatch(answered)
 			continue
 		}
 
-		var msg = &snapcast.Notification{}
+		c.handleMessage(raw, receivedAt)
+	}
+}
 
-		if err := json.Unmarshal(raw, msg); err != nil {
-			if n.MsgReaderErr != nil {
-				n.MsgReaderErr <- err
-			}
-			continue
+// handleMessage returns the ID of a response
+func (c *Client) handleMessage(raw []byte, receivedAt time.Time) *int {
+	var msg = &snapcast.Notification{}
+
+	if err := json.Unmarshal(raw, msg); err != nil {
+		c.readErr(err)
+		return nil
+	}
+	msg.ReceivedAt = receivedAt
+
+	// Responses to requests sent over the connection
+	if msg.Method == nil {
+		var res = &snapcast.Response{}
+		if err := json.Unmarshal(raw, res); err != nil {
+			c.readErr(err)
+			return nil
 		}
+		res.ReceivedAt = receivedAt
+		c.resolve(res)
+		return res.ID
+	}
+
+	if msg.Params != nil {
+		c.dispatch(msg)
+	}
+	return nil
+}
+
+func (c *Client) dispatch(msg *snapcast.Notification) {
+	c.streams.observe(msg)
+	c.dispatchHandlers(msg)
 
-		// Only process notifications, not responses to requests
-		if msg.Params != nil && msg.Method != nil {
-			msgChan <- msg
+	for _, l := range c.listenerSnapshot() {
+		l.queue.push(func() { l.n.handleNotification(msg) })
+	}
+}
+
+// readErr queues err for the MsgReaderErr of every listener, in order with the notifications
+func (c *Client) readErr(err error) {
+	for _, l := range c.listenerSnapshot() {
+		if l.n.MsgReaderErr == nil {
+			continue
 		}
+		l.queue.push(func() {
+			select {
+			case l.n.MsgReaderErr <- err:
+			case <-l.done:
+			}
+		})
 	}
 }
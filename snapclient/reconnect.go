@@ -0,0 +1,120 @@
+package snapclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+var (
+	DefaultReconnectInitialDelay = 500 * time.Millisecond
+	DefaultReconnectMaxDelay     = 30 * time.Second
+	DefaultReconnectMultiplier   = 2.0
+)
+
+// ReconnectPolicy is an exponential backoff for re-dialing a dropped Listen connection.
+// Zero values fall back to the defaults
+type ReconnectPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Randomizes each delay by up to ± this fraction, e.g. 0.2
+	Jitter float64
+	// Give up after this many failed dials, 0 retries forever
+	MaxAttempts int
+}
+
+// Delay before the given attempt, starting at 0
+func (p *ReconnectPolicy) Delay(attempt int) time.Duration {
+	var (
+		initial    = p.InitialDelay
+		max        = p.MaxDelay
+		multiplier = p.Multiplier
+	)
+	if initial <= 0 {
+		initial = DefaultReconnectInitialDelay
+	}
+	if max <= 0 {
+		max = DefaultReconnectMaxDelay
+	}
+	if multiplier < 1 {
+		multiplier = DefaultReconnectMultiplier
+	}
+
+	var delay = float64(initial)
+	for i := 0; i < attempt && delay < float64(max); i++ {
+		delay *= multiplier
+	}
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// reconnectLoop runs until a dial succeeds, the policy gives up or ctx is cancelled by Close or
+// the last listener leaving
+func (c *Client) reconnectLoop(ctx context.Context, cause error) {
+	c.emitDisconnected(ctx, cause)
+
+	var lastErr = cause
+	for attempt := 0; c.reconnect.MaxAttempts == 0 || attempt < c.reconnect.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(c.reconnect.Delay(attempt)):
+		case <-ctx.Done():
+			c.endListeners(cause)
+			return
+		}
+
+		if _, err := c.connect(ctx); errors.Is(err, ErrClientClosed) {
+			c.endListeners(err)
+			return
+		} else if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.state.Lock()
+		c.state.stopReconnect = nil
+		c.state.Unlock()
+
+		c.emitConnected(ctx)
+		return
+	}
+
+	c.endListeners(fmt.Errorf("gave up reconnecting after %d attempts: %w", c.reconnect.MaxAttempts, lastErr))
+}
+
+func (c *Client) emitDisconnected(ctx context.Context, err error) {
+	for _, l := range c.listenerSnapshot() {
+		if l.n == nil || l.n.Disconnected == nil {
+			continue
+		}
+		select {
+		case l.n.Disconnected <- err:
+		case <-l.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) emitConnected(ctx context.Context) {
+	for _, l := range c.listenerSnapshot() {
+		if l.n == nil || l.n.Connected == nil {
+			continue
+		}
+		select {
+		case l.n.Connected <- struct{}{}:
+		case <-l.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package snapclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	var p = &ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if d := p.Delay(attempt); d != want {
+			t.Errorf("attempt %d waits %s, want %s", attempt, d, want)
+		}
+	}
+
+	var defaults = &ReconnectPolicy{}
+	if d := defaults.Delay(0); d != DefaultReconnectInitialDelay {
+		t.Errorf("default initial delay %s", d)
+	}
+	if d := defaults.Delay(100); d != DefaultReconnectMaxDelay {
+		t.Errorf("default max delay %s", d)
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d < 1600*time.Millisecond || d > 2400*time.Millisecond {
+			t.Fatalf("jittered delay %s out of ±20%% of 2s", d)
+		}
+	}
+}
+
+// refusingServer accepts TCP connections and closes them at once, so every WebSocket dial fails.
+// It counts the dials
+func refusingServer(t *testing.T) (string, *atomic.Int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var dials = new(atomic.Int32)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			dials.Add(1)
+			nc.Close()
+		}
+	}()
+	return l.Addr().String(), dials
+}
+
+// deadConn stands in for a connection that just dropped
+type deadConn struct{}
+
+func (deadConn) read(ctx context.Context) ([]byte, error)    { return nil, net.ErrClosed }
+func (deadConn) write(ctx context.Context, msg []byte) error { return net.ErrClosed }
+func (deadConn) close() error                                { return nil }
+
+// dropListener registers a listener on a connection of c and drops that connection
+func dropListener(c *Client, n *Notifications) *listener {
+	var l = newListener(n)
+	c.addListener(l)
+	var cn = deadConn{}
+	c.state.Lock()
+	c.state.conn = cn
+	c.state.Unlock()
+	c.disconnected(cn, net.ErrClosed)
+	return l
+}
+
+func TestReconnectGiveUp(t *testing.T) {
+	var (
+		ctx         = testContext(t)
+		host, dials = refusingServer(t)
+		c           = New(&Options{
+			Host:      host,
+			Transport: TransportWebSocket,
+			Reconnect: &ReconnectPolicy{InitialDelay: time.Millisecond, MaxAttempts: 3},
+		})
+		n = &Notifications{Disconnected: make(chan error, 1)}
+		l = dropListener(c, n)
+	)
+	defer c.Close()
+
+	select {
+	case err := <-l.wsClose:
+		if !strings.Contains(err.Error(), "gave up reconnecting after 3 attempts") {
+			t.Errorf("closed with %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("never gave up")
+	}
+	if err := <-n.Disconnected; err != net.ErrClosed {
+		t.Errorf("disconnected with %v, want the cause", err)
+	}
+	if d := dials.Load(); d != 3 {
+		t.Errorf("dialed %d times, want 3", d)
+	}
+}
+
+func TestReconnectStopsWithoutListeners(t *testing.T) {
+	var (
+		host, dials = refusingServer(t)
+		c           = New(&Options{
+			Host:      host,
+			Transport: TransportWebSocket,
+			Reconnect: &ReconnectPolicy{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		})
+		l = dropListener(c, &Notifications{})
+	)
+	defer c.Close()
+
+	for dials.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// What Listen does once its ctx is done
+	c.removeListener(l, context.Canceled)
+	time.Sleep(10 * time.Millisecond)
+	var stopped = dials.Load()
+	time.Sleep(20 * time.Millisecond)
+	if d := dials.Load(); d != stopped {
+		t.Errorf("still dialing without listeners, %d dials after %d", d, stopped)
+	}
+}
+
+func TestCloseWhileDisconnectedUnread(t *testing.T) {
+	var (
+		ctx     = testContext(t)
+		host, _ = refusingServer(t)
+		c       = New(&Options{
+			Host:      host,
+			Transport: TransportWebSocket,
+			Reconnect: &ReconnectPolicy{InitialDelay: time.Millisecond},
+		})
+		// Nobody reads it
+		l = dropListener(c, &Notifications{Disconnected: make(chan error)})
+	)
+
+	c.Close()
+	select {
+	case <-l.wsClose:
+	case <-ctx.Done():
+		t.Fatal("the reconnect loop is stuck sending Disconnected")
+	}
+}
@@ -0,0 +1,247 @@
+package snapclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// streamCache keeps the last known properties of every stream, from Server.GetStatus
+// responses and stream notifications, so typed stream commands can be checked before sending.
+// It is only up to date while a connection delivers the notifications
+type streamCache struct {
+	sync.Mutex
+	props map[string]*snapcast.Properties
+}
+
+// reset forgets what was cached before a new connection
+func (s *streamCache) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.props = nil
+}
+
+func (s *streamCache) setServer(srv *snapcast.Server) {
+	s.Lock()
+	defer s.Unlock()
+	s.props = make(map[string]*snapcast.Properties, len(srv.Streams))
+	for _, st := range srv.Streams {
+		s.props[st.ID] = st.Properties
+	}
+}
+
+func (s *streamCache) set(id string, props *snapcast.Properties) {
+	s.Lock()
+	defer s.Unlock()
+	if s.props == nil {
+		s.props = make(map[string]*snapcast.Properties)
+	}
+	s.props[id] = props
+}
+
+func (s *streamCache) get(id string) (*snapcast.Properties, bool) {
+	s.Lock()
+	defer s.Unlock()
+	props, ok := s.props[id]
+	return props, ok
+}
+
+func (s *streamCache) observe(msg *snapcast.Notification) {
+	switch *msg.Method {
+	case snapcast.MethodStreamOnUpdate, snapcast.MethodStreamOnProperties, snapcast.MethodServerOnUpdate:
+	default:
+		return
+	}
+
+	params, err := decodeNotification(msg)
+	if err != nil {
+		return
+	}
+	switch p := params.(type) {
+	case *snapcast.StreamOnUpdate:
+		s.set(p.ID, p.Stream.Properties)
+	case *snapcast.StreamOnProperties:
+		s.set(p.ID, &p.Properties)
+	case *snapcast.ServerOnUpdate:
+		s.setServer(&p.Server)
+	}
+}
+
+// streamProperties of a stream from the cache while a connection keeps it up to date,
+// otherwise or if the stream was never seen from a fresh Server.GetStatus
+func (c *Client) streamProperties(ctx context.Context, method snapcast.RequestMethod, id string) (*snapcast.Properties, error) {
+	if c.connected() {
+		if props, ok := c.streams.get(id); ok {
+			return props, nil
+		}
+	}
+	if _, err := c.ServerGetStatus(ctx); err != nil {
+		return nil, err
+	}
+	if props, ok := c.streams.get(id); ok {
+		return props, nil
+	}
+	return nil, &snapcast.RPCError{Method: method, Code: snapcast.CodeInternalError, Message: "Stream not found"}
+}
+
+func invalidParams(method snapcast.RequestMethod, format string, args ...interface{}) error {
+	return &snapcast.RPCError{Method: method, Code: snapcast.CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+func capabilityError(method snapcast.RequestMethod, code int, id, what, property string) error {
+	return &snapcast.RPCError{
+		Method:  method,
+		Code:    code,
+		Message: fmt.Sprintf("stream %q can't %s, %s is false", id, what, property),
+	}
+}
+
+// checkControl mirrors the checks snapserver does for Stream.Control
+func checkControl(id string, props *snapcast.Properties, command snapcast.StreamCommand) error {
+	const method = snapcast.MethodStreamControl
+	if props == nil || !props.CanControl {
+		return capabilityError(method, snapcast.CodeCanControlIsFalse, id, "be controlled", "canControl")
+	}
+
+	switch command {
+	case snapcast.StreamCommandNext:
+		if !props.CanGoNext {
+			return capabilityError(method, snapcast.CodeCanGoNextIsFalse, id, "go to the next track", "canGoNext")
+		}
+	case snapcast.StreamCommandPrevious:
+		if !props.CanGoPrevious {
+			return capabilityError(method, snapcast.CodeCanGoPreviousIsFalse, id, "go to the previous track", "canGoPrevious")
+		}
+	case snapcast.StreamCommandPlay:
+		if !props.CanPlay {
+			return capabilityError(method, snapcast.CodeCanPlayIsFalse, id, "play", "canPlay")
+		}
+	case snapcast.StreamCommandPause:
+		if !props.CanPause {
+			return capabilityError(method, snapcast.CodeCanPauseIsFalse, id, "pause", "canPause")
+		}
+	case snapcast.StreamCommandPlayPause:
+		if props.PlaybackStatus == snapcast.PlaybackPlaying && !props.CanPause {
+			return capabilityError(method, snapcast.CodeCanPauseIsFalse, id, "pause", "canPause")
+		}
+		if props.PlaybackStatus != snapcast.PlaybackPlaying && !props.CanPlay {
+			return capabilityError(method, snapcast.CodeCanPlayIsFalse, id, "play", "canPlay")
+		}
+	case snapcast.StreamCommandSeek, snapcast.StreamCommandSetPosition:
+		if !props.CanSeek {
+			return capabilityError(method, snapcast.CodeCanSeekIsFalse, id, "seek", "canSeek")
+		}
+	}
+	return nil
+}
+
+// StreamSeekParams of snapcast.StreamCommandSeek, in seconds
+type StreamSeekParams struct {
+	Offset float64 `json:"offset"`
+}
+
+// StreamSetPositionParams of snapcast.StreamCommandSetPosition, in seconds
+type StreamSetPositionParams struct {
+	Position float64 `json:"position"`
+}
+
+// streamCommand checks the command against the last known properties of the stream before sending it
+func (c *Client) streamCommand(ctx context.Context, id string, command snapcast.StreamCommand, params interface{}) error {
+	props, err := c.streamProperties(ctx, snapcast.MethodStreamControl, id)
+	if err != nil {
+		return err
+	}
+	if err := checkControl(id, props, command); err != nil {
+		return err
+	}
+	_, err = c.StreamControl(ctx, id, command, params)
+	return err
+}
+
+func (c *Client) StreamPlay(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandPlay, nil)
+}
+
+func (c *Client) StreamPause(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandPause, nil)
+}
+
+func (c *Client) StreamPlayPause(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandPlayPause, nil)
+}
+
+func (c *Client) StreamStop(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandStop, nil)
+}
+
+func (c *Client) StreamNext(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandNext, nil)
+}
+
+func (c *Client) StreamPrevious(ctx context.Context, id string) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandPrevious, nil)
+}
+
+// StreamSeek moves the position by offset, negative to go back
+func (c *Client) StreamSeek(ctx context.Context, id string, offset time.Duration) error {
+	return c.streamCommand(ctx, id, snapcast.StreamCommandSeek, &StreamSeekParams{Offset: offset.Seconds()})
+}
+
+// StreamSetPosition jumps to position from the start of the track
+func (c *Client) StreamSetPosition(ctx context.Context, id string, position time.Duration) error {
+	if position < 0 {
+		return invalidParams(snapcast.MethodStreamControl, "position %s is negative", position)
+	}
+	return c.streamCommand(ctx, id, snapcast.StreamCommandSetPosition, &StreamSetPositionParams{Position: position.Seconds()})
+}
+
+// streamSetProperty checks the stream can be controlled before sending Stream.SetProperty
+func (c *Client) streamSetProperty(ctx context.Context, id string, property string, value interface{}) error {
+	const method = snapcast.MethodStreamSetProperty
+	props, err := c.streamProperties(ctx, method, id)
+	if err != nil {
+		return err
+	}
+	if props == nil || !props.CanControl {
+		return capabilityError(method, snapcast.CodeCanControlIsFalse, id, "be controlled", "canControl")
+	}
+	_, err = c.StreamSetProperty(ctx, id, property, value)
+	return err
+}
+
+func (c *Client) StreamSetLoopStatus(ctx context.Context, id string, status snapcast.LoopStatus) error {
+	switch status {
+	case snapcast.LoopNone, snapcast.LoopTrack, snapcast.LoopPlaylist:
+	default:
+		return invalidParams(snapcast.MethodStreamSetProperty, "loop status %q is not none, track or playlist", status)
+	}
+	return c.streamSetProperty(ctx, id, "loopStatus", status)
+}
+
+func (c *Client) StreamSetShuffle(ctx context.Context, id string, shuffle bool) error {
+	return c.streamSetProperty(ctx, id, "shuffle", shuffle)
+}
+
+// StreamSetVolume of the player behind the stream, 0 to 100
+func (c *Client) StreamSetVolume(ctx context.Context, id string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return invalidParams(snapcast.MethodStreamSetProperty, "volume %d is not between 0 and 100", percent)
+	}
+	return c.streamSetProperty(ctx, id, "volume", percent)
+}
+
+func (c *Client) StreamSetMute(ctx context.Context, id string, mute bool) error {
+	return c.streamSetProperty(ctx, id, "mute", mute)
+}
+
+// StreamSetRate sets the playback rate, 1 is normal speed
+func (c *Client) StreamSetRate(ctx context.Context, id string, rate float64) error {
+	if !(rate > 0) || math.IsInf(rate, 0) {
+		return invalidParams(snapcast.MethodStreamSetProperty, "rate %v is not a positive number", rate)
+	}
+	return c.streamSetProperty(ctx, id, "rate", rate)
+}
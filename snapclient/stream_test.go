@@ -0,0 +1,145 @@
+package snapclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+)
+
+func TestStreamControl(t *testing.T) {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+		c   = New(&Options{Host: srv.Host(), Transport: TransportWebSocket})
+	)
+	defer srv.Close()
+	defer c.Close()
+
+	var props = make(chan *snapcast.StreamOnProperties, 1)
+	c.OnStreamProperties(func(_ context.Context, p *snapcast.StreamOnProperties) { props <- p })
+	if _, err := c.Listen(ctx, &Notifications{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Properties are fetched on first use
+	if err := c.StreamSetPosition(ctx, "default", 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StreamSeek(ctx, "default", -30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if pos := srv.State().Streams[0].Properties.Position; pos != 60 {
+		t.Errorf("position = %v, want 60", pos)
+	}
+	if err := c.StreamPlay(ctx, "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The control script stops allowing seeks, the cache follows the notification
+	var p = *srv.State().Streams[0].Properties
+	p.CanSeek = false
+	p.CanGoNext = false
+	srv.SetStreamProperties("default", p)
+	select {
+	case <-props:
+	case <-ctx.Done():
+		t.Fatal("no Stream.OnProperties")
+	}
+
+	var sent = len(srv.Requests())
+	if err := c.StreamSeek(ctx, "default", time.Second); !errors.Is(err, snapcast.ErrCanSeekIsFalse) {
+		t.Errorf("expected ErrCanSeekIsFalse, got %v", err)
+	}
+	if err := c.StreamNext(ctx, "default"); !errors.Is(err, snapcast.ErrCanGoNextIsFalse) {
+		t.Errorf("expected ErrCanGoNextIsFalse, got %v", err)
+	}
+	if len(srv.Requests()) != sent {
+		t.Error("unsupported commands were sent")
+	}
+
+	if err := c.StreamPause(ctx, "missing"); !errors.Is(err, snapcast.ErrStreamNotFound) {
+		t.Errorf("expected ErrStreamNotFound, got %v", err)
+	}
+	if err := c.StreamSetPosition(ctx, "default", -time.Second); !errors.Is(err, snapcast.ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams, got %v", err)
+	}
+}
+
+func TestStreamControlHTTP(t *testing.T) {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+		c   = New(&Options{Host: srv.Host()})
+	)
+	defer srv.Close()
+
+	var p = *srv.State().Streams[0].Properties
+	p.CanGoNext = false
+	srv.SetStreamProperties("default", p)
+	if err := c.StreamNext(ctx, "default"); !errors.Is(err, snapcast.ErrCanGoNextIsFalse) {
+		t.Errorf("expected ErrCanGoNextIsFalse, got %v", err)
+	}
+
+	// Nothing tells an HTTP client about the change, the properties are fetched again
+	p.CanGoNext = true
+	srv.SetStreamProperties("default", p)
+	if err := c.StreamNext(ctx, "default"); err != nil {
+		t.Errorf("checked against stale properties: %v", err)
+	}
+}
+
+func TestStreamSetProperties(t *testing.T) {
+	var (
+		srv = snapcasttest.NewServer(nil)
+		ctx = testContext(t)
+		c   = New(&Options{Host: srv.Host(), Transport: TransportHTTP})
+	)
+	defer srv.Close()
+	defer c.Close()
+
+	for _, set := range []func() error{
+		func() error { return c.StreamSetLoopStatus(ctx, "default", snapcast.LoopPlaylist) },
+		func() error { return c.StreamSetShuffle(ctx, "default", true) },
+		func() error { return c.StreamSetVolume(ctx, "default", 42) },
+		func() error { return c.StreamSetMute(ctx, "default", true) },
+		func() error { return c.StreamSetRate(ctx, "default", 1.5) },
+	} {
+		if err := set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var props = srv.State().Streams[0].Properties
+	if props.LoopStatus != snapcast.LoopPlaylist || !props.Shuffle || props.Volume != 42 || !props.Mute || props.Rate != 1.5 {
+		t.Errorf("properties = %+v", props)
+	}
+
+	var sent = len(srv.Requests())
+	for _, err := range []error{
+		c.StreamSetLoopStatus(ctx, "default", "forever"),
+		c.StreamSetVolume(ctx, "default", 101),
+		c.StreamSetVolume(ctx, "default", -1),
+		c.StreamSetRate(ctx, "default", 0),
+	} {
+		if !errors.Is(err, snapcast.ErrInvalidParams) {
+			t.Errorf("expected ErrInvalidParams, got %v", err)
+		}
+	}
+	if len(srv.Requests()) != sent {
+		t.Error("invalid values were sent")
+	}
+
+	// Known after the next status
+	var p = *props
+	p.CanControl = false
+	srv.SetStreamProperties("default", p)
+	if _, err := c.ServerGetStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StreamSetMute(ctx, "default", false); !errors.Is(err, snapcast.ErrCanControlIsFalse) {
+		t.Errorf("expected ErrCanControlIsFalse, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package snapclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Subscription is a registered notification handler.
+// Handlers are called while a connection is being read, i.e. during Listen or,
+// with TransportWebSocket and TransportTCP, while the shared connection is open
+type Subscription struct {
+	c      *Client
+	method snapcast.NotificationMethod // Empty for every method
+	ctx    context.Context
+	cancel context.CancelFunc
+	handle func(ctx context.Context, msg *snapcast.Notification, params interface{})
+	queue  *taskQueue // Only for DispatchQueue
+}
+
+type subscriptions struct {
+	sync.Mutex
+	subs   map[*Subscription]struct{}
+	serial *taskQueue
+}
+
+// stopSerial ends the goroutine of DispatchSerial after the queued calls
+func (c *Client) stopSerial() {
+	c.subs.Lock()
+	defer c.subs.Unlock()
+	if c.subs.serial != nil {
+		c.subs.serial.finish()
+		c.subs.serial = nil
+	}
+}
+
+// Unsubscribe stops further calls and cancels the context passed to the handler
+func (s *Subscription) Unsubscribe() {
+	s.c.subs.Lock()
+	delete(s.c.subs.subs, s)
+	s.c.subs.Unlock()
+
+	s.cancel()
+	if s.queue != nil {
+		s.queue.close()
+	}
+}
+
+func (c *Client) subscribe(method snapcast.NotificationMethod, handle func(ctx context.Context, msg *snapcast.Notification, params interface{})) *Subscription {
+	var s = &Subscription{c: c, method: method, handle: handle}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if c.dispatchPolicy.Mode == DispatchQueue {
+		s.queue = newTaskQueue(c.dispatchPolicy.queueSize(), c.dispatchPolicy.Drop)
+	}
+
+	c.subs.Lock()
+	if c.subs.subs == nil {
+		c.subs.subs = make(map[*Subscription]struct{})
+	}
+	c.subs.subs[s] = struct{}{}
+	c.subs.Unlock()
+
+	return s
+}
+
+func subscribe[T any](c *Client, method snapcast.NotificationMethod, fn func(ctx context.Context, p *T)) *Subscription {
+	return c.subscribe(method, func(ctx context.Context, _ *snapcast.Notification, params interface{}) {
+		fn(ctx, params.(*T))
+	})
+}
+
+// dispatchHandlers decodes the notification and hands it to every matching subscription,
+// each with params of its own as handlers may keep or modify them
+func (c *Client) dispatchHandlers(msg *snapcast.Notification) {
+	var matches []*Subscription
+	c.subs.Lock()
+	for s := range c.subs.subs {
+		if s.method == "" || s.method == *msg.Method {
+			matches = append(matches, s)
+		}
+	}
+	// Started on demand and stopped by Close
+	if c.subs.serial == nil && len(matches) > 0 && c.dispatchPolicy.Mode == DispatchSerial {
+		c.subs.serial = newTaskQueue(c.dispatchPolicy.queueSize(), DropNone)
+	}
+	var serial = c.subs.serial
+	c.subs.Unlock()
+
+	if len(matches) == 0 {
+		return
+	}
+
+	params, err := decodeNotification(msg)
+	if err != nil {
+		c.readErr(err)
+	}
+
+	var handedOut bool
+	for _, s := range matches {
+		// Typed handlers can't be called without params
+		if s.method != "" && params == nil {
+			continue
+		}
+
+		var p = params
+		if handedOut && p != nil {
+			// Decoded fine the first time
+			p, _ = decodeNotification(msg)
+		}
+		handedOut = true
+
+		task := func() {
+			if s.ctx.Err() == nil {
+				s.handle(s.ctx, msg, p)
+			}
+		}
+
+		switch c.dispatchPolicy.Mode {
+		case DispatchGoroutine:
+			go task()
+		case DispatchQueue:
+			if !s.queue.push(task) && c.dispatchPolicy.OnDrop != nil {
+				c.dispatchPolicy.OnDrop(*msg.Method)
+			}
+		default:
+			serial.push(task)
+		}
+	}
+}
+
+// decodeNotification parses the params into the type matching the method, nil for unknown methods
+func decodeNotification(msg *snapcast.Notification) (interface{}, error) {
+	newParams, ok := notificationTypes[*msg.Method]
+	if !ok {
+		return nil, nil
+	}
+
+	var p = newParams()
+	if err := marshalJSON(msg.Params, p); err != nil {
+		return nil, fmt.Errorf("%s: %w", *msg.Method, err)
+	}
+	return p, nil
+}
+
+var notificationTypes = map[snapcast.NotificationMethod]func() interface{}{
+	snapcast.MethodClientOnConnect:        func() interface{} { return &snapcast.ClientOnConnect{} },
+	snapcast.MethodClientOnDisconnect:     func() interface{} { return &snapcast.ClientOnDisconnect{} },
+	snapcast.MethodClientOnVolumeChanged:  func() interface{} { return &snapcast.ClientOnVolumeChanged{} },
+	snapcast.MethodClientOnLatencyChanged: func() interface{} { return &snapcast.ClientOnLatencyChanged{} },
+	snapcast.MethodClientOnNameChanged:    func() interface{} { return &snapcast.ClientOnNameChanged{} },
+	snapcast.MethodGroupOnMute:            func() interface{} { return &snapcast.GroupOnMute{} },
+	snapcast.MethodGroupOnStreamChanged:   func() interface{} { return &snapcast.GroupOnStreamChanged{} },
+	snapcast.MethodGroupOnNameChanged:     func() interface{} { return &snapcast.GroupOnNameChanged{} },
+	snapcast.MethodStreamOnUpdate:         func() interface{} { return &snapcast.StreamOnUpdate{} },
+	snapcast.MethodStreamOnProperties:     func() interface{} { return &snapcast.StreamOnProperties{} },
+	snapcast.MethodServerOnUpdate:         func() interface{} { return &snapcast.ServerOnUpdate{} },
+}
+
+// OnNotification is called with every raw notification, whatever the method
+func (c *Client) OnNotification(fn func(ctx context.Context, msg *snapcast.Notification)) *Subscription {
+	return c.subscribe("", func(ctx context.Context, msg *snapcast.Notification, _ interface{}) {
+		fn(ctx, msg)
+	})
+}
+
+// --- Client
+
+func (c *Client) OnClientConnect(fn func(ctx context.Context, p *snapcast.ClientOnConnect)) *Subscription {
+	return subscribe(c, snapcast.MethodClientOnConnect, fn)
+}
+
+func (c *Client) OnClientDisconnect(fn func(ctx context.Context, p *snapcast.ClientOnDisconnect)) *Subscription {
+	return subscribe(c, snapcast.MethodClientOnDisconnect, fn)
+}
+
+func (c *Client) OnClientVolumeChanged(fn func(ctx context.Context, p *snapcast.ClientOnVolumeChanged)) *Subscription {
+	return subscribe(c, snapcast.MethodClientOnVolumeChanged, fn)
+}
+
+func (c *Client) OnClientLatencyChanged(fn func(ctx context.Context, p *snapcast.ClientOnLatencyChanged)) *Subscription {
+	return subscribe(c, snapcast.MethodClientOnLatencyChanged, fn)
+}
+
+func (c *Client) OnClientNameChanged(fn func(ctx context.Context, p *snapcast.ClientOnNameChanged)) *Subscription {
+	return subscribe(c, snapcast.MethodClientOnNameChanged, fn)
+}
+
+// --- Group
+
+func (c *Client) OnGroupMute(fn func(ctx context.Context, p *snapcast.GroupOnMute)) *Subscription {
+	return subscribe(c, snapcast.MethodGroupOnMute, fn)
+}
+
+func (c *Client) OnGroupStreamChanged(fn func(ctx context.Context, p *snapcast.GroupOnStreamChanged)) *Subscription {
+	return subscribe(c, snapcast.MethodGroupOnStreamChanged, fn)
+}
+
+func (c *Client) OnGroupNameChanged(fn func(ctx context.Context, p *snapcast.GroupOnNameChanged)) *Subscription {
+	return subscribe(c, snapcast.MethodGroupOnNameChanged, fn)
+}
+
+// --- Stream
+
+func (c *Client) OnStreamUpdate(fn func(ctx context.Context, p *snapcast.StreamOnUpdate)) *Subscription {
+	return subscribe(c, snapcast.MethodStreamOnUpdate, fn)
+}
+
+func (c *Client) OnStreamProperties(fn func(ctx context.Context, p *snapcast.StreamOnProperties)) *Subscription {
+	return subscribe(c, snapcast.MethodStreamOnProperties, fn)
+}
+
+// --- Server
+
+func (c *Client) OnServerUpdate(fn func(ctx context.Context, p *snapcast.ServerOnUpdate)) *Subscription {
+	return subscribe(c, snapcast.MethodServerOnUpdate, fn)
+}
@@ -0,0 +1,153 @@
+package snapclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+func testNotification(method snapcast.NotificationMethod, params interface{}) *snapcast.Notification {
+	return &snapcast.Notification{JsonRPC: "2.0", Method: &method, Params: params}
+}
+
+func TestSubscribeSerial(t *testing.T) {
+	var (
+		c      = New(&Options{})
+		got    = make(chan int, 3)
+		raw    = make(chan snapcast.NotificationMethod, 3)
+		volume = c.OnClientVolumeChanged(func(ctx context.Context, p *snapcast.ClientOnVolumeChanged) {
+			got <- p.Volume.Percent
+		})
+	)
+	c.OnNotification(func(ctx context.Context, msg *snapcast.Notification) {
+		raw <- *msg.Method
+	})
+
+	c.dispatchHandlers(testNotification(snapcast.MethodClientOnVolumeChanged, map[string]interface{}{
+		"id": "a", "volume": map[string]interface{}{"percent": 10},
+	}))
+	c.dispatchHandlers(testNotification(snapcast.MethodGroupOnMute, map[string]interface{}{"id": "g", "mute": true}))
+
+	if p := <-got; p != 10 {
+		t.Errorf("volume = %d, want 10", p)
+	}
+	if m := <-raw; m != snapcast.MethodClientOnVolumeChanged {
+		t.Errorf("first raw notification = %s", m)
+	}
+	if m := <-raw; m != snapcast.MethodGroupOnMute {
+		t.Errorf("second raw notification = %s", m)
+	}
+
+	volume.Unsubscribe()
+	c.dispatchHandlers(testNotification(snapcast.MethodClientOnVolumeChanged, map[string]interface{}{"id": "a"}))
+	<-raw
+	select {
+	case p := <-got:
+		t.Errorf("handler called after Unsubscribe with %d", p)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSubscribeQueueDrop(t *testing.T) {
+	var (
+		dropped = make(chan snapcast.NotificationMethod, 10)
+		c       = New(&Options{Dispatch: DispatchPolicy{
+			Mode:      DispatchQueue,
+			QueueSize: 1,
+			Drop:      DropNewest,
+			OnDrop:    func(m snapcast.NotificationMethod) { dropped <- m },
+		}})
+		block = make(chan struct{})
+		calls = make(chan string, 10)
+	)
+	c.OnGroupNameChanged(func(ctx context.Context, p *snapcast.GroupOnNameChanged) {
+		<-block
+		calls <- p.Name
+	})
+
+	for _, name := range []string{"a", "b", "c"} {
+		c.dispatchHandlers(testNotification(snapcast.MethodGroupOnNameChanged, map[string]interface{}{"id": "g", "name": name}))
+		// Let the first call start so it holds no queue slot
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(block)
+
+	if n := <-calls; n != "a" {
+		t.Errorf("first call = %s", n)
+	}
+	if n := <-calls; n != "b" {
+		t.Errorf("second call = %s", n)
+	}
+	if m := <-dropped; m != snapcast.MethodGroupOnNameChanged {
+		t.Errorf("dropped = %s", m)
+	}
+}
+
+func TestSubscribeSerialBounded(t *testing.T) {
+	var (
+		c     = New(&Options{Dispatch: DispatchPolicy{QueueSize: 1}})
+		block = make(chan struct{})
+		calls = make(chan string, 10)
+		sent  = make(chan struct{})
+	)
+	c.OnGroupNameChanged(func(ctx context.Context, p *snapcast.GroupOnNameChanged) {
+		<-block
+		calls <- p.Name
+	})
+
+	// One call runs, one waits in the queue and the third waits for room
+	go func() {
+		for _, name := range []string{"a", "b", "c"} {
+			c.dispatchHandlers(testNotification(snapcast.MethodGroupOnNameChanged, map[string]interface{}{"id": "g", "name": name}))
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("the serial queue grew past its size")
+	case <-time.After(30 * time.Millisecond):
+	}
+	close(block)
+	<-sent
+	for _, want := range []string{"a", "b", "c"} {
+		if n := <-calls; n != want {
+			t.Errorf("call = %s, want %s", n, want)
+		}
+	}
+
+	// Close stops the goroutine, the next notification starts it again
+	c.Close()
+	if c.subs.serial != nil {
+		t.Error("Close didn't stop the serial queue")
+	}
+	c.dispatchHandlers(testNotification(snapcast.MethodGroupOnNameChanged, map[string]interface{}{"id": "g", "name": "d"}))
+	if n := <-calls; n != "d" {
+		t.Errorf("call after Close = %s", n)
+	}
+}
+
+func TestSubscribeOwnParams(t *testing.T) {
+	var (
+		c   = New(&Options{Dispatch: DispatchPolicy{Mode: DispatchGoroutine}})
+		got = make(chan *snapcast.ClientOnVolumeChanged, 2)
+	)
+	for range 2 {
+		c.OnClientVolumeChanged(func(ctx context.Context, p *snapcast.ClientOnVolumeChanged) {
+			got <- p
+		})
+	}
+
+	c.dispatchHandlers(testNotification(snapcast.MethodClientOnVolumeChanged, map[string]interface{}{
+		"id": "a", "volume": map[string]interface{}{"percent": 10},
+	}))
+	var a, b = <-got, <-got
+	if a == b {
+		t.Error("subscriptions share the decoded params")
+	}
+	if a.Volume.Percent != 10 || b.Volume.Percent != 10 {
+		t.Errorf("volumes %d and %d, want 10", a.Volume.Percent, b.Volume.Percent)
+	}
+}
@@ -0,0 +1,78 @@
+package snapclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// tcpConn frames JSON-RPC messages with newlines
+type tcpConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	writeMu sync.Mutex
+}
+
+// newTCPConn reads lines up to wsReadLimit, a longer line ends the connection
+func newTCPConn(nc net.Conn) *tcpConn {
+	var scanner = bufio.NewScanner(nc)
+	scanner.Buffer(nil, wsReadLimit)
+	return &tcpConn{conn: nc, scanner: scanner}
+}
+
+func (c *Client) tcpConnect(ctx context.Context) (conn, error) {
+	var (
+		nc  net.Conn
+		err error
+	)
+
+	if c.secureConnection {
+		nc, err = (&tls.Dialer{}).DialContext(ctx, "tcp", c.host)
+	} else {
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", c.host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to snapcast at '%s', err: %w", c.host, err)
+	}
+
+	return newTCPConn(nc), nil
+}
+
+func (t *tcpConn) read(ctx context.Context) ([]byte, error) {
+	for t.scanner.Scan() {
+		// The scanner reuses its buffer
+		if line := bytes.TrimSpace(t.scanner.Bytes()); len(line) > 0 {
+			return bytes.Clone(line), nil
+		}
+	}
+	if err := t.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (t *tcpConn) write(ctx context.Context, msg []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	var deadline time.Time
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d
+	}
+	if err := t.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := t.conn.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *tcpConn) close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,91 @@
+package snapclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPFraming(t *testing.T) {
+	var (
+		ctx            = testContext(t)
+		client, server = net.Pipe()
+		tc             = newTCPConn(client)
+	)
+	defer tc.close()
+	defer server.Close()
+
+	// Several messages in one write, blank lines, CRLF and a message split across writes
+	go func() {
+		server.Write([]byte("{\"id\":1}\n\n{\"id\":2}\r\n{\"id\""))
+		time.Sleep(5 * time.Millisecond)
+		server.Write([]byte(":3}\n"))
+	}()
+	for _, want := range []string{`{"id":1}`, `{"id":2}`, `{"id":3}`} {
+		raw, err := tc.read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != want {
+			t.Errorf("read %q, want %q", raw, want)
+		}
+	}
+
+	// Every write is one line
+	go tc.write(ctx, []byte(`{"id":4}`))
+	line, err := bufio.NewReader(server).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "{\"id\":4}\n" {
+		t.Errorf("wrote %q", line)
+	}
+
+	server.Close()
+	if _, err := tc.read(ctx); err == nil {
+		t.Errorf("read after close = %v", err)
+	}
+}
+
+func TestTCPWriteDeadline(t *testing.T) {
+	var (
+		client, server = net.Pipe()
+		tc             = newTCPConn(client)
+	)
+	defer tc.close()
+	defer server.Close()
+
+	// Nobody reads the other end of the pipe
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tc.write(ctx, []byte(`{}`)); err == nil {
+		t.Error("write didn't time out")
+	}
+}
+
+func TestTCPLineLimit(t *testing.T) {
+	var (
+		ctx            = testContext(t)
+		client, server = net.Pipe()
+		tc             = newTCPConn(client)
+	)
+	defer tc.close()
+	defer server.Close()
+
+	// A peer that never sends a newline
+	go func() {
+		var chunk = bytes.Repeat([]byte("x"), 64<<10)
+		for {
+			if _, err := server.Write(chunk); err != nil {
+				return
+			}
+		}
+	}()
+	if _, err := tc.read(ctx); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("read = %v, want bufio.ErrTooLong", err)
+	}
+}
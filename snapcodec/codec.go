@@ -0,0 +1,82 @@
+// Package snapcodec decodes the audio snapserver sends into interleaved PCM.
+// Decoders are registered by codec name, pcm and flac are always available and opus
+// is built with the opus tag and cgo
+package snapcodec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+)
+
+// Codec names snapserver uses in CodecHeader
+const (
+	PCM    = snapproto.CodecPCM
+	FLAC   = "flac"
+	Opus   = "opus"
+	Vorbis = "ogg"
+)
+
+var ErrUnsupportedCodec = errors.New("snapcodec: unsupported codec")
+
+// Decoder turns WireChunk payloads into interleaved little endian PCM in Format.
+// Decoders holding resources outside of Go also implement io.Closer
+type Decoder interface {
+	Format() snapcast.SampleFormat
+	Decode(chunk []byte) ([]byte, error)
+}
+
+// Factory creates a decoder from the codec header payload
+type Factory func(header []byte) (Decoder, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a codec available to NewDecoder, replacing any decoder registered for it
+func Register(codec string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[codec] = f
+}
+
+// Codecs that have a registered decoder, sorted
+func Codecs() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var codecs = make([]string, 0, len(factories))
+	for codec := range factories {
+		codecs = append(codecs, codec)
+	}
+	sort.Strings(codecs)
+	return codecs
+}
+
+// NewDecoder for the codec named in the header
+func NewDecoder(h *snapproto.CodecHeader) (Decoder, error) {
+	mu.RLock()
+	f, ok := factories[h.Codec]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, have %v", ErrUnsupportedCodec, h.Codec, Codecs())
+	}
+
+	dec, err := f(h.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("snapcodec: %s header: %w", h.Codec, err)
+	}
+	return dec, nil
+}
+
+// putSample writes the low bytes of v little endian, 24 bit samples take 4 bytes like in snapcast
+func putSample(b []byte, v int32, size int) {
+	for i := 0; i < size; i++ {
+		b[i] = byte(v >> (8 * i))
+	}
+}
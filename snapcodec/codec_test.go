@@ -0,0 +1,160 @@
+package snapcodec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+func TestPCM(t *testing.T) {
+	var sf = snapcast.SampleFormat{Rate: 44100, Bits: 16, Channels: 2}
+	dec, err := NewDecoder(snapproto.PCMHeader(sf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Format() != sf {
+		t.Errorf("format = %s", dec.Format())
+	}
+	if out, err := dec.Decode([]byte{1, 2, 3, 4}); err != nil || !bytes.Equal(out, []byte{1, 2, 3, 4}) {
+		t.Errorf("decoded %x, %v", out, err)
+	}
+	if _, err := dec.Decode([]byte{1, 2, 3}); err == nil {
+		t.Error("expected an error for a partial frame")
+	}
+}
+
+// encodeFLAC returns the header and one chunk per group of frames, like snapserver sends them
+func encodeFLAC(t *testing.T, sf snapcast.SampleFormat, samples [][]int32, framesPerChunk int) ([]byte, [][]byte) {
+	const blockSize = 480
+
+	var buf bytes.Buffer
+	enc, err := flac.NewEncoder(&buf, &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    uint32(sf.Rate),
+		NChannels:     uint8(sf.Channels),
+		BitsPerSample: uint8(sf.Bits),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		header = append([]byte(nil), buf.Bytes()...)
+		chunks [][]byte
+	)
+	buf.Reset()
+
+	for start, n := 0, 0; start < len(samples[0]); start, n = start+blockSize, n+1 {
+		var f = &frame.Frame{Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         blockSize,
+			SampleRate:        uint32(sf.Rate),
+			Channels:          frame.ChannelsLR,
+			BitsPerSample:     uint8(sf.Bits),
+		}}
+		for _, ch := range samples {
+			f.Subframes = append(f.Subframes, &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   append([]int32(nil), ch[start:start+blockSize]...),
+				NSamples:  blockSize,
+			})
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+		if (n+1)%framesPerChunk == 0 {
+			chunks = append(chunks, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+	}
+	return header, chunks
+}
+
+func TestFLAC(t *testing.T) {
+	for _, sf := range []snapcast.SampleFormat{
+		{Rate: 48000, Bits: 16, Channels: 2},
+		{Rate: 44100, Bits: 24, Channels: 2},
+	} {
+		t.Run(sf.String(), func(t *testing.T) {
+			// A sine on the left and its inverse on the right, 40ms
+			var (
+				n       = 4 * 480
+				peak    = float64(int32(1)<<(sf.Bits-1) - 1)
+				samples = [][]int32{make([]int32, n), make([]int32, n)}
+			)
+			for i := 0; i < n; i++ {
+				var v = int32(peak * math.Sin(float64(i)/10))
+				samples[0][i], samples[1][i] = v, -v
+			}
+			header, chunks := encodeFLAC(t, sf, samples, 2)
+
+			dec, err := NewDecoder(&snapproto.CodecHeader{Codec: FLAC, Payload: header})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dec.Format() != sf {
+				t.Fatalf("format = %s", dec.Format())
+			}
+
+			var pcm []byte
+			for _, chunk := range chunks {
+				out, err := dec.Decode(chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pcm = append(pcm, out...)
+			}
+
+			var size = sf.SampleSize()
+			if len(pcm) != n*sf.FrameSize() {
+				t.Fatalf("decoded %d bytes, want %d", len(pcm), n*sf.FrameSize())
+			}
+			for i := 0; i < n; i++ {
+				for ch := range samples {
+					var got int32
+					if off := (i*2 + ch) * size; size == 2 {
+						got = int32(int16(binary.LittleEndian.Uint16(pcm[off:])))
+					} else {
+						got = int32(binary.LittleEndian.Uint32(pcm[off:]))
+					}
+					if got != samples[ch][i] {
+						t.Fatalf("sample %d channel %d = %d, want %d", i, ch, got, samples[ch][i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	var codecs = Codecs()
+	if len(codecs) < 2 || codecs[0] != FLAC {
+		t.Errorf("codecs = %v", codecs)
+	}
+
+	if _, err := NewDecoder(&snapproto.CodecHeader{Codec: Vorbis}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Errorf("expected ErrUnsupportedCodec, got %v", err)
+	}
+	if _, err := NewDecoder(&snapproto.CodecHeader{Codec: PCM, Payload: []byte("junk")}); err == nil {
+		t.Error("expected an error for a bad header")
+	}
+}
+
+func TestOpusHeader(t *testing.T) {
+	var header = []byte{'S', 'U', 'P', 'O', 0x80, 0xbb, 0, 0, 16, 0, 2, 0}
+	sf, err := parseOpusHeader(header)
+	if err != nil || sf != (snapcast.SampleFormat{Rate: 48000, Bits: 16, Channels: 2}) {
+		t.Errorf("parsed %+v, %v", sf, err)
+	}
+	if _, err := parseOpusHeader(header[:8]); err == nil {
+		t.Error("expected an error for a short header")
+	}
+}
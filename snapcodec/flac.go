@@ -0,0 +1,73 @@
+package snapcodec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+)
+
+func init() {
+	Register(FLAC, newFLAC)
+}
+
+// flacDecoder decodes chunks of whole FLAC frames, the header is the fLaC marker and metadata blocks
+type flacDecoder struct {
+	format snapcast.SampleFormat
+}
+
+func newFLAC(header []byte) (Decoder, error) {
+	stream, err := flac.New(bytes.NewReader(header))
+	if err != nil {
+		return nil, err
+	}
+	return &flacDecoder{format: snapcast.SampleFormat{
+		Rate:     int(stream.Info.SampleRate),
+		Bits:     int(stream.Info.BitsPerSample),
+		Channels: int(stream.Info.NChannels),
+	}}, nil
+}
+
+func (d *flacDecoder) Format() snapcast.SampleFormat { return d.format }
+
+func (d *flacDecoder) Decode(chunk []byte) ([]byte, error) {
+	var (
+		r    = bytes.NewReader(chunk)
+		size = d.format.SampleSize()
+		out  []byte
+	)
+	for r.Len() > 0 {
+		f, err := frame.New(r)
+		if err != nil {
+			return nil, fmt.Errorf("snapcodec: flac frame header: %w", err)
+		}
+		// Frames may leave these to STREAMINFO
+		if f.BitsPerSample == 0 {
+			f.BitsPerSample = uint8(d.format.Bits)
+		}
+		if f.SampleRate == 0 {
+			f.SampleRate = uint32(d.format.Rate)
+		}
+		if err := f.Parse(); err != nil {
+			return nil, fmt.Errorf("snapcodec: flac frame: %w", err)
+		}
+		if len(f.Subframes) != d.format.Channels {
+			return nil, fmt.Errorf("snapcodec: flac frame has %d channels, stream has %d", len(f.Subframes), d.format.Channels)
+		}
+
+		var (
+			n   = int(f.BlockSize)
+			off = len(out)
+		)
+		out = append(out, make([]byte, n*d.format.FrameSize())...)
+		for i := 0; i < n; i++ {
+			for _, sub := range f.Subframes {
+				putSample(out[off:], sub.Samples[i], size)
+				off += size
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+//go:build opus && cgo
+
+package snapcodec
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+func init() {
+	Register(Opus, newOpus)
+}
+
+// opusDecoder wraps libopus, every chunk is one opus packet
+type opusDecoder struct {
+	format snapcast.SampleFormat
+	dec    *C.OpusDecoder
+	pcm    []int16
+	// Longest packet opus allows is 120ms
+	maxSamples int
+}
+
+func newOpus(header []byte) (Decoder, error) {
+	format, err := parseOpusHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if format.Bits != 16 {
+		return nil, fmt.Errorf("opus decodes to 16 bit, header says %d", format.Bits)
+	}
+
+	var code C.int
+	dec := C.opus_decoder_create(C.opus_int32(format.Rate), C.int(format.Channels), &code)
+	if code != C.OPUS_OK {
+		return nil, opusError(code)
+	}
+
+	var maxSamples = format.Rate * 120 / 1000
+	return &opusDecoder{
+		format:     format,
+		dec:        dec,
+		pcm:        make([]int16, maxSamples*format.Channels),
+		maxSamples: maxSamples,
+	}, nil
+}
+
+func (d *opusDecoder) Format() snapcast.SampleFormat { return d.format }
+
+func (d *opusDecoder) Decode(chunk []byte) ([]byte, error) {
+	if d.dec == nil {
+		return nil, fmt.Errorf("snapcodec: opus decoder is closed")
+	}
+	if len(chunk) == 0 {
+		return nil, nil
+	}
+
+	n := C.opus_decode(
+		d.dec,
+		(*C.uchar)(unsafe.Pointer(&chunk[0])),
+		C.opus_int32(len(chunk)),
+		(*C.opus_int16)(unsafe.Pointer(&d.pcm[0])),
+		C.int(d.maxSamples),
+		0,
+	)
+	if n < 0 {
+		return nil, fmt.Errorf("snapcodec: opus: %w", opusError(n))
+	}
+
+	var (
+		samples = d.pcm[:int(n)*d.format.Channels]
+		out     = make([]byte, 2*len(samples))
+	)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(s))
+	}
+	return out, nil
+}
+
+func (d *opusDecoder) Close() error {
+	if d.dec != nil {
+		C.opus_decoder_destroy(d.dec)
+		d.dec = nil
+	}
+	return nil
+}
+
+type opusError C.int
+
+func (e opusError) Error() string {
+	return C.GoString(C.opus_strerror(C.int(e)))
+}
@@ -0,0 +1,23 @@
+package snapcodec
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// opusMarker starts the pseudo header snapserver sends for opus, "OPUS" as a little endian uint32
+const opusMarker = 0x4F505553
+
+// parseOpusHeader reads the marker, then rate uint32, bits uint16 and channels uint16
+func parseOpusHeader(header []byte) (snapcast.SampleFormat, error) {
+	if len(header) < 12 || binary.LittleEndian.Uint32(header) != opusMarker {
+		return snapcast.SampleFormat{}, errors.New("not a snapserver opus header")
+	}
+	return snapcast.SampleFormat{
+		Rate:     int(binary.LittleEndian.Uint32(header[4:])),
+		Bits:     int(binary.LittleEndian.Uint16(header[8:])),
+		Channels: int(binary.LittleEndian.Uint16(header[10:])),
+	}, nil
+}
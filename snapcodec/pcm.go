@@ -0,0 +1,34 @@
+package snapcodec
+
+import (
+	"fmt"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+)
+
+func init() {
+	Register(PCM, newPCM)
+}
+
+// pcm chunks already are what we want, the header is a RIFF header with the format
+type pcm struct {
+	format snapcast.SampleFormat
+}
+
+func newPCM(header []byte) (Decoder, error) {
+	format, err := snapproto.ParsePCMHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	return &pcm{format: format}, nil
+}
+
+func (d *pcm) Format() snapcast.SampleFormat { return d.format }
+
+func (d *pcm) Decode(chunk []byte) ([]byte, error) {
+	if len(chunk)%d.format.FrameSize() != 0 {
+		return nil, fmt.Errorf("snapcodec: pcm chunk of %d bytes isn't whole %s frames", len(chunk), d.format)
+	}
+	return chunk, nil
+}
@@ -0,0 +1,136 @@
+// Package snapmetrics exports the state of a snapserver as Prometheus metrics
+package snapmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "snapcast"
+
+var (
+	upDesc = prometheus.NewDesc(namespace+"_up",
+		"Whether the state was synced from the server.", nil, nil)
+
+	clientConnectedDesc = prometheus.NewDesc(namespace+"_client_connected",
+		"Whether the client is connected.", []string{"id", "name", "group"}, nil)
+	clientVolumeDesc = prometheus.NewDesc(namespace+"_client_volume_percent",
+		"Volume of the client.", []string{"id", "name"}, nil)
+	clientMutedDesc = prometheus.NewDesc(namespace+"_client_muted",
+		"Whether the client is muted.", []string{"id", "name"}, nil)
+	clientLatencyDesc = prometheus.NewDesc(namespace+"_client_latency_ms",
+		"Configured latency of the client.", []string{"id", "name"}, nil)
+	clientLastSeenDesc = prometheus.NewDesc(namespace+"_client_last_seen_seconds",
+		"Unix time the server last heard from the client.", []string{"id", "name"}, nil)
+
+	groupMutedDesc = prometheus.NewDesc(namespace+"_group_muted",
+		"Whether the group is muted.", []string{"id", "name", "stream"}, nil)
+	groupClientsDesc = prometheus.NewDesc(namespace+"_group_clients",
+		"Number of clients in the group.", []string{"id", "name"}, nil)
+
+	streamPlayingDesc = prometheus.NewDesc(namespace+"_stream_playing",
+		"Whether the stream is playing.", []string{"id", "status"}, nil)
+)
+
+// Collector reports the clients, groups and streams of a snapstate.State, how many notifications
+// arrived and how long requests took. The zero value isn't usable, use NewCollector
+type Collector struct {
+	state         *snapstate.State
+	notifications *prometheus.CounterVec
+	requests      *prometheus.HistogramVec
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notifications_total",
+			Help:      "Notifications received by method.",
+		}, []string{"method"}),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time from sending a JSON-RPC request to its response, by method and outcome.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method", "outcome"}),
+	}
+}
+
+// Watch reports state and counts the notifications client receives.
+// Call it before registering the collector, the state doesn't need to be running yet
+func (c *Collector) Watch(client *snapclient.Client, state *snapstate.State) *snapclient.Subscription {
+	c.state = state
+	return client.OnNotification(func(_ context.Context, msg *snapcast.Notification) {
+		c.notifications.WithLabelValues(string(*msg.Method)).Inc()
+	})
+}
+
+// ObserveRequest records a request, pass it as snapclient.Options.OnResponse.
+// The outcome is ok, error for an error response or failed if no response arrived
+func (c *Collector) ObserveRequest(method snapcast.RequestMethod, d time.Duration, res *snapcast.Response, err error) {
+	var outcome = "ok"
+	switch {
+	case err != nil:
+		outcome = "failed"
+	case res.Error != nil:
+		outcome = "error"
+	}
+	c.requests.WithLabelValues(string(method), outcome).Observe(d.Seconds())
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		upDesc,
+		clientConnectedDesc, clientVolumeDesc, clientMutedDesc, clientLatencyDesc, clientLastSeenDesc,
+		groupMutedDesc, groupClientsDesc,
+		streamPlayingDesc,
+	} {
+		ch <- d
+	}
+	c.notifications.Describe(ch)
+	c.requests.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.notifications.Collect(ch)
+	c.requests.Collect(ch)
+
+	if c.state == nil || !c.state.Synced() {
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+
+	var server = c.state.Server()
+	for _, g := range server.Groups {
+		ch <- prometheus.MustNewConstMetric(groupMutedDesc, prometheus.GaugeValue, boolValue(g.Muted), g.ID, g.Name, g.StreamID)
+		ch <- prometheus.MustNewConstMetric(groupClientsDesc, prometheus.GaugeValue, float64(len(g.Clients)), g.ID, g.Name)
+
+		for _, cl := range g.Clients {
+			var name = cl.Name()
+			ch <- prometheus.MustNewConstMetric(clientConnectedDesc, prometheus.GaugeValue, boolValue(cl.Connected), cl.ID, name, g.ID)
+			ch <- prometheus.MustNewConstMetric(clientVolumeDesc, prometheus.GaugeValue, float64(cl.Config.Volume.Percent), cl.ID, name)
+			ch <- prometheus.MustNewConstMetric(clientMutedDesc, prometheus.GaugeValue, boolValue(cl.Config.Volume.Muted), cl.ID, name)
+			ch <- prometheus.MustNewConstMetric(clientLatencyDesc, prometheus.GaugeValue, float64(cl.Config.Latency), cl.ID, name)
+			ch <- prometheus.MustNewConstMetric(clientLastSeenDesc, prometheus.GaugeValue,
+				float64(cl.LastSeen.Sec)+float64(cl.LastSeen.USec)/1e6, cl.ID, name)
+		}
+	}
+
+	for _, st := range server.Streams {
+		ch <- prometheus.MustNewConstMetric(streamPlayingDesc, prometheus.GaugeValue,
+			boolValue(st.Status.IsPlaying()), st.ID, string(st.Status))
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
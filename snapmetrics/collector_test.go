@@ -0,0 +1,167 @@
+package snapmetrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		collector = NewCollector()
+		client    = snapclient.New(&snapclient.Options{Host: srv.Host(), OnResponse: collector.ObserveRequest})
+		state     = snapstate.New(client)
+	)
+	defer client.Close()
+	collector.Watch(client, state)
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP snapcast_up Whether the state was synced from the server.
+# TYPE snapcast_up gauge
+snapcast_up 0
+`), "snapcast_up"); err != nil {
+		t.Error(err)
+	}
+
+	go state.Run(ctx)
+	for !state.Synced() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("never synced")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// A change from another control client arrives as a notification
+	var other = snapclient.New(&snapclient.Options{Host: srv.Host()})
+	defer other.Close()
+	if _, err := other.ClientSetVolume(ctx, "b8:27:eb:5f:1e:77", snapcast.Volume{Percent: 15, Muted: true}); err != nil {
+		t.Fatal(err)
+	}
+	for testutil.ToFloat64(collector.notifications.WithLabelValues(string(snapcast.MethodClientOnVolumeChanged))) != 1 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("notification not counted")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	client.ClientSetName(ctx, "missing", "x")
+
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP snapcast_client_volume_percent Volume of the client.
+# TYPE snapcast_client_volume_percent gauge
+snapcast_client_volume_percent{id="00:21:6a:7d:74:fc",name="Living Room"} 74
+snapcast_client_volume_percent{id="b8:27:eb:5f:1e:77",name="Kitchen"} 15
+snapcast_client_volume_percent{id="dc:a6:32:0b:44:12",name="bedroom"} 30
+# HELP snapcast_client_muted Whether the client is muted.
+# TYPE snapcast_client_muted gauge
+snapcast_client_muted{id="00:21:6a:7d:74:fc",name="Living Room"} 0
+snapcast_client_muted{id="b8:27:eb:5f:1e:77",name="Kitchen"} 1
+snapcast_client_muted{id="dc:a6:32:0b:44:12",name="bedroom"} 1
+# HELP snapcast_client_connected Whether the client is connected.
+# TYPE snapcast_client_connected gauge
+snapcast_client_connected{group="4dcc4e3b-c699-a04b-7f0c-8260d23c43e1",id="00:21:6a:7d:74:fc",name="Living Room"} 1
+snapcast_client_connected{group="4dcc4e3b-c699-a04b-7f0c-8260d23c43e1",id="b8:27:eb:5f:1e:77",name="Kitchen"} 1
+snapcast_client_connected{group="c4b3a1f2-5e6d-4c7b-8a9f-0e1d2c3b4a59",id="dc:a6:32:0b:44:12",name="bedroom"} 0
+# HELP snapcast_client_latency_ms Configured latency of the client.
+# TYPE snapcast_client_latency_ms gauge
+snapcast_client_latency_ms{id="00:21:6a:7d:74:fc",name="Living Room"} 0
+snapcast_client_latency_ms{id="b8:27:eb:5f:1e:77",name="Kitchen"} 20
+snapcast_client_latency_ms{id="dc:a6:32:0b:44:12",name="bedroom"} 0
+# HELP snapcast_group_muted Whether the group is muted.
+# TYPE snapcast_group_muted gauge
+snapcast_group_muted{id="4dcc4e3b-c699-a04b-7f0c-8260d23c43e1",name="",stream="default"} 0
+snapcast_group_muted{id="c4b3a1f2-5e6d-4c7b-8a9f-0e1d2c3b4a59",name="Bedroom",stream="default"} 0
+# HELP snapcast_stream_playing Whether the stream is playing.
+# TYPE snapcast_stream_playing gauge
+snapcast_stream_playing{id="default",status="idle"} 0
+# HELP snapcast_up Whether the state was synced from the server.
+# TYPE snapcast_up gauge
+snapcast_up 1
+`), "snapcast_up", "snapcast_client_volume_percent", "snapcast_client_muted", "snapcast_client_connected",
+		"snapcast_client_latency_ms", "snapcast_group_muted", "snapcast_stream_playing"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(collector, "snapcast_request_duration_seconds"); n != 2 {
+		t.Errorf("%d request series, want Server.GetStatus ok and Client.SetName error", n)
+	}
+	problems, err := testutil.CollectAndLint(collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		// Named after the ms snapserver configures latency in
+		if p.Metric == "snapcast_client_latency_ms" {
+			continue
+		}
+		t.Errorf("lint %s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestCollectorDown(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		collector = NewCollector()
+		client    = snapclient.New(&snapclient.Options{
+			Host:      srv.Host(),
+			Reconnect: &snapclient.ReconnectPolicy{InitialDelay: 50 * time.Millisecond},
+		})
+		state = snapstate.New(client)
+	)
+	defer client.Close()
+	collector.Watch(client, state)
+
+	var waitUp = func(up int) {
+		t.Helper()
+		var want = fmt.Sprintf(`
+# HELP snapcast_up Whether the state was synced from the server.
+# TYPE snapcast_up gauge
+snapcast_up %d
+`, up)
+		for {
+			var changed = state.Changed()
+			err := testutil.CollectAndCompare(collector, strings.NewReader(want), "snapcast_up")
+			if err == nil {
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var runErr = make(chan error, 1)
+	go func() { runErr <- state.Run(ctx) }()
+	waitUp(1)
+
+	// Changes are missed until the reconnect resyncs
+	srv.CloseConnections()
+	waitUp(0)
+	waitUp(1)
+
+	cancel()
+	<-runErr
+	waitUp(0)
+}
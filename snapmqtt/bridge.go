@@ -0,0 +1,382 @@
+// Package snapmqtt bridges snapserver and MQTT. The state of every client, group and stream is
+// published as retained JSON, commands are taken from set topics and Home Assistant discovery
+// configs are published so the entities show up on their own.
+//
+// Topics, under Options.Prefix:
+//
+//	status                          online or offline
+//	client/<id>                     client state
+//	client/<id>/volume/set          0 to 100
+//	client/<id>/mute/set            ON or OFF
+//	group/<id>                      group state
+//	group/<id>/mute/set             ON or OFF
+//	group/<id>/stream/set           stream id
+//	stream/<id>                     stream state
+//	stream/<id>/state               playing, paused or idle
+//	stream/<id>/title               also artist, album, duration and position, plain values of the stream state
+//	stream/<id>/control/set         play, pause, next, ... or {"command": "seek", "params": {"offset": 10}}
+//
+// Streams are discovered as media players of the bkbilly/mqtt_media_player custom integration.
+package snapmqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+)
+
+const (
+	DefaultPrefix          = "snapcast"
+	DefaultDiscoveryPrefix = "homeassistant"
+
+	Online  = "online"
+	Offline = "offline"
+)
+
+type Options struct {
+	// Topic prefix, defaults to DefaultPrefix
+	Prefix string
+	// Home Assistant discovery prefix, defaults to DefaultDiscoveryPrefix
+	DiscoveryPrefix  string
+	DisableDiscovery bool
+	// Called with errors of commands and publishes, which don't stop the bridge
+	OnError func(err error)
+}
+
+type Bridge struct {
+	opts   Options
+	client *snapclient.Client
+	state  *snapstate.State
+	broker Broker
+
+	mu sync.Mutex
+	// Retained payloads published so far, to only publish changes and clear what's gone
+	published map[string][]byte
+
+	statusMu sync.Mutex
+	// Online while Run runs
+	status string
+}
+
+func New(client *snapclient.Client, broker Broker, opts *Options) *Bridge {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Prefix == "" {
+		o.Prefix = DefaultPrefix
+	}
+	if o.DiscoveryPrefix == "" {
+		o.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if o.OnError == nil {
+		o.OnError = func(error) {}
+	}
+	return &Bridge{
+		opts:      o,
+		client:    client,
+		state:     snapstate.New(client),
+		broker:    broker,
+		published: make(map[string][]byte),
+		status:    Offline,
+	}
+}
+
+// StatusTopic is where the bridge publishes online and offline, also use it as the will
+func (b *Bridge) StatusTopic() string {
+	return b.opts.Prefix + "/status"
+}
+
+// PublishStatus publishes the status again, call it when the broker connection is back
+// as the will may have set it to offline
+func (b *Bridge) PublishStatus() {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	b.publish(b.StatusTopic(), []byte(b.status))
+}
+
+func (b *Bridge) setStatus(status string) {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	b.status = status
+	b.publish(b.StatusTopic(), []byte(status))
+}
+
+// command is a message on a set topic
+type command struct {
+	topic   string
+	payload []byte
+}
+
+// Run keeps the topics in sync with the server and runs commands until ctx is done or the
+// connection to snapserver ends. The status topic is set to offline when it returns
+func (b *Bridge) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var commands = make(chan command, 16)
+	if err := b.broker.Subscribe(b.opts.Prefix+"/+/+/+/set", func(topic string, payload []byte) {
+		select {
+		case commands <- command{topic, payload}:
+		case <-ctx.Done():
+		}
+	}); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case cmd := <-commands:
+				if err := b.handle(ctx, cmd.topic, cmd.payload); err != nil {
+					b.opts.OnError(fmt.Errorf("%s: %w", cmd.topic, err))
+				}
+			}
+		}
+	}()
+
+	var runErr = make(chan error, 1)
+	go func() { runErr <- b.state.Run(ctx) }()
+
+	b.setStatus(Online)
+	defer b.setStatus(Offline)
+
+	for {
+		var changed = b.state.Changed()
+		if b.state.Synced() {
+			b.sync(b.state.Server())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-runErr:
+			return err
+		case <-changed:
+		}
+	}
+}
+
+func (b *Bridge) publish(topic string, payload []byte) {
+	if err := b.broker.Publish(topic, payload, true); err != nil {
+		b.opts.OnError(fmt.Errorf("publish %s: %w", topic, err))
+	}
+}
+
+// sync publishes every retained topic that changed and clears the ones of removed entities
+func (b *Bridge) sync(server snapcast.Server) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var topics = b.stateTopics(&server)
+	if !b.opts.DisableDiscovery {
+		for topic, payload := range b.discoveryTopics(&server) {
+			topics[topic] = payload
+		}
+	}
+	for topic, payload := range topics {
+		if old, ok := b.published[topic]; ok && string(old) == string(payload) {
+			continue
+		}
+		b.publish(topic, payload)
+		b.published[topic] = payload
+	}
+	for topic := range b.published {
+		if _, ok := topics[topic]; !ok {
+			b.publish(topic, nil)
+			delete(b.published, topic)
+		}
+	}
+}
+
+type ClientState struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	Volume    int    `json:"volume"`
+	Muted     bool   `json:"muted"`
+	Latency   int    `json:"latency"`
+	Group     string `json:"group"`
+	Stream    string `json:"stream"`
+}
+
+type GroupState struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Muted   bool     `json:"muted"`
+	Stream  string   `json:"stream"`
+	Clients []string `json:"clients"`
+}
+
+type StreamState struct {
+	ID       string  `json:"id"`
+	Status   string  `json:"status"`
+	Playback string  `json:"playback,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Artist   string  `json:"artist,omitempty"`
+	Album    string  `json:"album,omitempty"`
+	ArtURL   string  `json:"art_url,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Position float64 `json:"position,omitempty"`
+}
+
+func (b *Bridge) topic(kind, id string, parts ...string) string {
+	return strings.Join(append([]string{b.opts.Prefix, kind, id}, parts...), "/")
+}
+
+func (b *Bridge) stateTopics(server *snapcast.Server) map[string][]byte {
+	var topics = make(map[string][]byte)
+	for _, g := range server.Groups {
+		var gs = GroupState{ID: g.ID, Name: g.Name, Muted: g.Muted, Stream: g.StreamID, Clients: []string{}}
+		for _, c := range g.Clients {
+			gs.Clients = append(gs.Clients, c.ID)
+			b.put(topics, b.topic("client", c.ID), ClientState{
+				ID:        c.ID,
+				Name:      c.Name(),
+				Connected: c.Connected,
+				Volume:    c.Config.Volume.Percent,
+				Muted:     c.Config.Volume.Muted,
+				Latency:   c.Config.Latency,
+				Group:     g.ID,
+				Stream:    g.StreamID,
+			})
+		}
+		b.put(topics, b.topic("group", g.ID), gs)
+	}
+
+	for _, st := range server.Streams {
+		var ss = StreamState{ID: st.ID, Status: string(st.Status)}
+		if p := st.Properties; p != nil {
+			ss.Playback = string(p.PlaybackStatus)
+			ss.Position = p.Position
+			if md := p.Metadata; md != nil {
+				ss.Title = md.Title
+				ss.Artist = strings.Join(md.Artist, ", ")
+				ss.Album = md.Album
+				ss.ArtURL = md.ArtURL
+				ss.Duration = md.Duration
+			}
+		}
+		b.put(topics, b.topic("stream", st.ID), ss)
+
+		// For the media player
+		var playerState = "idle"
+		if ss.Playback == string(snapcast.PlaybackPlaying) || ss.Playback == string(snapcast.PlaybackPaused) {
+			playerState = ss.Playback
+		}
+		for what, value := range map[string]string{
+			"state":    playerState,
+			"title":    ss.Title,
+			"artist":   ss.Artist,
+			"album":    ss.Album,
+			"duration": strconv.FormatFloat(ss.Duration, 'f', -1, 64),
+			"position": strconv.FormatFloat(ss.Position, 'f', -1, 64),
+		} {
+			topics[b.topic("stream", st.ID, what)] = []byte(value)
+		}
+	}
+	return topics
+}
+
+// put the JSON of v as the payload of topic. If it doesn't marshal the error goes to OnError and
+// the topic keeps its last payload. Call with mu held
+func (b *Bridge) put(topics map[string][]byte, topic string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		b.opts.OnError(fmt.Errorf("%s: %w", topic, err))
+		if old, ok := b.published[topic]; ok {
+			topics[topic] = old
+		}
+		return
+	}
+	topics[topic] = payload
+}
+
+// handle runs the command of a set topic
+func (b *Bridge) handle(ctx context.Context, topic string, payload []byte) error {
+	var parts = strings.Split(strings.TrimPrefix(topic, b.opts.Prefix+"/"), "/")
+	if len(parts) != 4 {
+		return fmt.Errorf("unknown topic")
+	}
+	var (
+		kind, id, what = parts[0], parts[1], parts[2]
+		value          = strings.TrimSpace(string(payload))
+	)
+
+	switch kind + "/" + what {
+	case "client/volume":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > 100 {
+			return fmt.Errorf("volume %q is not between 0 and 100", value)
+		}
+		c, ok := b.state.Client(id)
+		if !ok {
+			return fmt.Errorf("unknown client %q", id)
+		}
+		var volume = c.Config.Volume
+		volume.Percent = int(math.Round(v))
+		_, err = b.client.ClientSetVolume(ctx, id, volume)
+		return err
+
+	case "client/mute":
+		muted, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		c, ok := b.state.Client(id)
+		if !ok {
+			return fmt.Errorf("unknown client %q", id)
+		}
+		var volume = c.Config.Volume
+		volume.Muted = muted
+		_, err = b.client.ClientSetVolume(ctx, id, volume)
+		return err
+
+	case "group/mute":
+		muted, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		_, err = b.client.GroupSetMute(ctx, id, muted)
+		return err
+
+	case "group/stream":
+		_, err := b.client.GroupSetStream(ctx, id, value)
+		return err
+
+	case "stream/control":
+		var cmd struct {
+			Command snapcast.StreamCommand `json:"command"`
+			Params  interface{}            `json:"params,omitempty"`
+		}
+		if strings.HasPrefix(value, "{") {
+			if err := json.Unmarshal(payload, &cmd); err != nil {
+				return err
+			}
+		} else {
+			cmd.Command = snapcast.StreamCommand(value)
+		}
+		_, err := b.client.StreamControl(ctx, id, cmd.Command, cmd.Params)
+		return err
+	}
+	return fmt.Errorf("unknown topic")
+}
+
+// parseSwitch takes the payloads of Home Assistant switches as well as true and false
+func parseSwitch(v string) (bool, error) {
+	switch strings.ToUpper(v) {
+	case "ON", "TRUE", "1":
+		return true, nil
+	case "OFF", "FALSE", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not ON or OFF", v)
+}
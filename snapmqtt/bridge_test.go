@@ -0,0 +1,154 @@
+package snapmqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+const (
+	livingRoom = "00:21:6a:7d:74:fc"
+	bedroom    = "dc:a6:32:0b:44:12"
+	firstGroup = "4dcc4e3b-c699-a04b-7f0c-8260d23c43e1"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"snapcast/+/+/+/set", "snapcast/client/00:21:6a:7d:74:fc/volume/set", true},
+		{"snapcast/+/+/+/set", "snapcast/client/00:21:6a:7d:74:fc", false},
+		{"snapcast/#", "snapcast", true},
+		{"snapcast/#", "snapcast/stream/default", true},
+		{"snapcast/+", "snapcast/stream/default", false},
+		{"homeassistant/+/+/config", "homeassistant/number/x/config", true},
+	} {
+		if got := Match(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v", tc.filter, tc.topic, got)
+		}
+	}
+}
+
+func TestBridge(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		broker = NewMemoryBroker()
+		client = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		errs   = make(chan error, 16)
+		bridge = New(client, broker, &Options{OnError: func(err error) { errs <- err }})
+		done   = make(chan error, 1)
+	)
+	defer client.Close()
+	runCtx, stop := context.WithCancel(ctx)
+	go func() { done <- bridge.Run(runCtx) }()
+
+	var waitFor = func(what string, ok func() bool) {
+		t.Helper()
+		for !ok() {
+			select {
+			case err := <-errs:
+				t.Fatalf("%s: %v", what, err)
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %s", what)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	var clientState = func(id string) (s ClientState) {
+		json.Unmarshal(broker.Retained("snapcast/client/"+id), &s)
+		return s
+	}
+
+	waitFor("state", func() bool { return broker.Retained("snapcast/client/"+livingRoom) != nil })
+	if s := clientState(livingRoom); s.Name != "Living Room" || s.Volume != 74 || !s.Connected || s.Group != firstGroup || s.Stream != "default" {
+		t.Errorf("client state %+v", s)
+	}
+	var stream StreamState
+	json.Unmarshal(broker.Retained("snapcast/stream/default"), &stream)
+	if stream.Title != "Test Track" || stream.Artist != "Test Artist" || stream.Playback != "stopped" {
+		t.Errorf("stream state %+v", stream)
+	}
+	if string(broker.Retained(bridge.StatusTopic())) != Online {
+		t.Error("status isn't online")
+	}
+	// The will of a dropped broker connection
+	broker.Publish(bridge.StatusTopic(), []byte(Offline), true)
+	bridge.PublishStatus()
+	if string(broker.Retained(bridge.StatusTopic())) != Online {
+		t.Error("status isn't online again after reconnecting to the broker")
+	}
+
+	// Two entities per client, one per group and stream
+	if topics := broker.Topics("homeassistant/#"); len(topics) != 3*2+2+1 {
+		t.Errorf("discovery topics %v", topics)
+	}
+	var number map[string]interface{}
+	json.Unmarshal(broker.Retained("homeassistant/number/snapcast_client_00_21_6a_7d_74_fc_volume/config"), &number)
+	if number["command_topic"] != "snapcast/client/"+livingRoom+"/volume/set" || number["state_topic"] != "snapcast/client/"+livingRoom {
+		t.Errorf("number config %v", number)
+	}
+	var player map[string]interface{}
+	json.Unmarshal(broker.Retained("homeassistant/media_player/snapcast_stream_default/config"), &player)
+	if player["state_title_topic"] != "snapcast/stream/default/title" {
+		t.Errorf("media player config %v", player)
+	}
+	if title, state := broker.Retained("snapcast/stream/default/title"), broker.Retained("snapcast/stream/default/state"); string(title) != "Test Track" || string(state) != "idle" {
+		t.Errorf("media player title %q, state %q", title, state)
+	}
+
+	// Commands, HA numbers send floats
+	broker.Publish("snapcast/client/"+livingRoom+"/volume/set", []byte("33.0"), false)
+	waitFor("volume", func() bool { return clientState(livingRoom).Volume == 33 })
+	broker.Publish("snapcast/client/"+livingRoom+"/mute/set", []byte("ON"), false)
+	waitFor("mute", func() bool { s := clientState(livingRoom); return s.Muted && s.Volume == 33 })
+
+	broker.Publish("snapcast/group/"+firstGroup+"/mute/set", []byte("ON"), false)
+	waitFor("group mute", func() bool { return srv.State().Groups[0].Muted })
+
+	broker.Publish("snapcast/stream/default/control/set", []byte("play"), false)
+	waitFor("play", func() bool {
+		json.Unmarshal(broker.Retained("snapcast/stream/default"), &stream)
+		return stream.Playback == string(snapcast.PlaybackPlaying)
+	})
+	broker.Publish("snapcast/stream/default/control/set", []byte(`{"command": "setPosition", "params": {"position": 42}}`), false)
+	waitFor("position", func() bool { return srv.State().Streams[0].Properties.Position == 42 })
+
+	broker.Publish("snapcast/client/"+livingRoom+"/volume/set", []byte("loud"), false)
+	select {
+	case err := <-errs:
+		t.Log(err)
+	case <-ctx.Done():
+		t.Fatal("bad volume not reported")
+	}
+
+	// Deleted clients are cleared from the broker
+	var other = snapclient.New(&snapclient.Options{Host: srv.Host()})
+	defer other.Close()
+	if _, err := other.ServerDeleteClient(ctx, bedroom); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("delete", func() bool {
+		return broker.Retained("snapcast/client/"+bedroom) == nil &&
+			broker.Retained("homeassistant/switch/snapcast_client_dc_a6_32_0b_44_12_mute/config") == nil
+	})
+
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+	if bridge.PublishStatus(); string(broker.Retained(bridge.StatusTopic())) != Offline {
+		t.Error("status isn't offline")
+	}
+}
@@ -0,0 +1,109 @@
+package snapmqtt
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Broker is the part of an MQTT client the bridge needs. cmd/snapcast-mqtt adapts paho,
+// MemoryBroker runs in-process for tests
+type Broker interface {
+	Publish(topic string, payload []byte, retained bool) error
+	// Subscribe calls handler for every message on topics matching filter, which may contain + and # wildcards
+	Subscribe(filter string, handler func(topic string, payload []byte)) error
+}
+
+// Match reports whether topic matches an MQTT topic filter
+func Match(filter, topic string) bool {
+	var f, t = strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// MemoryBroker is an in-process Broker that keeps retained messages like a real broker:
+// an empty retained payload clears the topic, subscribers get the matching retained messages first
+type MemoryBroker struct {
+	mu       sync.Mutex
+	retained map[string][]byte
+	subs     []memorySub
+}
+
+type memorySub struct {
+	filter  string
+	handler func(topic string, payload []byte)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{retained: make(map[string][]byte)}
+}
+
+func (b *MemoryBroker) Publish(topic string, payload []byte, retained bool) error {
+	payload = append([]byte(nil), payload...)
+
+	b.mu.Lock()
+	if retained {
+		if len(payload) == 0 {
+			delete(b.retained, topic)
+		} else {
+			b.retained[topic] = payload
+		}
+	}
+	var handlers []func(string, []byte)
+	for _, s := range b.subs {
+		if Match(s.filter, topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, h := range handlers {
+		h(topic, payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	b.mu.Lock()
+	b.subs = append(b.subs, memorySub{filter, handler})
+	var retained = make(map[string][]byte)
+	for topic, payload := range b.retained {
+		if Match(filter, topic) {
+			retained[topic] = payload
+		}
+	}
+	b.mu.Unlock()
+
+	for topic, payload := range retained {
+		handler(topic, payload)
+	}
+	return nil
+}
+
+// Retained message of a topic, nil if there is none
+func (b *MemoryBroker) Retained(topic string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retained[topic]
+}
+
+// Topics with a retained message matching filter, sorted
+func (b *MemoryBroker) Topics(filter string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var topics []string
+	for topic := range b.retained {
+		if Match(filter, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,218 @@
+package snapmqtt
+
+import (
+	"strings"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// device groups entities in Home Assistant
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// entity has the keys every discovery config shares
+type entity struct {
+	Name              string  `json:"name"`
+	UniqueID          string  `json:"unique_id"`
+	AvailabilityTopic string  `json:"availability_topic"`
+	StateTopic        string  `json:"state_topic"`
+	CommandTopic      string  `json:"command_topic"`
+	Device            *device `json:"device"`
+}
+
+type numberConfig struct {
+	entity
+	ValueTemplate     string `json:"value_template"`
+	Min               int    `json:"min"`
+	Max               int    `json:"max"`
+	Step              int    `json:"step"`
+	UnitOfMeasurement string `json:"unit_of_measurement"`
+	Icon              string `json:"icon,omitempty"`
+}
+
+type switchConfig struct {
+	entity
+	ValueTemplate string `json:"value_template"`
+	PayloadOn     string `json:"payload_on"`
+	PayloadOff    string `json:"payload_off"`
+	Icon          string `json:"icon,omitempty"`
+}
+
+// mediaPlayerConfig is the discovery schema of the bkbilly/mqtt_media_player custom integration,
+// Home Assistant has no MQTT media player of its own. Every value is read from a topic of its own and
+// a command is offered when its topic is set
+type mediaPlayerConfig struct {
+	Name          string        `json:"name"`
+	UniqueID      string        `json:"unique_id"`
+	Availability  *availability `json:"availability"`
+	Device        *device       `json:"device"`
+	StateTopic    string        `json:"state_state_topic"`
+	TitleTopic    string        `json:"state_title_topic"`
+	ArtistTopic   string        `json:"state_artist_topic"`
+	AlbumTopic    string        `json:"state_album_topic"`
+	DurationTopic string        `json:"state_duration_topic"`
+	PositionTopic string        `json:"state_position_topic"`
+
+	PlayTopic        string `json:"command_play_topic,omitempty"`
+	PlayPayload      string `json:"command_play_payload,omitempty"`
+	PauseTopic       string `json:"command_pause_topic,omitempty"`
+	PausePayload     string `json:"command_pause_payload,omitempty"`
+	PlayPauseTopic   string `json:"command_playpause_topic,omitempty"`
+	PlayPausePayload string `json:"command_playpause_payload,omitempty"`
+	NextTopic        string `json:"command_next_topic,omitempty"`
+	NextPayload      string `json:"command_next_payload,omitempty"`
+	PreviousTopic    string `json:"command_previous_topic,omitempty"`
+	PreviousPayload  string `json:"command_previous_payload,omitempty"`
+}
+
+type availability struct {
+	Topic               string `json:"topic"`
+	PayloadAvailable    string `json:"payload_available"`
+	PayloadNotAvailable string `json:"payload_not_available"`
+}
+
+// objectID makes an id usable in discovery topics and unique ids, e.g. MACs
+func objectID(parts ...string) string {
+	var s = strings.ToLower(strings.Join(append([]string{"snapcast"}, parts...), "_"))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func (b *Bridge) discoveryTopic(component, objectID string) string {
+	return b.opts.DiscoveryPrefix + "/" + component + "/" + objectID + "/config"
+}
+
+// discoveryTopics has a volume number and a mute switch per client, a mute switch per group
+// and a media player per stream. Groups and streams belong to the snapserver device
+func (b *Bridge) discoveryTopics(server *snapcast.Server) map[string][]byte {
+	var (
+		topics       = make(map[string][]byte)
+		status       = b.StatusTopic()
+		serverDevice = &device{
+			Identifiers:  []string{objectID("server")},
+			Name:         "Snapserver",
+			Manufacturer: "Snapcast",
+			Model:        server.Snapserver.Name,
+			SWVersion:    server.Snapserver.Version,
+		}
+	)
+
+	for _, g := range server.Groups {
+		var (
+			groupTopic = b.topic("group", g.ID)
+			id         = objectID("group", g.ID, "mute")
+		)
+		b.put(topics, b.discoveryTopic("switch", id), switchConfig{
+			entity: entity{
+				Name:              g.DisplayName() + " mute",
+				UniqueID:          id,
+				AvailabilityTopic: status,
+				StateTopic:        groupTopic,
+				CommandTopic:      groupTopic + "/mute/set",
+				Device:            serverDevice,
+			},
+			ValueTemplate: "{{ 'ON' if value_json.muted else 'OFF' }}",
+			PayloadOn:     "ON",
+			PayloadOff:    "OFF",
+			Icon:          "mdi:volume-off",
+		})
+
+		for _, c := range g.Clients {
+			var (
+				clientTopic = b.topic("client", c.ID)
+				dev         = &device{
+					Identifiers:  []string{objectID("client", c.ID)},
+					Name:         c.Name(),
+					Manufacturer: "Snapcast",
+					Model:        c.Snapclient.Name,
+					SWVersion:    c.Snapclient.Version,
+				}
+			)
+			id = objectID("client", c.ID, "volume")
+			b.put(topics, b.discoveryTopic("number", id), numberConfig{
+				entity: entity{
+					Name:              "Volume",
+					UniqueID:          id,
+					AvailabilityTopic: status,
+					StateTopic:        clientTopic,
+					CommandTopic:      clientTopic + "/volume/set",
+					Device:            dev,
+				},
+				ValueTemplate:     "{{ value_json.volume }}",
+				Min:               0,
+				Max:               100,
+				Step:              1,
+				UnitOfMeasurement: "%",
+				Icon:              "mdi:volume-high",
+			})
+			id = objectID("client", c.ID, "mute")
+			b.put(topics, b.discoveryTopic("switch", id), switchConfig{
+				entity: entity{
+					Name:              "Mute",
+					UniqueID:          id,
+					AvailabilityTopic: status,
+					StateTopic:        clientTopic,
+					CommandTopic:      clientTopic + "/mute/set",
+					Device:            dev,
+				},
+				ValueTemplate: "{{ 'ON' if value_json.muted else 'OFF' }}",
+				PayloadOn:     "ON",
+				PayloadOff:    "OFF",
+				Icon:          "mdi:volume-off",
+			})
+		}
+	}
+
+	for _, st := range server.Streams {
+		var (
+			streamTopic = b.topic("stream", st.ID)
+			id          = objectID("stream", st.ID)
+			config      = mediaPlayerConfig{
+				Name:          st.ID,
+				UniqueID:      id,
+				Availability:  &availability{Topic: status, PayloadAvailable: Online, PayloadNotAvailable: Offline},
+				Device:        serverDevice,
+				StateTopic:    streamTopic + "/state",
+				TitleTopic:    streamTopic + "/title",
+				ArtistTopic:   streamTopic + "/artist",
+				AlbumTopic:    streamTopic + "/album",
+				DurationTopic: streamTopic + "/duration",
+				PositionTopic: streamTopic + "/position",
+			}
+		)
+		config.setCommands(streamTopic+"/control/set", st.Properties)
+		b.put(topics, b.discoveryTopic("media_player", id), config)
+	}
+	return topics
+}
+
+// setCommands offers the commands a stream supports going by its can* properties, all on the control topic
+func (c *mediaPlayerConfig) setCommands(control string, p *snapcast.Properties) {
+	if p == nil || !p.CanControl {
+		return
+	}
+	if p.CanPlay {
+		c.PlayTopic, c.PlayPayload = control, string(snapcast.StreamCommandPlay)
+	}
+	if p.CanPause {
+		c.PauseTopic, c.PausePayload = control, string(snapcast.StreamCommandPause)
+	}
+	if p.CanPlay && p.CanPause {
+		c.PlayPauseTopic, c.PlayPausePayload = control, string(snapcast.StreamCommandPlayPause)
+	}
+	if p.CanGoNext {
+		c.NextTopic, c.NextPayload = control, string(snapcast.StreamCommandNext)
+	}
+	if p.CanGoPrevious {
+		c.PreviousTopic, c.PreviousPayload = control, string(snapcast.StreamCommandPrevious)
+	}
+}
@@ -0,0 +1,426 @@
+// Package snapplayer is a headless snapclient. It connects to the stream port of snapserver,
+// keeps its clock in sync with the server and hands time stamped PCM to an AudioSink
+package snapplayer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcodec"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+)
+
+const (
+	DefaultPort       = "1704"
+	DefaultClientName = "Snapcast-Go"
+	// ProtocolVersion of the stream protocol sent in Hello
+	ProtocolVersion = 2
+	clientVersion   = "0.1.0"
+)
+
+var (
+	// DefaultSyncInterval between Time requests once the clock is in sync
+	DefaultSyncInterval = time.Second
+	// syncBurst Time requests are sent right after connecting for a quick first estimate
+	syncBurst = 10
+)
+
+type Options struct {
+	// Host of snapserver, the port defaults to 1704
+	Host string
+	// ID of the client, defaults to MAC
+	ID string
+	// Instance to tell apart several players on one host, defaults to 1
+	Instance int
+	// Defaults to os.Hostname
+	HostName string
+	// Defaults to the MAC of the first network interface that has one
+	MAC        string
+	ClientName string
+	Sink       AudioSink
+	// Defaults to DefaultSyncInterval
+	SyncInterval time.Duration
+}
+
+type Player struct {
+	opts     Options
+	clock    *clockSync
+	mu       sync.Mutex
+	settings snapproto.ServerSettings
+	format   snapcast.SampleFormat
+	conn     *conn
+}
+
+// ServerError is sent by the server before it closes the connection
+type ServerError struct {
+	Code    uint32
+	Reason  string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("snapserver error %d %s: %s", e.Code, e.Reason, e.Message)
+}
+
+func New(opts *Options) *Player {
+	var o = *opts
+	if _, _, err := net.SplitHostPort(o.Host); err != nil {
+		o.Host = net.JoinHostPort(o.Host, DefaultPort)
+	}
+	if o.Instance == 0 {
+		o.Instance = 1
+	}
+	if o.HostName == "" {
+		o.HostName, _ = os.Hostname()
+	}
+	if o.MAC == "" {
+		o.MAC = hostMAC()
+	}
+	if o.ID == "" {
+		o.ID = o.MAC
+	}
+	if o.ClientName == "" {
+		o.ClientName = DefaultClientName
+	}
+	if o.Sink == nil {
+		o.Sink = &NullSink{}
+	}
+	if o.SyncInterval == 0 {
+		o.SyncInterval = DefaultSyncInterval
+	}
+	return &Player{opts: o, clock: newClockSync()}
+}
+
+// ID the player shows up with in Server.GetStatus
+func (p *Player) ID() string {
+	return p.opts.ID
+}
+
+// Settings last sent by the server
+func (p *Player) Settings() snapproto.ServerSettings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings
+}
+
+// Format of the current stream, zero until the codec header arrived
+func (p *Player) Format() snapcast.SampleFormat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.format
+}
+
+// Offset to add to local time to get server time
+func (p *Player) Offset() time.Duration {
+	p.mu.Lock()
+	var clock = p.clock
+	p.mu.Unlock()
+	return clock.Offset()
+}
+
+// ServerNow is the current time on the server's clock
+func (p *Player) ServerNow() time.Time {
+	return time.Now().Add(p.Offset())
+}
+
+// SetVolume reports a volume change made on this client to the server
+func (p *Player) SetVolume(percent int, muted bool) error {
+	p.mu.Lock()
+	var c = p.conn
+	p.settings.Volume = percent
+	p.settings.Muted = muted
+	p.mu.Unlock()
+
+	if c == nil {
+		return net.ErrClosed
+	}
+	return c.send(&snapproto.ClientInfo{Volume: percent, Muted: muted}, snapproto.Header{})
+}
+
+// conn is one connection to the server
+type conn struct {
+	nc     net.Conn
+	mu     sync.Mutex
+	nextID uint16
+}
+
+func (c *conn) send(msg snapproto.Message, h snapproto.Header) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	h.ID = c.nextID
+	h.Sent = snapproto.TimevalFromTime(time.Now())
+	return snapproto.WriteMessage(c.nc, h, msg)
+}
+
+// item is what the reader hands to the playback goroutine, a new format or a chunk
+type item struct {
+	format    *snapcast.SampleFormat
+	timestamp time.Time
+	data      []byte
+}
+
+// Run connects and plays until ctx is done or the connection fails. It can be called again to reconnect
+func (p *Player) Run(ctx context.Context) error {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", p.opts.Host)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	var parent = ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		nc.Close()
+	}()
+
+	var c = &conn{nc: nc}
+	p.mu.Lock()
+	p.conn = c
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.conn = nil
+		p.mu.Unlock()
+	}()
+
+	if err := c.send(&snapproto.Hello{
+		Arch:                      runtime.GOARCH,
+		ClientName:                p.opts.ClientName,
+		HostName:                  p.opts.HostName,
+		ID:                        p.opts.ID,
+		Instance:                  p.opts.Instance,
+		MAC:                       p.opts.MAC,
+		OS:                        runtime.GOOS,
+		SnapStreamProtocolVersion: ProtocolVersion,
+		Version:                   clientVersion,
+	}, snapproto.Header{}); err != nil {
+		return err
+	}
+
+	var (
+		clock    = newClockSync()
+		items    = make(chan item, 256)
+		playDone = make(chan error, 1)
+	)
+	p.mu.Lock()
+	p.clock = clock
+	p.mu.Unlock()
+
+	go func() {
+		playDone <- p.play(ctx, clock, items)
+		cancel()
+	}()
+	go p.syncTime(ctx, c)
+
+	var readErr = p.read(ctx, c, clock, items)
+	cancel()
+	if err := <-playDone; err != nil {
+		return err
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+func (p *Player) read(ctx context.Context, c *conn, clock *clockSync, items chan<- item) error {
+	var send = func(it item) error {
+		select {
+		case items <- it:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var dec snapcodec.Decoder
+	defer func() { closeDecoder(dec) }()
+
+	for {
+		h, msg, err := snapproto.ReadMessage(c.nc)
+		if err != nil {
+			return err
+		}
+		h.Received = snapproto.TimevalFromTime(time.Now())
+
+		switch msg := msg.(type) {
+		case *snapproto.ServerSettings:
+			p.mu.Lock()
+			p.settings = *msg
+			p.mu.Unlock()
+
+		case *snapproto.CodecHeader:
+			closeDecoder(dec)
+			if dec, err = snapcodec.NewDecoder(msg); err != nil {
+				return err
+			}
+			var format = dec.Format()
+			p.mu.Lock()
+			p.format = format
+			p.mu.Unlock()
+			if err := send(item{format: &format}); err != nil {
+				return err
+			}
+
+		case *snapproto.WireChunk:
+			if dec == nil {
+				continue
+			}
+			pcm, err := dec.Decode(msg.Payload)
+			if err != nil {
+				return err
+			}
+			if err := send(item{timestamp: msg.Timestamp.Time(), data: pcm}); err != nil {
+				return err
+			}
+
+		case *snapproto.Time:
+			var (
+				c2s = msg.Latency.Duration()
+				s2c = h.Received.Time().Sub(h.Sent.Time())
+			)
+			clock.add(c2s, s2c)
+
+		case *snapproto.Error:
+			return &ServerError{Code: msg.Code, Reason: msg.Error, Message: msg.Message}
+		}
+	}
+}
+
+// syncTime sends a burst of Time requests and then one every SyncInterval
+func (p *Player) syncTime(ctx context.Context, c *conn) {
+	for i := 0; i < syncBurst; i++ {
+		if c.send(&snapproto.Time{}, snapproto.Header{}) != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	var ticker = time.NewTicker(p.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.send(&snapproto.Time{}, snapproto.Header{}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// play waits for each chunk's turn and writes it to the sink.
+// A chunk is played at its timestamp plus the buffer, chunks more than their own length late are dropped
+func (p *Player) play(ctx context.Context, clock *clockSync, items <-chan item) error {
+	var (
+		sink   = p.opts.Sink
+		format snapcast.SampleFormat
+		open   bool
+		timer  = time.NewTimer(0)
+	)
+	<-timer.C
+	defer func() {
+		if open {
+			sink.Close()
+		}
+	}()
+
+	for {
+		var it item
+		select {
+		case <-ctx.Done():
+			return nil
+		case it = <-items:
+		}
+
+		if it.format != nil {
+			if open && *it.format == format {
+				continue
+			}
+			if open {
+				if err := sink.Close(); err != nil {
+					return err
+				}
+			}
+			format = *it.format
+			open = false
+			if err := sink.Open(format); err != nil {
+				return err
+			}
+			open = true
+			continue
+		}
+
+		// Nothing can be scheduled before the first clock estimate
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.ready:
+		}
+
+		var settings = p.Settings()
+		var (
+			buffer = time.Duration(settings.BufferMs-settings.Latency) * time.Millisecond
+			playAt = it.timestamp.Add(buffer).Add(-clock.Offset())
+			wait   = time.Until(playAt)
+		)
+		if -wait > format.Duration(len(it.data)) {
+			continue
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+
+		if err := sink.Write(&Frame{
+			PlayAt:    playAt,
+			Timestamp: it.timestamp,
+			Format:    format,
+			Data:      it.data,
+			Volume:    settings.Volume,
+			Muted:     settings.Muted,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func closeDecoder(dec snapcodec.Decoder) {
+	if c, ok := dec.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// hostMAC is the address of the first interface that is up and has one
+func hostMAC() string {
+	ifaces, err := net.Interfaces()
+	if err == nil {
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) == 6 {
+				return iface.HardwareAddr.String()
+			}
+		}
+	}
+	return "00:00:00:00:00:00"
+}
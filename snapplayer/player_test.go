@@ -0,0 +1,185 @@
+package snapplayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// chanSink hands every frame to the test
+type chanSink struct {
+	opened chan snapcast.SampleFormat
+	frames chan *Frame
+}
+
+func newChanSink() *chanSink {
+	return &chanSink{opened: make(chan snapcast.SampleFormat, 1), frames: make(chan *Frame, 16)}
+}
+
+func (s *chanSink) Open(format snapcast.SampleFormat) error {
+	s.opened <- format
+	return nil
+}
+
+func (s *chanSink) Write(f *Frame) error {
+	s.frames <- f
+	return nil
+}
+
+func (s *chanSink) Close() error { return nil }
+
+func TestPlayer(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		control  = snapclient.New(&snapclient.Options{Host: srv.Host(), Transport: snapclient.TransportWebSocket})
+		connects = make(chan *snapcast.ClientOnConnect, 1)
+	)
+	defer control.Close()
+	if _, err := control.Listen(ctx, &snapclient.Notifications{ClientOnConnect: connects}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		sink = newChanSink()
+		p    = New(&Options{Host: srv.StreamHost(), ID: "go-player", HostName: "test-host", MAC: "02:00:00:00:00:01", Sink: sink})
+		done = make(chan error, 1)
+	)
+	runCtx, stop := context.WithCancel(ctx)
+	go func() { done <- p.Run(runCtx) }()
+
+	select {
+	case msg := <-connects:
+		if msg.ID != "go-player" {
+			t.Errorf("connected %q", msg.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("player never connected")
+	}
+
+	// Shows up like any other snapclient
+	status, err := control.ServerGetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *snapcast.Client
+	for _, g := range status.Server.Groups {
+		for i := range g.Clients {
+			if g.Clients[i].ID == "go-player" {
+				found = &g.Clients[i]
+			}
+		}
+	}
+	if found == nil || !found.Connected || found.Host.Name != "test-host" || found.Host.MAC != "02:00:00:00:00:01" {
+		t.Fatalf("client in status = %+v", found)
+	}
+
+	if format := <-sink.opened; format != snapcasttest.DefaultSampleFormat {
+		t.Errorf("opened %s", format)
+	}
+
+	// Captured almost a buffer ago, so it is due right away
+	var pcm = bytes.Repeat([]byte{1, 2, 3, 4}, 960)
+	srv.WriteChunk(time.Now().Add(-snapcasttest.DefaultBufferMs*time.Millisecond+50*time.Millisecond), pcm)
+
+	select {
+	case f := <-sink.frames:
+		if !bytes.Equal(f.Data, pcm) || f.Format != snapcasttest.DefaultSampleFormat || f.Volume != 100 {
+			t.Errorf("frame %+v", f)
+		}
+		if early := time.Until(f.PlayAt); early > 100*time.Millisecond {
+			t.Errorf("written %s before it's due", early)
+		}
+	case <-ctx.Done():
+		t.Fatal("no frame played")
+	}
+
+	// Volume changes from the control API arrive as ServerSettings
+	if _, err := control.ClientSetVolume(ctx, "go-player", snapcast.Volume{Percent: 35}); err != nil {
+		t.Fatal(err)
+	}
+	for p.Settings().Volume != 35 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("settings = %+v", p.Settings())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+}
+
+func TestClockSync(t *testing.T) {
+	var c = newClockSync()
+
+	// Server is 3s ahead, 5ms each way plus a few round trips stuck in a queue
+	const offset = 3 * time.Second
+	for i := 0; i < 20; i++ {
+		var up, down = 5 * time.Millisecond, 5 * time.Millisecond
+		if i%5 == 0 {
+			up += 200 * time.Millisecond
+		}
+		c.add(offset+up, -offset+down)
+	}
+
+	select {
+	case <-c.ready:
+	default:
+		t.Fatal("not ready after samples")
+	}
+	if got := c.Offset(); got != offset {
+		t.Errorf("offset = %s, want %s", got, offset)
+	}
+}
+
+func TestWAVSink(t *testing.T) {
+	var (
+		path   = filepath.Join(t.TempDir(), "out.wav")
+		format = snapcast.SampleFormat{Rate: 44100, Bits: 16, Channels: 2}
+	)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var sink = NewWAVSink(f)
+	if err := sink.Open(format); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(&Frame{Format: format, Data: make([]byte, 400)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Open(snapcast.SampleFormat{Rate: 48000, Bits: 16, Channels: 2}); !errors.Is(err, ErrFormatChanged) {
+		t.Errorf("expected ErrFormatChanged, got %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 44+1200 || binary.LittleEndian.Uint32(b[4:]) != 36+1200 || binary.LittleEndian.Uint32(b[40:]) != 1200 {
+		t.Errorf("bad wav file of %d bytes: %x", len(b), b[:44])
+	}
+}
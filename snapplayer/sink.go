@@ -0,0 +1,154 @@
+package snapplayer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapproto"
+)
+
+// Frame is a chunk of decoded audio and when to play it
+type Frame struct {
+	// Local time the first sample should be audible
+	PlayAt time.Time
+	// Server time the chunk was captured
+	Timestamp time.Time
+	Format    snapcast.SampleFormat
+	// Interleaved little endian PCM
+	Data []byte
+	// Client volume from the server, sinks apply it as they see fit
+	Volume int
+	Muted  bool
+}
+
+// AudioSink receives frames in order, shortly before PlayAt.
+// Open is called again if the server changes the stream format
+type AudioSink interface {
+	Open(format snapcast.SampleFormat) error
+	Write(f *Frame) error
+	Close() error
+}
+
+// NullSink discards audio and counts what it got
+type NullSink struct {
+	mu     sync.Mutex
+	format snapcast.SampleFormat
+	frames int
+	bytes  int
+}
+
+func (s *NullSink) Open(format snapcast.SampleFormat) error {
+	s.mu.Lock()
+	s.format = format
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *NullSink) Write(f *Frame) error {
+	s.mu.Lock()
+	s.frames++
+	s.bytes += len(f.Data)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *NullSink) Close() error { return nil }
+
+func (s *NullSink) Format() snapcast.SampleFormat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.format
+}
+
+// Frames written so far
+func (s *NullSink) Frames() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+// Bytes of PCM written so far
+func (s *NullSink) Bytes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+var ErrFormatChanged = errors.New("snapplayer: wav sink can't change format")
+
+// WAVSink writes the raw stream to a WAV file, ignoring volume and timing.
+// If w is an io.WriteSeeker the RIFF sizes are fixed up on Close, otherwise they're left at
+// their maximum so readers keep going until EOF. w is not closed
+type WAVSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format snapcast.SampleFormat
+	size   uint32
+}
+
+func NewWAVSink(w io.Writer) *WAVSink {
+	return &WAVSink{w: w}
+}
+
+func (s *WAVSink) Open(format snapcast.SampleFormat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.format != (snapcast.SampleFormat{}) {
+		if s.format != format {
+			return ErrFormatChanged
+		}
+		return nil
+	}
+	s.format = format
+
+	var header = snapproto.PCMHeader(format).Payload
+	if _, ok := s.w.(io.WriteSeeker); !ok {
+		binary.LittleEndian.PutUint32(header[4:], 0xffffffff)
+		binary.LittleEndian.PutUint32(header[40:], 0xffffffff)
+	}
+	_, err := s.w.Write(header)
+	return err
+}
+
+func (s *WAVSink) Write(f *Frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.w.Write(f.Data)
+	s.size += uint32(n)
+	return err
+}
+
+func (s *WAVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, ok := s.w.(io.WriteSeeker)
+	if !ok || s.format == (snapcast.SampleFormat{}) {
+		return nil
+	}
+
+	var size [4]byte
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{4, 36 + s.size},
+		{40, s.size},
+	} {
+		binary.LittleEndian.PutUint32(size[:], field.value)
+		if _, err := ws.Seek(field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := ws.Write(size[:]); err != nil {
+			return err
+		}
+	}
+	_, err := ws.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,57 @@
+package snapplayer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// syncSamples is how many time diffs the median is taken over, the same as snapclient
+const syncSamples = 200
+
+// clockSync estimates the offset of the server clock from Time round trips
+type clockSync struct {
+	mu      sync.Mutex
+	diffs   []time.Duration
+	next    int
+	offset  time.Duration
+	ready   chan struct{}
+	isReady bool
+}
+
+func newClockSync() *clockSync {
+	return &clockSync{ready: make(chan struct{})}
+}
+
+// add a round trip, c2s is server receive minus client send and s2c client receive minus server send.
+// Both include the offset with opposite signs, so half their difference is the offset if the
+// network delay is symmetric. The median throws away the round trips where it wasn't
+func (c *clockSync) add(c2s, s2c time.Duration) {
+	var diff = (c2s - s2c) / 2
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.diffs) < syncSamples {
+		c.diffs = append(c.diffs, diff)
+	} else {
+		c.diffs[c.next] = diff
+		c.next = (c.next + 1) % syncSamples
+	}
+
+	var sorted = append([]time.Duration(nil), c.diffs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	c.offset = sorted[len(sorted)/2]
+
+	if !c.isReady {
+		c.isReady = true
+		close(c.ready)
+	}
+}
+
+// Offset to add to local time to get server time
+func (c *clockSync) Offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
+}
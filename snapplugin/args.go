@@ -0,0 +1,63 @@
+package snapplugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Args snapserver starts control scripts with, followed by the stream's controlscriptparams
+type Args struct {
+	// ID of the stream the script controls
+	Stream string
+	// Control API of the server, e.g. to read the stream's URI
+	Host string
+	Port int
+	// Everything else, from controlscriptparams
+	Extra []string
+}
+
+// ParseArgs reads --stream, --snapcast-host and --snapcast-port from os.Args[1:]
+func ParseArgs(args []string) (Args, error) {
+	var a Args
+	for i := 0; i < len(args); i++ {
+		var (
+			key, value, hasValue = strings.Cut(args[i], "=")
+			target               *string
+			port                 string
+		)
+		switch key {
+		case "--stream":
+			target = &a.Stream
+		case "--snapcast-host":
+			target = &a.Host
+		case "--snapcast-port":
+			target = &port
+		default:
+			a.Extra = append(a.Extra, args[i])
+			continue
+		}
+
+		if !hasValue {
+			if i+1 == len(args) {
+				return a, fmt.Errorf("snapplugin: %s needs a value", key)
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+
+		if target == &port {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return a, fmt.Errorf("snapplugin: --snapcast-port %q is not a number", port)
+			}
+			a.Port = p
+		}
+	}
+
+	if a.Stream == "" {
+		return a, fmt.Errorf("snapplugin: missing --stream")
+	}
+	return a, nil
+}
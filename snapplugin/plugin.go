@@ -0,0 +1,60 @@
+// Package snapplugin is for writing stream control scripts. snapserver starts the script given as
+// controlscript in the stream URI and talks JSON-RPC with it over stdin and stdout, forwarding
+// Stream.Control and Stream.SetProperty and taking the player's properties from it.
+// See https://github.com/badaix/snapcast/blob/develop/doc/json_rpc_api/stream_plugin.md
+package snapplugin
+
+import (
+	"context"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Requests from snapserver
+const (
+	MethodControl       snapcast.RequestMethod = "Plugin.Stream.Player.Control"
+	MethodSetProperty   snapcast.RequestMethod = "Plugin.Stream.Player.SetProperty"
+	MethodGetProperties snapcast.RequestMethod = "Plugin.Stream.Player.GetProperties"
+)
+
+// Notifications to snapserver
+const (
+	MethodProperties snapcast.NotificationMethod = "Plugin.Stream.Player.Properties"
+	MethodReady      snapcast.NotificationMethod = "Plugin.Stream.Ready"
+	MethodLog        snapcast.NotificationMethod = "Plugin.Stream.Log"
+)
+
+type Severity string
+
+const (
+	SeverityTrace   Severity = "trace"
+	SeverityDebug   Severity = "debug"
+	SeverityInfo    Severity = "info"
+	SeverityNotice  Severity = "notice"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+	SeverityFatal   Severity = "fatal"
+)
+
+// ControlParams of the seek and setPosition commands, in seconds
+type ControlParams struct {
+	Offset   float64 `json:"offset,omitempty"`
+	Position float64 `json:"position,omitempty"`
+}
+
+// PropertyChange has the one property snapserver asks to set
+type PropertyChange struct {
+	LoopStatus *snapcast.LoopStatus `json:"loopStatus,omitempty"`
+	Shuffle    *bool                `json:"shuffle,omitempty"`
+	Volume     *int                 `json:"volume,omitempty"`
+	Mute       *bool                `json:"mute,omitempty"`
+	Rate       *float64             `json:"rate,omitempty"`
+}
+
+// Player is what the control script controls. Errors of type *snapcast.RPCError are sent
+// to snapserver with their code, anything else as an internal error
+type Player interface {
+	Control(ctx context.Context, command snapcast.StreamCommand, params ControlParams) error
+	SetProperty(ctx context.Context, change PropertyChange) error
+	GetProperties(ctx context.Context) (*snapcast.Properties, error)
+}
@@ -0,0 +1,181 @@
+package snapplugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Server answers snapserver's requests and sends notifications to it
+type Server struct {
+	player Player
+	r      io.Reader
+	mu     sync.Mutex
+	w      io.Writer
+}
+
+func NewServer(player Player, r io.Reader, w io.Writer) *Server {
+	return &Server{player: player, r: r, w: w}
+}
+
+// Run serves player on stdin and stdout, the way snapserver starts control scripts
+func Run(ctx context.Context, player Player) error {
+	return NewServer(player, os.Stdin, os.Stdout).Serve(ctx)
+}
+
+// Serve sends Plugin.Stream.Ready and handles requests one at a time until the input ends or ctx is done
+func (s *Server) Serve(ctx context.Context) error {
+	var lines = make(chan []byte)
+	var readErr = make(chan error, 1)
+	go func() {
+		var scanner = bufio.NewScanner(s.r)
+		scanner.Buffer(nil, 4<<20)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	if err := s.notify(MethodReady, nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+			if res := s.handle(ctx, line); res != nil {
+				if err := s.write(res); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// SetProperties tells snapserver about the player's state, send it whenever something changes
+func (s *Server) SetProperties(props *snapcast.Properties) error {
+	return s.notify(MethodProperties, props)
+}
+
+// Log a message to snapserver's log
+func (s *Server) Log(severity Severity, message string) error {
+	return s.notify(MethodLog, &struct {
+		Severity Severity `json:"severity"`
+		Message  string   `json:"message"`
+	}{severity, message})
+}
+
+func (s *Server) notify(method snapcast.NotificationMethod, params interface{}) error {
+	return s.write(&snapcast.Notification{JsonRPC: "2.0", Method: &method, Params: params})
+}
+
+func (s *Server) write(v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(raw, '\n'))
+	return err
+}
+
+func (s *Server) handle(ctx context.Context, raw []byte) *snapcast.Response {
+	var req struct {
+		ID     *int                   `json:"id"`
+		Method snapcast.RequestMethod `json:"method"`
+		Params json.RawMessage        `json:"params"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, snapcast.ErrParse)
+	}
+
+	result, err := s.call(ctx, req.Method, req.Params)
+
+	// Requests without an id don't get a response
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	return &snapcast.Response{ID: req.ID, JsonRPC: "2.0", Result: result}
+}
+
+func (s *Server) call(ctx context.Context, method snapcast.RequestMethod, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodControl:
+		var p struct {
+			Command snapcast.StreamCommand `json:"command"`
+			Params  ControlParams          `json:"params"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Command == "" {
+			return nil, snapcast.ErrInvalidParams
+		}
+		if err := s.player.Control(ctx, p.Command, p.Params); err != nil {
+			return nil, err
+		}
+		return "ok", nil
+
+	case MethodSetProperty:
+		var p PropertyChange
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, snapcast.ErrInvalidParams
+		}
+		if err := s.player.SetProperty(ctx, p); err != nil {
+			return nil, err
+		}
+		return "ok", nil
+
+	case MethodGetProperties:
+		props, err := s.player.GetProperties(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return props, nil
+	}
+	return nil, snapcast.ErrMethodNotFound
+}
+
+func errorResponse(id *int, err error) *snapcast.Response {
+	var rpcErr *snapcast.RPCError
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: err.Error()}
+	}
+	var message = rpcErr.Message
+	if message == "" {
+		message = standardMessages[rpcErr.Code]
+	}
+	return &snapcast.Response{
+		ID:      id,
+		JsonRPC: "2.0",
+		Error:   &snapcast.Error{Code: rpcErr.Code, Message: message, Data: rpcErr.Data},
+	}
+}
+
+// standardMessages for the sentinel errors, which have none
+var standardMessages = map[int]string{
+	snapcast.CodeParseError:     "Parse error",
+	snapcast.CodeInvalidRequest: "Invalid request",
+	snapcast.CodeMethodNotFound: "Method not found",
+	snapcast.CodeInvalidParams:  "Invalid params",
+	snapcast.CodeInternalError:  "Internal error",
+}
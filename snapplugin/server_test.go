@@ -0,0 +1,163 @@
+package snapplugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+type fakePlayer struct {
+	commands []snapcast.StreamCommand
+	params   []ControlParams
+	changes  []PropertyChange
+	props    snapcast.Properties
+}
+
+func (p *fakePlayer) Control(ctx context.Context, command snapcast.StreamCommand, params ControlParams) error {
+	if command == snapcast.StreamCommandNext && !p.props.CanGoNext {
+		return &snapcast.RPCError{Code: snapcast.CodeCanGoNextIsFalse, Message: "Stream property canGoNext is false"}
+	}
+	p.commands = append(p.commands, command)
+	p.params = append(p.params, params)
+	return nil
+}
+
+func (p *fakePlayer) SetProperty(ctx context.Context, change PropertyChange) error {
+	if change.Volume != nil && *change.Volume > 100 {
+		return errors.New("volume out of range")
+	}
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+func (p *fakePlayer) GetProperties(ctx context.Context) (*snapcast.Properties, error) {
+	return &p.props, nil
+}
+
+// pluginConn is snapserver's end of the script's stdin and stdout
+type pluginConn struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out *bufio.Scanner
+}
+
+func (c *pluginConn) send(line string) {
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *pluginConn) read() map[string]interface{} {
+	if !c.out.Scan() {
+		c.t.Fatalf("no output: %v", c.out.Err())
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(c.out.Bytes(), &msg); err != nil {
+		c.t.Fatalf("invalid output %q: %v", c.out.Text(), err)
+	}
+	return msg
+}
+
+func startServer(t *testing.T, player Player) (*Server, *pluginConn) {
+	var (
+		inR, inW   = io.Pipe()
+		outR, outW = io.Pipe()
+		srv        = NewServer(player, inR, outW)
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	var done = make(chan error, 1)
+	go func() { done <- srv.Serve(ctx) }()
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+		cancel()
+		outR.Close()
+	})
+	return srv, &pluginConn{t: t, in: inW, out: bufio.NewScanner(outR)}
+}
+
+func TestServer(t *testing.T) {
+	var (
+		player = &fakePlayer{props: snapcast.Properties{PlaybackStatus: snapcast.PlaybackPaused, CanControl: true, Volume: 40}}
+		srv, c = startServer(t, player)
+	)
+
+	if msg := c.read(); msg["method"] != string(MethodReady) {
+		t.Fatalf("first message %v, want %s", msg, MethodReady)
+	}
+
+	c.send(`{"id": 1, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.Control", "params": {"command": "seek", "params": {"offset": 30}}}`)
+	if msg := c.read(); msg["id"] != 1.0 || msg["result"] != "ok" {
+		t.Errorf("control response %v", msg)
+	}
+	if !reflect.DeepEqual(player.commands, []snapcast.StreamCommand{snapcast.StreamCommandSeek}) || player.params[0].Offset != 30 {
+		t.Errorf("commands %v %v", player.commands, player.params)
+	}
+
+	c.send(`{"id": 2, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.Control", "params": {"command": "next"}}`)
+	if msg := c.read(); msg["error"].(map[string]interface{})["code"] != float64(snapcast.CodeCanGoNextIsFalse) {
+		t.Errorf("control error response %v", msg)
+	}
+
+	c.send(`{"id": 3, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.SetProperty", "params": {"loopStatus": "track"}}`)
+	if msg := c.read(); msg["result"] != "ok" {
+		t.Errorf("set property response %v", msg)
+	}
+	if len(player.changes) != 1 || *player.changes[0].LoopStatus != snapcast.LoopTrack || player.changes[0].Volume != nil {
+		t.Errorf("changes %+v", player.changes)
+	}
+
+	c.send(`{"id": 4, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.SetProperty", "params": {"volume": 120}}`)
+	if msg := c.read(); msg["error"].(map[string]interface{})["message"] != "volume out of range" {
+		t.Errorf("set property error response %v", msg)
+	}
+
+	c.send(`{"id": 5, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.GetProperties"}`)
+	if msg := c.read(); msg["result"].(map[string]interface{})["playbackStatus"] != "paused" {
+		t.Errorf("get properties response %v", msg)
+	}
+
+	c.send(`{"id": 6, "jsonrpc": "2.0", "method": "Plugin.Stream.Player.Dance"}`)
+	if msg := c.read(); msg["error"].(map[string]interface{})["message"] != "Method not found" {
+		t.Errorf("unknown method response %v", msg)
+	}
+
+	// Notifications pushed by the plugin
+	go func() {
+		srv.SetProperties(&snapcast.Properties{PlaybackStatus: snapcast.PlaybackPlaying, Metadata: &snapcast.Metadata{Title: "Song"}})
+		srv.Log(SeverityInfo, "playing")
+	}()
+	msg := c.read()
+	if msg["method"] != string(MethodProperties) || msg["params"].(map[string]interface{})["metadata"].(map[string]interface{})["title"] != "Song" {
+		t.Errorf("properties notification %v", msg)
+	}
+	if msg := c.read(); msg["method"] != string(MethodLog) || msg["params"].(map[string]interface{})["severity"] != "info" {
+		t.Errorf("log notification %v", msg)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	args, err := ParseArgs([]string{"--stream=Spotify", "--snapcast-host", "127.0.0.1", "--snapcast-port=1780", "--mpd-host=localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = Args{Stream: "Spotify", Host: "127.0.0.1", Port: 1780, Extra: []string{"--mpd-host=localhost"}}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %+v", args)
+	}
+
+	for _, bad := range [][]string{{}, {"--stream"}, {"--stream=x", "--snapcast-port=http"}} {
+		if _, err := ParseArgs(bad); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
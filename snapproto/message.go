@@ -0,0 +1,223 @@
+// Package snapproto encodes and decodes the snapcast binary streaming protocol spoken on port 1704.
+// All values are little endian, see https://github.com/badaix/snapcast/blob/develop/doc/binary_protocol.md
+package snapproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type MessageType uint16
+
+const (
+	TypeBase           MessageType = 0
+	TypeCodecHeader    MessageType = 1
+	TypeWireChunk      MessageType = 2
+	TypeServerSettings MessageType = 3
+	TypeTime           MessageType = 4
+	TypeHello          MessageType = 5
+	// Deprecated by snapserver, decoded as Raw
+	TypeStreamTags MessageType = 6
+	TypeClientInfo MessageType = 7
+	TypeError      MessageType = 8
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case TypeBase:
+		return "Base"
+	case TypeCodecHeader:
+		return "CodecHeader"
+	case TypeWireChunk:
+		return "WireChunk"
+	case TypeServerSettings:
+		return "ServerSettings"
+	case TypeTime:
+		return "Time"
+	case TypeHello:
+		return "Hello"
+	case TypeStreamTags:
+		return "StreamTags"
+	case TypeClientInfo:
+		return "ClientInfo"
+	case TypeError:
+		return "Error"
+	}
+	return fmt.Sprintf("MessageType(%d)", uint16(t))
+}
+
+// HeaderSize is the length of the base message header preceding every payload
+const HeaderSize = 26
+
+// MaxPayloadSize guards against allocating garbage sizes from a corrupt stream
+var MaxPayloadSize uint32 = 16 << 20
+
+var ErrPayloadTooLarge = errors.New("snapproto: payload too large")
+
+// Timeval is a timestamp or duration as seconds and microseconds
+type Timeval struct {
+	Sec  int32
+	Usec int32
+}
+
+func TimevalFromTime(t time.Time) Timeval {
+	return Timeval{Sec: int32(t.Unix()), Usec: int32(t.Nanosecond() / 1000)}
+}
+
+func TimevalFromDuration(d time.Duration) Timeval {
+	return Timeval{Sec: int32(d / time.Second), Usec: int32((d % time.Second) / time.Microsecond)}
+}
+
+func (t Timeval) Time() time.Time {
+	return time.Unix(int64(t.Sec), int64(t.Usec)*1000)
+}
+
+func (t Timeval) Duration() time.Duration {
+	return time.Duration(t.Sec)*time.Second + time.Duration(t.Usec)*time.Microsecond
+}
+
+func (t Timeval) IsZero() bool {
+	return t.Sec == 0 && t.Usec == 0
+}
+
+// Header is the base message header
+type Header struct {
+	Type     MessageType
+	ID       uint16
+	RefersTo uint16
+	// Set by the sender just before writing
+	Sent Timeval
+	// Set by the receiver when the message arrived
+	Received Timeval
+	// Payload length in bytes
+	Size uint32
+}
+
+func (h *Header) MarshalBinary() ([]byte, error) {
+	var b = make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint16(b[0:], uint16(h.Type))
+	binary.LittleEndian.PutUint16(b[2:], h.ID)
+	binary.LittleEndian.PutUint16(b[4:], h.RefersTo)
+	binary.LittleEndian.PutUint32(b[6:], uint32(h.Sent.Sec))
+	binary.LittleEndian.PutUint32(b[10:], uint32(h.Sent.Usec))
+	binary.LittleEndian.PutUint32(b[14:], uint32(h.Received.Sec))
+	binary.LittleEndian.PutUint32(b[18:], uint32(h.Received.Usec))
+	binary.LittleEndian.PutUint32(b[22:], h.Size)
+	return b, nil
+}
+
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderSize {
+		return io.ErrUnexpectedEOF
+	}
+	h.Type = MessageType(binary.LittleEndian.Uint16(b[0:]))
+	h.ID = binary.LittleEndian.Uint16(b[2:])
+	h.RefersTo = binary.LittleEndian.Uint16(b[4:])
+	h.Sent.Sec = int32(binary.LittleEndian.Uint32(b[6:]))
+	h.Sent.Usec = int32(binary.LittleEndian.Uint32(b[10:]))
+	h.Received.Sec = int32(binary.LittleEndian.Uint32(b[14:]))
+	h.Received.Usec = int32(binary.LittleEndian.Uint32(b[18:]))
+	h.Size = binary.LittleEndian.Uint32(b[22:])
+	return nil
+}
+
+// Message is the payload of a message
+type Message interface {
+	Type() MessageType
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(b []byte) error
+}
+
+// New returns an empty message for the type, Raw for unknown types
+func New(t MessageType) Message {
+	switch t {
+	case TypeCodecHeader:
+		return &CodecHeader{}
+	case TypeWireChunk:
+		return &WireChunk{}
+	case TypeServerSettings:
+		return &ServerSettings{}
+	case TypeTime:
+		return &Time{}
+	case TypeHello:
+		return &Hello{}
+	case TypeClientInfo:
+		return &ClientInfo{}
+	case TypeError:
+		return &Error{}
+	}
+	return &Raw{MessageType: t}
+}
+
+// Encode a message, the type and size of the header are filled in from msg
+func Encode(h Header, msg Message) ([]byte, error) {
+	payload, err := msg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	h.Type = msg.Type()
+	h.Size = uint32(len(payload))
+	header, _ := h.MarshalBinary()
+
+	return append(header, payload...), nil
+}
+
+// Decode a single message of exactly header plus payload
+func Decode(b []byte) (Header, Message, error) {
+	var h Header
+	if err := h.UnmarshalBinary(b); err != nil {
+		return h, nil, err
+	}
+	if uint32(len(b)-HeaderSize) != h.Size {
+		return h, nil, fmt.Errorf("snapproto: %s payload is %d bytes, header says %d", h.Type, len(b)-HeaderSize, h.Size)
+	}
+
+	var msg = New(h.Type)
+	if err := msg.UnmarshalBinary(b[HeaderSize:]); err != nil {
+		return h, nil, fmt.Errorf("snapproto: %s: %w", h.Type, err)
+	}
+	return h, msg, nil
+}
+
+func WriteMessage(w io.Writer, h Header, msg Message) error {
+	b, err := Encode(h, msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadMessage reads the next message. Received is left as sent, callers stamp it on arrival
+func ReadMessage(r io.Reader) (Header, Message, error) {
+	var (
+		h   Header
+		buf = make([]byte, HeaderSize)
+	)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, nil, err
+	}
+	h.UnmarshalBinary(buf)
+
+	if h.Size > MaxPayloadSize {
+		return h, nil, fmt.Errorf("%w: %s of %d bytes", ErrPayloadTooLarge, h.Type, h.Size)
+	}
+
+	var payload = make([]byte, h.Size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return h, nil, err
+	}
+
+	var msg = New(h.Type)
+	if err := msg.UnmarshalBinary(payload); err != nil {
+		return h, nil, fmt.Errorf("snapproto: %s: %w", h.Type, err)
+	}
+	return h, msg, nil
+}
@@ -0,0 +1,195 @@
+package snapproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// Fixtures in testdata are hand-built from the message layout in the snapcast binary protocol docs,
+// not captured from a snapserver or snapclient
+var fixtures = []struct {
+	file   string
+	header Header
+	msg    Message
+}{
+	{
+		file:   "hello.bin",
+		header: Header{Type: TypeHello, Sent: Timeval{1700000000, 120000}, Size: 223},
+		msg: &Hello{
+			Arch:                      "x86_64",
+			ClientName:                "Snapclient",
+			HostName:                  "living-room",
+			ID:                        "00:21:6a:7d:74:fc",
+			Instance:                  1,
+			MAC:                       "00:21:6a:7d:74:fc",
+			OS:                        "Debian GNU/Linux 12 (bookworm)",
+			SnapStreamProtocolVersion: 2,
+			Version:                   "0.29.0",
+		},
+	},
+	{
+		file:   "server_settings.bin",
+		header: Header{Type: TypeServerSettings, Sent: Timeval{1700000000, 130500}, Received: Timeval{1700000000, 130250}, Size: 59},
+		msg:    &ServerSettings{BufferMs: 1000, Volume: 74},
+	},
+	{
+		file:   "codec_header.bin",
+		header: Header{Type: TypeCodecHeader, Sent: Timeval{1700000000, 131000}, Received: Timeval{1700000000, 130900}, Size: 55},
+		msg: &CodecHeader{Codec: "pcm", Payload: []byte{
+			'R', 'I', 'F', 'F', 36, 0, 0, 0, 'W', 'A', 'V', 'E',
+			'f', 'm', 't', ' ', 16, 0, 0, 0, 1, 0, 2, 0, 0x80, 0xbb, 0, 0, 0, 0xee, 2, 0, 4, 0, 16, 0,
+			'd', 'a', 't', 'a', 0, 0, 0, 0,
+		}},
+	},
+	{
+		file:   "wire_chunk.bin",
+		header: Header{Type: TypeWireChunk, Sent: Timeval{1700000001, 5000}, Received: Timeval{1700000001, 4800}, Size: 28},
+		msg: &WireChunk{
+			Timestamp: Timeval{1700000000, 980000},
+			Payload:   []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		},
+	},
+	{
+		file:   "time.bin",
+		header: Header{Type: TypeTime, ID: 7, Sent: Timeval{1700000002, 0}, Size: 8},
+		msg:    &Time{},
+	},
+	{
+		file:   "time_reply.bin",
+		header: Header{Type: TypeTime, RefersTo: 7, Sent: Timeval{1700000002, 1500}, Received: Timeval{1700000002, 1800}, Size: 8},
+		msg:    &Time{Latency: Timeval{0, 1234}},
+	},
+	{
+		file:   "client_info.bin",
+		header: Header{Type: TypeClientInfo, ID: 3, Sent: Timeval{1700000003, 0}, Size: 30},
+		msg:    &ClientInfo{Muted: true, Volume: 30},
+	},
+	{
+		file:   "error.bin",
+		header: Header{Type: TypeError, Sent: Timeval{1700000004, 0}, Received: Timeval{1700000004, 10}, Size: 47},
+		msg:    &Error{Code: 401, Error: "Unauthorized", Message: "Authentication required"},
+	},
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFixtures(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.file, func(t *testing.T) {
+			var raw = readFixture(t, f.file)
+
+			h, msg, err := Decode(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h != f.header {
+				t.Errorf("header = %+v, want %+v", h, f.header)
+			}
+			if !reflect.DeepEqual(msg, f.msg) {
+				t.Errorf("message = %+v, want %+v", msg, f.msg)
+			}
+
+			out, err := Encode(f.header, f.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, raw) {
+				t.Errorf("encoded\n%x\nwant\n%x", out, raw)
+			}
+		})
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	var stream bytes.Buffer
+	for _, f := range fixtures {
+		stream.Write(readFixture(t, f.file))
+	}
+
+	for _, f := range fixtures {
+		h, msg, err := ReadMessage(&stream)
+		if err != nil {
+			t.Fatalf("%s: %v", f.file, err)
+		}
+		if h != f.header || !reflect.DeepEqual(msg, f.msg) {
+			t.Errorf("%s: got %+v %+v", f.file, h, msg)
+		}
+	}
+	if _, _, err := ReadMessage(&stream); err != io.EOF {
+		t.Errorf("expected io.EOF at the end, got %v", err)
+	}
+
+	var truncated = readFixture(t, "wire_chunk.bin")
+	if _, _, err := ReadMessage(bytes.NewReader(truncated[:len(truncated)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated payload, got %v", err)
+	}
+
+	var huge = readFixture(t, "wire_chunk.bin")
+	binary.LittleEndian.PutUint32(huge[22:], MaxPayloadSize+1)
+	if _, _, err := ReadMessage(bytes.NewReader(huge)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("expected ErrPayloadTooLarge, got %v", err)
+	}
+}
+
+func TestUnknownType(t *testing.T) {
+	var raw = readFixture(t, "time.bin")
+	binary.LittleEndian.PutUint16(raw, uint16(TypeStreamTags))
+
+	h, msg, err := Decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := msg.(*Raw); !ok || r.Type() != TypeStreamTags || len(r.Payload) != 8 {
+		t.Fatalf("expected Raw StreamTags, got %+v", msg)
+	}
+
+	out, err := Encode(h, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, raw) {
+		t.Errorf("encoded %x, want %x", out, raw)
+	}
+}
+
+func TestTimeval(t *testing.T) {
+	var now = time.Unix(1700000000, 123456000)
+	if got := TimevalFromTime(now); got != (Timeval{1700000000, 123456}) || !got.Time().Equal(now) {
+		t.Errorf("TimevalFromTime = %+v", got)
+	}
+
+	var d = 2*time.Second + 500*time.Microsecond
+	if got := TimevalFromDuration(d); got != (Timeval{2, 500}) || got.Duration() != d {
+		t.Errorf("TimevalFromDuration = %+v", got)
+	}
+}
+
+func TestPCMHeader(t *testing.T) {
+	var (
+		sf      = snapcast.SampleFormat{Rate: 48000, Bits: 16, Channels: 2}
+		fixture = fixtures[2].msg.(*CodecHeader)
+	)
+	if h := PCMHeader(sf); !reflect.DeepEqual(h, fixture) {
+		t.Errorf("PCMHeader = %x, want %x", h.Payload, fixture.Payload)
+	}
+
+	got, err := ParsePCMHeader(fixture.Payload)
+	if err != nil || got != sf {
+		t.Errorf("ParsePCMHeader = %+v, %v", got, err)
+	}
+}
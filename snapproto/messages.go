@@ -0,0 +1,254 @@
+package snapproto
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CodecHeader carries the codec name and the codec specific stream header,
+// e.g. a RIFF header for pcm or the STREAMINFO block for flac
+type CodecHeader struct {
+	Codec   string
+	Payload []byte
+}
+
+// WireChunk is a chunk of encoded audio, Timestamp is the server time of its first sample
+type WireChunk struct {
+	Timestamp Timeval
+	Payload   []byte
+}
+
+// Time is used to estimate the clock offset to the server.
+// In a reply Latency is the server receive time minus the client send time
+type Time struct {
+	Latency Timeval
+}
+
+// Hello is the first message a client sends
+type Hello struct {
+	Arch                      string `json:"Arch"`
+	ClientName                string `json:"ClientName"`
+	HostName                  string `json:"HostName"`
+	ID                        string `json:"ID"`
+	Instance                  int    `json:"Instance"`
+	MAC                       string `json:"MAC"`
+	OS                        string `json:"OS"`
+	SnapStreamProtocolVersion int    `json:"SnapStreamProtocolVersion"`
+	Version                   string `json:"Version"`
+}
+
+// ServerSettings is sent on connect and whenever the client config changes
+type ServerSettings struct {
+	BufferMs int  `json:"bufferMs"`
+	Latency  int  `json:"latency"`
+	Muted    bool `json:"muted"`
+	Volume   int  `json:"volume"`
+}
+
+// ClientInfo reports volume changes made on the client
+type ClientInfo struct {
+	Muted  bool `json:"muted"`
+	Volume int  `json:"volume"`
+}
+
+// Error is sent by the server before it drops a client, e.g. for failed authentication
+type Error struct {
+	Code    uint32
+	Error   string
+	Message string
+}
+
+// Raw keeps the payload of types this package doesn't decode
+type Raw struct {
+	MessageType MessageType
+	Payload     []byte
+}
+
+func (*CodecHeader) Type() MessageType    { return TypeCodecHeader }
+func (*WireChunk) Type() MessageType      { return TypeWireChunk }
+func (*Time) Type() MessageType           { return TypeTime }
+func (*Hello) Type() MessageType          { return TypeHello }
+func (*ServerSettings) Type() MessageType { return TypeServerSettings }
+func (*ClientInfo) Type() MessageType     { return TypeClientInfo }
+func (*Error) Type() MessageType          { return TypeError }
+func (r *Raw) Type() MessageType          { return r.MessageType }
+
+func (m *CodecHeader) MarshalBinary() ([]byte, error) {
+	var b = appendString(nil, m.Codec)
+	return appendBytes(b, m.Payload), nil
+}
+
+func (m *CodecHeader) UnmarshalBinary(b []byte) error {
+	var (
+		d   = decoder{b: b}
+		err error
+	)
+	if m.Codec, err = d.string(); err != nil {
+		return err
+	}
+	if m.Payload, err = d.bytes(); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+func (m *WireChunk) MarshalBinary() ([]byte, error) {
+	var b = appendTimeval(nil, m.Timestamp)
+	return appendBytes(b, m.Payload), nil
+}
+
+func (m *WireChunk) UnmarshalBinary(b []byte) error {
+	var (
+		d   = decoder{b: b}
+		err error
+	)
+	if m.Timestamp, err = d.timeval(); err != nil {
+		return err
+	}
+	if m.Payload, err = d.bytes(); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+func (m *Time) MarshalBinary() ([]byte, error) {
+	return appendTimeval(nil, m.Latency), nil
+}
+
+func (m *Time) UnmarshalBinary(b []byte) error {
+	var (
+		d   = decoder{b: b}
+		err error
+	)
+	if m.Latency, err = d.timeval(); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+func (m *Hello) MarshalBinary() ([]byte, error)          { return marshalJSON(m) }
+func (m *Hello) UnmarshalBinary(b []byte) error          { return unmarshalJSON(b, m) }
+func (m *ServerSettings) MarshalBinary() ([]byte, error) { return marshalJSON(m) }
+func (m *ServerSettings) UnmarshalBinary(b []byte) error { return unmarshalJSON(b, m) }
+func (m *ClientInfo) MarshalBinary() ([]byte, error)     { return marshalJSON(m) }
+func (m *ClientInfo) UnmarshalBinary(b []byte) error     { return unmarshalJSON(b, m) }
+
+func (m *Error) MarshalBinary() ([]byte, error) {
+	var b = binary.LittleEndian.AppendUint32(nil, m.Code)
+	b = appendString(b, m.Error)
+	return appendString(b, m.Message), nil
+}
+
+func (m *Error) UnmarshalBinary(b []byte) error {
+	var (
+		d   = decoder{b: b}
+		err error
+	)
+	if m.Code, err = d.uint32(); err != nil {
+		return err
+	}
+	if m.Error, err = d.string(); err != nil {
+		return err
+	}
+	if m.Message, err = d.string(); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+func (m *Raw) MarshalBinary() ([]byte, error) {
+	return m.Payload, nil
+}
+
+func (m *Raw) UnmarshalBinary(b []byte) error {
+	m.Payload = append([]byte(nil), b...)
+	return nil
+}
+
+// JSON messages are a length prefixed JSON string
+func marshalJSON(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendBytes(nil, raw), nil
+}
+
+func unmarshalJSON(b []byte, v interface{}) error {
+	var d = decoder{b: b}
+	raw, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	if err := d.end(); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, v string) []byte {
+	return appendBytes(b, []byte(v))
+}
+
+func appendTimeval(b []byte, t Timeval) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(t.Sec))
+	return binary.LittleEndian.AppendUint32(b, uint32(t.Usec))
+}
+
+type decoder struct {
+	b   []byte
+	off int
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if len(d.b)-d.off < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	v := binary.LittleEndian.Uint32(d.b[d.off:])
+	d.off += 4
+	return v, nil
+}
+
+func (d *decoder) timeval() (Timeval, error) {
+	sec, err := d.uint32()
+	if err != nil {
+		return Timeval{}, err
+	}
+	usec, err := d.uint32()
+	if err != nil {
+		return Timeval{}, err
+	}
+	return Timeval{Sec: int32(sec), Usec: int32(usec)}, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	size, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.b)-d.off) < uint64(size) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	v := append([]byte(nil), d.b[d.off:d.off+int(size)]...)
+	d.off += int(size)
+	return v, nil
+}
+
+func (d *decoder) string() (string, error) {
+	v, err := d.bytes()
+	return string(v), err
+}
+
+func (d *decoder) end() error {
+	if d.off != len(d.b) {
+		return fmt.Errorf("%d trailing bytes", len(d.b)-d.off)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package snapproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+// CodecPCM is the codec name snapserver uses for uncompressed audio
+const CodecPCM = "pcm"
+
+// PCMHeader is the codec header snapserver sends for pcm streams, a RIFF header without data
+func PCMHeader(sf snapcast.SampleFormat) *CodecHeader {
+	var b = make([]byte, 0, 44)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 36)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(sf.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sf.Rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sf.Rate*sf.FrameSize()))
+	b = binary.LittleEndian.AppendUint16(b, uint16(sf.FrameSize()))
+	b = binary.LittleEndian.AppendUint16(b, uint16(sf.Bits))
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	return &CodecHeader{Codec: CodecPCM, Payload: b}
+}
+
+// ParsePCMHeader reads the sample format from the RIFF header of a pcm codec header
+func ParsePCMHeader(payload []byte) (snapcast.SampleFormat, error) {
+	if len(payload) < 12 || string(payload[0:4]) != "RIFF" || string(payload[8:12]) != "WAVE" {
+		return snapcast.SampleFormat{}, errors.New("snapproto: pcm header is not a RIFF WAVE header")
+	}
+
+	// Walk the chunks until fmt, snapserver puts it first but others may not
+	for b := payload[12:]; len(b) >= 8; {
+		var (
+			id   = string(b[0:4])
+			size = binary.LittleEndian.Uint32(b[4:8])
+		)
+		b = b[8:]
+		if uint64(size) > uint64(len(b)) {
+			break
+		}
+		if id == "fmt " {
+			if size < 16 {
+				break
+			}
+			return snapcast.SampleFormat{
+				Channels: int(binary.LittleEndian.Uint16(b[2:])),
+				Rate:     int(binary.LittleEndian.Uint32(b[4:])),
+				Bits:     int(binary.LittleEndian.Uint16(b[14:])),
+			}, nil
+		}
+		b = b[size:]
+	}
+	return snapcast.SampleFormat{}, fmt.Errorf("snapproto: pcm header has no valid fmt chunk")
+}
@@ -0,0 +1,478 @@
+// Package snapproxy multiplexes many JSON-RPC control connections onto one upstream snapclient.
+//
+// Downstreams connect like they would to snapserver: HTTP POSTs and WebSockets on /jsonrpc and
+// newline delimited JSON over TCP. Their requests are forwarded with Client.Send, which gives
+// them upstream IDs, and answered with their own IDs. Every notification is sent to all
+// WebSocket and TCP downstreams. A Policy per downstream limits its request rate and methods.
+//
+// Keep the upstream on the default snapclient.TransportHTTP. snapserver doesn't notify the
+// session that made a change, with a shared upstream connection no downstream would learn of
+// changes made through the proxy
+package snapproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/coder/websocket"
+	"golang.org/x/time/rate"
+)
+
+// Errors the proxy answers with itself, in the range JSON-RPC leaves to servers
+const (
+	CodeMethodNotAllowed = -32001
+	CodeRateLimited      = -32002
+	CodeUpstream         = -32003
+)
+
+var (
+	ErrMethodNotAllowed = &snapcast.RPCError{Code: CodeMethodNotAllowed}
+	ErrRateLimited      = &snapcast.RPCError{Code: CodeRateLimited}
+	ErrUpstream         = &snapcast.RPCError{Code: CodeUpstream}
+)
+
+const (
+	DefaultRequestTimeout = 10 * time.Second
+	DefaultQueueSize      = 64
+
+	// HTTP downstreams are limited by remote host, limiters idle this long are forgotten
+	httpLimiterIdle = 10 * time.Minute
+	// Largest request or batch a downstream may send
+	maxMessageSize = 1 << 20
+)
+
+// Policy of a downstream. The zero value allows everything
+type Policy struct {
+	// Methods the downstream may call, all of them if empty
+	Allow []snapcast.RequestMethod
+	// Methods the downstream may not call, even if in Allow
+	Deny []snapcast.RequestMethod
+	// Requests per second, requests over the limit are answered with CodeRateLimited.
+	// Zero is unlimited
+	Rate  rate.Limit
+	Burst int
+}
+
+func (p *Policy) allows(method snapcast.RequestMethod) bool {
+	for _, m := range p.Deny {
+		if m == method {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, m := range p.Allow {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) limiter() *rate.Limiter {
+	if p.Rate <= 0 {
+		return nil
+	}
+	var burst = p.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(p.Rate, burst)
+}
+
+// Downstream describes a connection for Options.Policy
+type Downstream struct {
+	Transport  snapclient.Transport
+	RemoteAddr string
+	// The POST or the WebSocket upgrade, nil over TCP
+	Request *http.Request
+}
+
+type Options struct {
+	// Picks the policy of a downstream, e.g. by its address or an auth header.
+	// HTTP downstreams are asked on every request but share a rate limit per remote host.
+	// Everything is allowed if nil
+	Policy func(d *Downstream) *Policy
+	// Time an upstream request may take, defaults to DefaultRequestTimeout
+	RequestTimeout time.Duration
+	// Messages queued per WebSocket or TCP downstream, one falling further behind is
+	// disconnected. Defaults to DefaultQueueSize
+	QueueSize int
+	// Hosts of other web pages that may open WebSocket downstreams, e.g. snapweb.local:1780,
+	// see websocket.AcceptOptions. By default browsers may only connect from pages of the proxy's own host
+	OriginPatterns []string
+}
+
+type Proxy struct {
+	opts   Options
+	client *snapclient.Client
+
+	mu           sync.Mutex
+	conns        map[*conn]struct{}
+	httpLimiters map[string]*httpLimiter
+}
+
+type httpLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func New(client *snapclient.Client, opts *Options) *Proxy {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Policy == nil {
+		o.Policy = func(*Downstream) *Policy { return &Policy{} }
+	}
+	if o.RequestTimeout <= 0 {
+		o.RequestTimeout = DefaultRequestTimeout
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	return &Proxy{
+		opts:         o,
+		client:       client,
+		conns:        make(map[*conn]struct{}),
+		httpLimiters: make(map[string]*httpLimiter),
+	}
+}
+
+// Run listens upstream and fans notifications out until ctx is done or the connection ends.
+// Requests are forwarded whether Run is running or not
+func (p *Proxy) Run(ctx context.Context) error {
+	var sub = p.client.OnNotification(func(_ context.Context, msg *snapcast.Notification) {
+		p.broadcast(marshal(msg))
+	})
+	defer sub.Unsubscribe()
+
+	closed, err := p.client.Listen(ctx, nil)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-closed:
+		return err
+	}
+}
+
+// Close disconnects every WebSocket and TCP downstream
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	var conns = make([]*conn, 0, len(p.conns))
+	for c := range p.conns {
+		conns = append(conns, c)
+	}
+	p.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// conn is a WebSocket or TCP downstream. Responses and notifications are written in order by
+// a single writer
+type conn struct {
+	policy  *Policy
+	limiter *rate.Limiter
+	out     chan []byte
+	// Closed once, ends the writer and the connection
+	done      chan struct{}
+	closeOnce sync.Once
+	closeConn func()
+}
+
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.closeConn()
+	})
+}
+
+// send queues msg, a downstream that can't keep up is disconnected
+func (c *conn) send(msg []byte) {
+	select {
+	case c.out <- msg:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+func (p *Proxy) newConn(d *Downstream, closeConn func()) *conn {
+	var policy = p.opts.Policy(d)
+	var c = &conn{
+		policy:    policy,
+		limiter:   policy.limiter(),
+		out:       make(chan []byte, p.opts.QueueSize),
+		done:      make(chan struct{}),
+		closeConn: closeConn,
+	}
+	p.mu.Lock()
+	p.conns[c] = struct{}{}
+	p.mu.Unlock()
+	return c
+}
+
+func (p *Proxy) removeConn(c *conn) {
+	p.mu.Lock()
+	delete(p.conns, c)
+	p.mu.Unlock()
+	c.close()
+}
+
+func (p *Proxy) broadcast(msg []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for c := range p.conns {
+		c.send(msg)
+	}
+}
+
+// writeLoop writes queued messages until the conn is closed or a write fails
+func (c *conn) writeLoop(write func(msg []byte) error) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.out:
+			if err := write(msg); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// ServeHTTP answers POSTs and upgrades WebSockets, mount it on /jsonrpc like snapserver
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		p.serveWebSocket(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var policy = p.opts.Policy(&Downstream{Transport: snapclient.TransportHTTP, RemoteAddr: r.RemoteAddr, Request: r})
+
+	w.Header().Set("Content-Type", "application/json")
+	if res := p.handleMessage(r.Context(), policy, p.httpLimiter(r.RemoteAddr, policy), body); res != nil {
+		w.Write(res)
+	}
+}
+
+// httpLimiter is shared by the POSTs of a remote host, nil if its policy is unlimited
+func (p *Proxy) httpLimiter(remoteAddr string, policy *Policy) *rate.Limiter {
+	if policy.Rate <= 0 {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var now = time.Now()
+	l, ok := p.httpLimiters[host]
+	if !ok || l.limiter.Limit() != policy.Rate {
+		for h, l := range p.httpLimiters {
+			if now.Sub(l.lastUsed) > httpLimiterIdle {
+				delete(p.httpLimiters, h)
+			}
+		}
+		l = &httpLimiter{limiter: policy.limiter()}
+		p.httpLimiters[host] = l
+	}
+	l.lastUsed = now
+	return l.limiter
+}
+
+func (p *Proxy) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: p.opts.OriginPatterns})
+	if err != nil {
+		return
+	}
+	ws.SetReadLimit(maxMessageSize)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var c = p.newConn(&Downstream{Transport: snapclient.TransportWebSocket, RemoteAddr: r.RemoteAddr, Request: r}, func() {
+		cancel()
+		ws.CloseNow()
+	})
+	defer p.removeConn(c)
+	go c.writeLoop(func(msg []byte) error {
+		return ws.Write(ctx, websocket.MessageText, msg)
+	})
+
+	for {
+		_, raw, err := ws.Read(ctx)
+		if err != nil {
+			return
+		}
+		if res := p.handleMessage(ctx, c.policy, c.limiter, raw); res != nil {
+			c.send(res)
+		}
+	}
+}
+
+// ServeTCP accepts newline delimited JSON connections until l is closed
+func (p *Proxy) ServeTCP(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.serveTCP(nc)
+	}
+}
+
+func (p *Proxy) serveTCP(nc net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var c = p.newConn(&Downstream{Transport: snapclient.TransportTCP, RemoteAddr: nc.RemoteAddr().String()}, func() {
+		cancel()
+		nc.Close()
+	})
+	defer p.removeConn(c)
+	go c.writeLoop(func(msg []byte) error {
+		_, err := nc.Write(append(msg, '\r', '\n'))
+		return err
+	})
+
+	var scanner = bufio.NewScanner(nc)
+	scanner.Buffer(nil, maxMessageSize)
+	for scanner.Scan() {
+		var line = bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if res := p.handleMessage(ctx, c.policy, c.limiter, line); res != nil {
+			c.send(res)
+		}
+	}
+}
+
+type request struct {
+	ID      json.RawMessage        `json:"id,omitempty"`
+	JsonRPC string                 `json:"jsonrpc,omitempty"`
+	Method  snapcast.RequestMethod `json:"method"`
+	Params  json.RawMessage        `json:"params,omitempty"`
+}
+
+// response carries the downstream's ID, which may be any JSON value
+type response struct {
+	ID      json.RawMessage `json:"id"`
+	JsonRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *snapcast.Error `json:"error,omitempty"`
+}
+
+// handleMessage forwards a single request or a batch, nil if there is nothing to answer.
+// Requests of a batch are forwarded one after the other, like snapserver runs them
+func (p *Proxy) handleMessage(ctx context.Context, policy *Policy, limiter *rate.Limiter, raw []byte) []byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+			return marshal(errorResponse(nil, snapcast.CodeParseError, "Parse error"))
+		}
+
+		var responses []*response
+		for _, item := range batch {
+			if res := p.handleRequest(ctx, policy, limiter, item); res != nil {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return marshal(responses)
+	}
+
+	if res := p.handleRequest(ctx, policy, limiter, raw); res != nil {
+		return marshal(res)
+	}
+	return nil
+}
+
+func (p *Proxy) handleRequest(ctx context.Context, policy *Policy, limiter *rate.Limiter, raw []byte) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, snapcast.CodeParseError, "Parse error")
+	}
+	if req.Method == "" {
+		return errorResponse(req.ID, snapcast.CodeInvalidRequest, "Invalid request")
+	}
+	// Requests without an ID are notifications and get no response, not even an error
+	var answer = func(res *response) *response {
+		if req.ID == nil {
+			return nil
+		}
+		return res
+	}
+
+	if !policy.allows(req.Method) {
+		return answer(errorResponse(req.ID, CodeMethodNotAllowed, "Method not allowed"))
+	}
+	if limiter != nil && !limiter.Allow() {
+		return answer(errorResponse(req.ID, CodeRateLimited, "Rate limit exceeded"))
+	}
+
+	var params interface{}
+	if len(req.Params) > 0 {
+		params = req.Params
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.opts.RequestTimeout)
+	defer cancel()
+	res, err := p.client.Send(ctx, req.Method, params)
+	if err != nil {
+		return answer(errorResponse(req.ID, CodeUpstream, "Upstream: "+err.Error()))
+	}
+	return answer(&response{ID: req.ID, JsonRPC: "2.0", Result: res.Result, Error: res.Error})
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	return &response{
+		ID:      id,
+		JsonRPC: "2.0",
+		Error:   &snapcast.Error{Code: code, Message: message},
+	}
+}
+
+func marshal(v interface{}) []byte {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic("snapproxy: " + err.Error())
+	}
+	return raw
+}
@@ -0,0 +1,229 @@
+package snapproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/coder/websocket"
+	"golang.org/x/time/rate"
+)
+
+const (
+	livingRoom = "00:21:6a:7d:74:fc"
+	kitchen    = "b8:27:eb:5f:1e:77"
+	bedroom    = "dc:a6:32:0b:44:12"
+)
+
+type testProxy struct {
+	upstream *snapcasttest.Server
+	http     *httptest.Server
+	tcp      net.Listener
+}
+
+func (p *testProxy) Host() string { return strings.TrimPrefix(p.http.URL, "http://") }
+
+// newTestProxy treats TCP downstreams as guests that may not delete clients and send two
+// requests a second
+func newTestProxy(t *testing.T) *testProxy {
+	var (
+		upstream = snapcasttest.NewServer(nil)
+		client   = snapclient.New(&snapclient.Options{Host: upstream.Host(), RateLimiter: rate.NewLimiter(rate.Inf, 0)})
+		proxy    = New(client, &Options{
+			Policy: func(d *Downstream) *Policy {
+				if d.Transport != snapclient.TransportTCP {
+					return &Policy{}
+				}
+				return &Policy{Deny: []snapcast.RequestMethod{snapcast.MethodServerDeleteClient}, Rate: 2, Burst: 2}
+			},
+			OriginPatterns: []string{"snapweb.local"},
+		})
+		mux  = http.NewServeMux()
+		done = make(chan error, 1)
+	)
+	mux.Handle("/jsonrpc", proxy)
+	var p = &testProxy{upstream: upstream, http: httptest.NewServer(mux)}
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.tcp = tcp
+	go proxy.ServeTCP(tcp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- proxy.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		proxy.Close()
+		tcp.Close()
+		p.http.Close()
+		client.Close()
+		upstream.Close()
+	})
+	return p
+}
+
+func downstream(t *testing.T, host string, transport snapclient.Transport) *snapclient.Client {
+	var c = snapclient.New(&snapclient.Options{Host: host, Transport: transport, RateLimiter: rate.NewLimiter(rate.Inf, 0)})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestRawIDs(t *testing.T) {
+	var p = newTestProxy(t)
+
+	res, err := http.Post(p.http.URL+"/jsonrpc", "application/json", strings.NewReader(
+		`[{"id": "a", "jsonrpc": "2.0", "method": "Client.GetStatus", "params": {"id": "`+kitchen+`"}},
+		  {"jsonrpc": "2.0", "method": "Server.GetRPCVersion"},
+		  {"id": 7, "jsonrpc": "2.0", "method": "Client.GetStatus", "params": {"id": "nope"}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var batch []struct {
+		ID     json.RawMessage `json:"id"`
+		Result *struct {
+			Client snapcast.Client `json:"client"`
+		} `json:"result"`
+		Error *snapcast.Error `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 {
+		t.Fatalf("%d responses, the notification shouldn't be answered", len(batch))
+	}
+	if string(batch[0].ID) != `"a"` || batch[0].Result == nil || batch[0].Result.Client.ID != kitchen {
+		t.Errorf("first response %s %+v", batch[0].ID, batch[0].Result)
+	}
+	if string(batch[1].ID) != "7" || batch[1].Error == nil || batch[1].Error.Code != snapcast.CodeInternalError {
+		t.Errorf("second response %s %+v", batch[1].ID, batch[1].Error)
+	}
+}
+
+func TestMultiplex(t *testing.T) {
+	var (
+		p       = newTestProxy(t)
+		ctx     = context.Background()
+		ws      = downstream(t, p.Host(), snapclient.TransportWebSocket)
+		other   = downstream(t, p.Host(), snapclient.TransportWebSocket)
+		changes = make(chan *snapcast.ClientOnVolumeChanged, 16)
+	)
+	if _, err := ws.Listen(ctx, &snapclient.Notifications{ClientOnVolumeChanged: changes}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both downstreams number their requests from the same start, the answers must not mix
+	var wg sync.WaitGroup
+	for _, c := range []*snapclient.Client{ws, other} {
+		for _, id := range []string{livingRoom, kitchen, bedroom} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 5; i++ {
+					res, err := c.ClientGetStatus(ctx, id)
+					if err != nil || res.Client.ID != id {
+						t.Errorf("ClientGetStatus(%s) = %+v, %v", id, res, err)
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	// A change made through the proxy reaches the other downstreams
+	if _, err := other.ClientSetVolume(ctx, kitchen, snapcast.Volume{Percent: 12}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-changes:
+		if p.ID != kitchen || p.Volume.Percent != 12 {
+			t.Errorf("notification %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+}
+
+func TestGuestPolicy(t *testing.T) {
+	var (
+		p     = newTestProxy(t)
+		ctx   = context.Background()
+		guest = downstream(t, p.tcp.Addr().String(), snapclient.TransportTCP)
+	)
+
+	if _, err := guest.ServerDeleteClient(ctx, bedroom); !errors.Is(err, ErrMethodNotAllowed) {
+		t.Errorf("delete client: %v", err)
+	}
+	if _, err := guest.ClientSetVolume(ctx, kitchen, snapcast.Volume{Percent: 5}); err != nil {
+		t.Errorf("set volume: %v", err)
+	}
+	// Denied requests don't count towards the limit
+	if _, err := guest.ClientGetStatus(ctx, kitchen); err != nil {
+		t.Errorf("get status: %v", err)
+	}
+	if _, err := guest.ClientGetStatus(ctx, kitchen); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("third request: %v", err)
+	}
+
+	for _, r := range p.upstream.Requests() {
+		if *r.Method == snapcast.MethodServerDeleteClient {
+			t.Error("denied request reached snapserver")
+		}
+	}
+}
+
+func TestMessageSize(t *testing.T) {
+	var p = newTestProxy(t)
+
+	var body = `{"id": 1, "jsonrpc": "2.0", "method": "Server.GetRPCVersion", "params": "` + strings.Repeat("x", maxMessageSize) + `"}`
+	res, err := http.Post(p.http.URL+"/jsonrpc", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d", res.StatusCode)
+	}
+	if n := len(p.upstream.Requests()); n != 0 {
+		t.Errorf("%d requests reached snapserver", n)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	var (
+		p   = newTestProxy(t)
+		ctx = context.Background()
+	)
+	for origin, ok := range map[string]bool{
+		"":                     true,
+		"http://" + p.Host():   true,
+		"http://snapweb.local": true,
+		"http://evil.example":  false,
+	} {
+		var header = http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		ws, _, err := websocket.Dial(ctx, "ws://"+p.Host()+"/jsonrpc", &websocket.DialOptions{HTTPHeader: header})
+		if (err == nil) != ok {
+			t.Errorf("origin %q: %v", origin, err)
+		}
+		if err == nil {
+			ws.CloseNow()
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package snaprecord records what snapserver sends to JSONL files and replays recordings, so
+// sync glitches seen in a deployment become reproducible tests.
+//
+// Record frames by passing Recorder.Record as snapclient.Options.OnFrame and listening through
+// Recorder.Listen:
+//
+//	var rec = snaprecord.NewRecorder(file)
+//	var client = snapclient.New(&snapclient.Options{Host: host, OnFrame: rec.Record})
+//	closed, err := rec.Listen(ctx, client, n)
+//
+// Replay them into a snapclient.Notifications with Replay, or into the sessions of a mock
+// server with ReplayToServer
+package snaprecord
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// Lifecycle events recorded by Recorder.Listen
+const (
+	EventConnected    = "connected"
+	EventDisconnected = "disconnected"
+)
+
+// Entry is a line of a recording, either a frame or a lifecycle event
+type Entry struct {
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Frame      json.RawMessage `json:"frame,omitempty"`
+	// Frames that aren't JSON are kept as text
+	Text  string `json:"text,omitempty"`
+	Event string `json:"event,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder writes entries as JSON lines, it's safe for concurrent use
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes a frame, pass it as snapclient.Options.OnFrame
+func (r *Recorder) Record(raw []byte, receivedAt time.Time) {
+	var e = Entry{ReceivedAt: receivedAt}
+	if json.Valid(raw) {
+		e.Frame = json.RawMessage(raw)
+	} else {
+		e.Text = string(raw)
+	}
+	r.write(&e)
+}
+
+func (r *Recorder) write(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(e)
+}
+
+// Err is the first error writing the recording, nothing is written after it
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Listen calls client.Listen and also records when the connection drops and comes back, which
+// needs snapclient.Options.Reconnect. The Connected and Disconnected channels of n are still sent
+// to when set
+func (r *Recorder) Listen(ctx context.Context, client *snapclient.Client, n *snapclient.Notifications) (chan error, error) {
+	if n == nil {
+		n = &snapclient.Notifications{}
+	}
+	var (
+		wrapped      = *n
+		connected    = n.Connected
+		disconnected = n.Disconnected
+	)
+	wrapped.Connected = make(chan struct{})
+	wrapped.Disconnected = make(chan error)
+
+	closed, err := client.Listen(ctx, &wrapped)
+	if err != nil {
+		return closed, err
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wrapped.Connected:
+				r.write(&Entry{ReceivedAt: time.Now(), Event: EventConnected})
+				if connected != nil {
+					select {
+					case connected <- struct{}{}:
+					case <-ctx.Done():
+					}
+				}
+			case err := <-wrapped.Disconnected:
+				r.write(&Entry{ReceivedAt: time.Now(), Event: EventDisconnected, Error: errorString(err)})
+				if disconnected != nil {
+					select {
+					case disconnected <- err:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}
+	}()
+	return closed, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Read parses a recording
+func Read(r io.Reader) ([]Entry, error) {
+	var (
+		entries []Entry
+		scanner = bufio.NewScanner(r)
+		line    int
+	)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
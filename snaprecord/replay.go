@@ -0,0 +1,145 @@
+package snaprecord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+type ReplayOptions struct {
+	// Multiplies the pace of the recording, 10 replays ten times faster and math.Inf(1) doesn't
+	// wait at all. Defaults to 1, the original gaps between entries
+	Speed float64
+	// If set, every entry waits for a receive instead, e.g. to check the state after each one
+	Step <-chan struct{}
+}
+
+// Notifications of an entry's frame, a frame may be a batch. Responses are skipped
+func (e *Entry) Notifications() ([]*snapcast.Notification, error) {
+	var raw = bytes.TrimSpace(e.Frame)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var items []json.RawMessage
+	if raw[0] != '[' {
+		items = append(items, raw)
+	} else if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+
+	var msgs []*snapcast.Notification
+	for _, item := range items {
+		var msg = &snapcast.Notification{}
+		if err := json.Unmarshal(item, msg); err != nil {
+			return nil, err
+		}
+		if msg.Method == nil {
+			continue
+		}
+		msg.ReceivedAt = e.ReceivedAt
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// play waits for each entry according to opts and calls fn with it
+func play(ctx context.Context, entries []Entry, opts *ReplayOptions, fn func(e *Entry) error) error {
+	var o ReplayOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Speed <= 0 {
+		o.Speed = 1
+	}
+
+	var timer = time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for i := range entries {
+		var e = &entries[i]
+		switch {
+		case o.Step != nil:
+			select {
+			case <-o.Step:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case i > 0:
+			var gap = time.Duration(float64(e.ReceivedAt.Sub(entries[i-1].ReceivedAt)) / o.Speed)
+			if gap > 0 {
+				timer.Reset(gap)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay sends the recorded notifications to the channels of n like Listen does, and the
+// lifecycle events to Connected and Disconnected. Read the channels, sends block
+func Replay(ctx context.Context, entries []Entry, n *snapclient.Notifications, opts *ReplayOptions) error {
+	return play(ctx, entries, opts, func(e *Entry) error {
+		switch e.Event {
+		case EventConnected:
+			if n.Connected != nil {
+				select {
+				case n.Connected <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		case EventDisconnected:
+			if n.Disconnected != nil {
+				select {
+				case n.Disconnected <- errors.New(e.Error):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+
+		msgs, err := e.Notifications()
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			n.Handle(msg)
+		}
+		return nil
+	})
+}
+
+// ReplayToServer sends the recorded notifications to every session of srv, the clients under
+// test receive them like from snapserver. Lifecycle events are skipped and the state of srv
+// isn't changed
+func ReplayToServer(ctx context.Context, entries []Entry, srv *snapcasttest.Server, opts *ReplayOptions) error {
+	return play(ctx, entries, opts, func(e *Entry) error {
+		msgs, err := e.Notifications()
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			srv.Notify(*msg.Method, msg.Params)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,216 @@
+package snaprecord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+const firstGroup = "4dcc4e3b-c699-a04b-7f0c-8260d23c43e1"
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func frame(t *testing.T, at time.Time, method snapcast.NotificationMethod, params interface{}) Entry {
+	raw, err := json.Marshal(&snapcast.Notification{JsonRPC: "2.0", Method: &method, Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Entry{ReceivedAt: at, Frame: raw}
+}
+
+func TestRecord(t *testing.T) {
+	var (
+		srv    = snapcasttest.NewServer(nil)
+		ctx    = testContext(t)
+		buf    = new(bytes.Buffer)
+		rec    = NewRecorder(buf)
+		client = snapclient.New(&snapclient.Options{
+			Host:      srv.Host(),
+			Transport: snapclient.TransportWebSocket,
+			Reconnect: &snapclient.ReconnectPolicy{InitialDelay: 10 * time.Millisecond},
+			OnFrame:   rec.Record,
+		})
+		n = &snapclient.Notifications{
+			Connected:   make(chan struct{}),
+			GroupOnMute: make(chan *snapcast.GroupOnMute),
+		}
+	)
+	defer srv.Close()
+	defer client.Close()
+
+	if _, err := rec.Listen(ctx, client, n); err != nil {
+		t.Fatal(err)
+	}
+	srv.Notify(snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: firstGroup, Mute: true})
+	<-n.GroupOnMute
+
+	// The lifecycle is recorded and still reaches n
+	srv.CloseConnections()
+	select {
+	case <-n.Connected:
+	case <-ctx.Done():
+		t.Fatal("never reconnected")
+	}
+	srv.Notify(snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: firstGroup, Mute: false})
+	<-n.GroupOnMute
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range entries {
+		switch {
+		case e.Event != "":
+			kinds = append(kinds, e.Event)
+		case strings.Contains(string(e.Frame), "Group.OnMute"):
+			kinds = append(kinds, "mute")
+		}
+		if e.ReceivedAt.IsZero() {
+			t.Errorf("entry without receivedAt %+v", e)
+		}
+	}
+	if got := strings.Join(kinds, ","); got != "mute,disconnected,connected,mute" {
+		t.Errorf("recorded %s", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		start   = time.Now()
+		entries = []Entry{
+			frame(t, start, snapcast.MethodClientOnVolumeChanged, &snapcast.ClientOnVolumeChanged{ID: "c", Volume: snapcast.Volume{Percent: 1}}),
+			{ReceivedAt: start.Add(50 * time.Millisecond), Event: EventDisconnected, Error: "EOF"},
+			{ReceivedAt: start.Add(100 * time.Millisecond), Event: EventConnected},
+			// A batch with a response, which is skipped
+			{ReceivedAt: start.Add(200 * time.Millisecond), Frame: json.RawMessage(`[
+				{"jsonrpc": "2.0", "id": 1, "result": {}},
+				{"jsonrpc": "2.0", "method": "Client.OnVolumeChanged", "params": {"id": "c", "volume": {"percent": 2}}}]`)},
+		}
+	)
+
+	for _, speed := range []float64{1, 10, math.Inf(1)} {
+		var (
+			n = &snapclient.Notifications{
+				Connected:             make(chan struct{}, 1),
+				Disconnected:          make(chan error, 1),
+				ClientOnVolumeChanged: make(chan *snapcast.ClientOnVolumeChanged, 2),
+			}
+			began = time.Now()
+		)
+		if err := Replay(testContext(t), entries, n, &ReplayOptions{Speed: speed}); err != nil {
+			t.Fatal(err)
+		}
+		var took = time.Since(began)
+		if want := time.Duration(float64(200*time.Millisecond) / speed); took < want || took > want+150*time.Millisecond {
+			t.Errorf("speed %v took %s, want %s", speed, took, want)
+		}
+
+		if err := <-n.Disconnected; err.Error() != "EOF" {
+			t.Errorf("disconnected with %v", err)
+		}
+		<-n.Connected
+		for _, want := range []int{1, 2} {
+			if p := <-n.ClientOnVolumeChanged; p.Volume.Percent != want {
+				t.Errorf("volume %d, want %d", p.Volume.Percent, want)
+			}
+		}
+	}
+}
+
+// Nobody reads Disconnected, Replay still returns once ctx is done
+func TestReplayCancel(t *testing.T) {
+	var (
+		entries = []Entry{{ReceivedAt: time.Now(), Event: EventDisconnected, Error: "EOF"}}
+		n       = &snapclient.Notifications{Disconnected: make(chan error)}
+		done    = make(chan error, 1)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- Replay(ctx, entries, n, nil) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Replay returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Replay blocked on Disconnected")
+	}
+}
+
+func TestStep(t *testing.T) {
+	var (
+		start   = time.Now()
+		entries = []Entry{
+			frame(t, start, snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: "g", Mute: true}),
+			frame(t, start.Add(time.Hour), snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: "g", Mute: false}),
+		}
+		step = make(chan struct{})
+		n    = &snapclient.Notifications{GroupOnMute: make(chan *snapcast.GroupOnMute, 2)}
+		done = make(chan error, 1)
+	)
+	go func() { done <- Replay(testContext(t), entries, n, &ReplayOptions{Step: step}) }()
+
+	for _, want := range []bool{true, false} {
+		select {
+		case <-n.GroupOnMute:
+			t.Fatal("replayed without a step")
+		case <-time.After(20 * time.Millisecond):
+		}
+		step <- struct{}{}
+		if p := <-n.GroupOnMute; p.Mute != want {
+			t.Errorf("mute %v, want %v", p.Mute, want)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayToServer(t *testing.T) {
+	var (
+		srv    = snapcasttest.NewServer(nil)
+		ctx    = testContext(t)
+		client = snapclient.New(&snapclient.Options{Host: srv.Host()})
+		n      = &snapclient.Notifications{GroupOnNameChanged: make(chan *snapcast.GroupOnNameChanged, 1)}
+	)
+	defer srv.Close()
+	defer client.Close()
+	if _, err := client.Listen(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries = []Entry{
+		{ReceivedAt: time.Now(), Event: EventConnected},
+		frame(t, time.Now(), snapcast.MethodGroupOnNameChanged, &snapcast.GroupOnNameChanged{ID: firstGroup, Name: "Replayed"}),
+	}
+	if err := ReplayToServer(ctx, entries, srv, &ReplayOptions{Speed: math.Inf(1)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-n.GroupOnNameChanged:
+		if p.ID != firstGroup || p.Name != "Replayed" {
+			t.Errorf("notification %+v", p)
+		}
+	case <-ctx.Done():
+		t.Fatal("no notification")
+	}
+}
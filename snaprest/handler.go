@@ -0,0 +1,404 @@
+// Package snaprest serves the snapserver control API as resource style JSON routes, for
+// frontends that can't speak JSON-RPC. Every route maps to snapclient calls and the OpenAPI 3
+// document describing them is built from the snapcast types, see Handler.OpenAPI
+//
+//	http.Handle("/api/", http.StripPrefix("/api", snaprest.New(client)))
+package snaprest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// Largest request body accepted
+const maxBodySize = 1 << 20
+
+type (
+	// ClientPatch changes the fields that are set
+	ClientPatch struct {
+		Volume  *int    `json:"volume,omitempty"`
+		Muted   *bool   `json:"muted,omitempty"`
+		Name    *string `json:"name,omitempty"`
+		Latency *int    `json:"latency,omitempty"`
+	}
+
+	// GroupPatch changes the fields that are set
+	GroupPatch struct {
+		Name  *string `json:"name,omitempty"`
+		Muted *bool   `json:"muted,omitempty"`
+	}
+
+	GroupStream struct {
+		StreamID string `json:"stream_id"`
+	}
+
+	GroupClients struct {
+		Clients []string `json:"clients"`
+	}
+
+	StreamControl struct {
+		Command snapcast.StreamCommand `json:"command"`
+		Params  interface{}            `json:"params,omitempty"`
+	}
+
+	StreamAdd struct {
+		StreamURI string `json:"streamUri"`
+	}
+
+	// Error is the body of every response that isn't 2xx. Code is the JSON-RPC error code
+	// when snapserver rejected the request
+	Error struct {
+		Error string `json:"error"`
+		Code  int    `json:"code,omitempty"`
+	}
+)
+
+// none is the body of routes that take no body and the result of routes answering 204
+type none struct{}
+
+// route is both a mux pattern and an OpenAPI operation
+type route struct {
+	method, path string
+	summary      string
+	status       int
+	body         reflect.Type
+	result       reflect.Type
+	serve        func(w http.ResponseWriter, r *http.Request)
+}
+
+// newRoute decodes a B, calls fn and encodes its R. B and R are none when there's nothing to decode
+// or encode
+func newRoute[B, R any](method, path, summary string, fn func(ctx context.Context, r *http.Request, body *B) (*R, error)) route {
+	var rt = route{
+		method:  method,
+		path:    path,
+		summary: summary,
+		status:  http.StatusOK,
+		body:    reflect.TypeFor[B](),
+		result:  reflect.TypeFor[R](),
+	}
+	if rt.result == reflect.TypeFor[none]() {
+		rt.status = http.StatusNoContent
+	}
+	rt.serve = func(w http.ResponseWriter, r *http.Request) {
+		var body = new(B)
+		if rt.body != reflect.TypeFor[none]() {
+			var dec = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(body); err != nil {
+				writeJSON(w, http.StatusBadRequest, &Error{Error: "invalid body: " + err.Error()})
+				return
+			}
+		}
+		res, err := fn(r.Context(), r, body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if rt.status == http.StatusNoContent {
+			w.WriteHeader(rt.status)
+			return
+		}
+		writeJSON(w, rt.status, res)
+	}
+	return rt
+}
+
+type Handler struct {
+	client *snapclient.Client
+	routes []route
+	mux    *http.ServeMux
+}
+
+// New serves the routes below, and the OpenAPI document on GET /openapi.json. A PATCH makes a call
+// per field plus one to read the result back, all of them go through the client's rate limiter
+//
+//	GET    /server
+//	GET    /clients
+//	GET    /clients/{id}
+//	PATCH  /clients/{id}             volume, muted, name and latency
+//	DELETE /clients/{id}
+//	GET    /groups
+//	GET    /groups/{id}
+//	PATCH  /groups/{id}              name and muted
+//	PUT    /groups/{id}/stream
+//	PUT    /groups/{id}/clients
+//	GET    /streams
+//	POST   /streams
+//	GET    /streams/{id}
+//	DELETE /streams/{id}
+//	POST   /streams/{id}/control
+func New(client *snapclient.Client) *Handler {
+	var h = &Handler{client: client, mux: http.NewServeMux()}
+	h.routes = []route{
+		newRoute("GET", "/server", "Status of the server with all groups, clients and streams", h.getServer),
+		newRoute("GET", "/clients", "All clients", h.getClients),
+		newRoute("GET", "/clients/{id}", "A client", h.getClient),
+		newRoute("PATCH", "/clients/{id}", "Change the volume, mute, name or latency of a client", h.patchClient),
+		newRoute("DELETE", "/clients/{id}", "Remove a disconnected client", h.deleteClient),
+		newRoute("GET", "/groups", "All groups", h.getGroups),
+		newRoute("GET", "/groups/{id}", "A group", h.getGroup),
+		newRoute("PATCH", "/groups/{id}", "Change the name or mute of a group", h.patchGroup),
+		newRoute("PUT", "/groups/{id}/stream", "Set the stream a group plays", h.putGroupStream),
+		newRoute("PUT", "/groups/{id}/clients", "Set the clients of a group", h.putGroupClients),
+		newRoute("GET", "/streams", "All streams", h.getStreams),
+		newRoute("POST", "/streams", "Add a stream from a stream URI", h.postStream),
+		newRoute("GET", "/streams/{id}", "A stream", h.getStream),
+		newRoute("DELETE", "/streams/{id}", "Remove a stream", h.deleteStream),
+		newRoute("POST", "/streams/{id}/control", "Send a command to a stream", h.postStreamControl),
+	}
+	for _, rt := range h.routes {
+		h.mux.HandleFunc(rt.method+" "+rt.path, rt.serve)
+	}
+
+	var spec = h.OpenAPI()
+	h.mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spec)
+	})
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// --- Server
+
+func (h *Handler) getServer(ctx context.Context, _ *http.Request, _ *none) (*snapcast.Server, error) {
+	res, err := h.client.ServerGetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &res.Server, nil
+}
+
+// --- Clients
+
+func (h *Handler) getClients(ctx context.Context, r *http.Request, _ *none) (*[]snapcast.Client, error) {
+	server, err := h.getServer(ctx, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	var clients = []snapcast.Client{}
+	for _, g := range server.Groups {
+		clients = append(clients, g.Clients...)
+	}
+	return &clients, nil
+}
+
+func (h *Handler) getClient(ctx context.Context, r *http.Request, _ *none) (*snapcast.Client, error) {
+	res, err := h.client.ClientGetStatus(ctx, r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	return &res.Client, nil
+}
+
+func (h *Handler) patchClient(ctx context.Context, r *http.Request, p *ClientPatch) (*snapcast.Client, error) {
+	var id = r.PathValue("id")
+	if p.Volume != nil && (*p.Volume < 0 || *p.Volume > 100) {
+		return nil, badRequest("volume %d is not between 0 and 100", *p.Volume)
+	}
+	if p.Latency != nil && *p.Latency < 0 {
+		return nil, badRequest("latency %d is negative", *p.Latency)
+	}
+
+	if p.Volume != nil || p.Muted != nil {
+		// Client.SetVolume takes both, keep the one that isn't patched
+		c, err := h.getClient(ctx, r, nil)
+		if err != nil {
+			return nil, err
+		}
+		var volume = c.Config.Volume
+		if p.Volume != nil {
+			volume.Percent = *p.Volume
+		}
+		if p.Muted != nil {
+			volume.Muted = *p.Muted
+		}
+		if _, err := h.client.ClientSetVolume(ctx, id, volume); err != nil {
+			return nil, err
+		}
+	}
+	if p.Name != nil {
+		if _, err := h.client.ClientSetName(ctx, id, *p.Name); err != nil {
+			return nil, err
+		}
+	}
+	if p.Latency != nil {
+		if _, err := h.client.ClientSetLatency(ctx, id, *p.Latency); err != nil {
+			return nil, err
+		}
+	}
+	return h.getClient(ctx, r, nil)
+}
+
+func (h *Handler) deleteClient(ctx context.Context, r *http.Request, _ *none) (*none, error) {
+	_, err := h.client.ServerDeleteClient(ctx, r.PathValue("id"))
+	return nil, err
+}
+
+// --- Groups
+
+func (h *Handler) getGroups(ctx context.Context, r *http.Request, _ *none) (*[]snapcast.Group, error) {
+	server, err := h.getServer(ctx, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	var groups = server.Groups
+	if groups == nil {
+		groups = []snapcast.Group{}
+	}
+	return &groups, nil
+}
+
+func (h *Handler) getGroup(ctx context.Context, r *http.Request, _ *none) (*snapcast.Group, error) {
+	res, err := h.client.GroupGetStatus(ctx, r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	return &res.Group, nil
+}
+
+func (h *Handler) patchGroup(ctx context.Context, r *http.Request, p *GroupPatch) (*snapcast.Group, error) {
+	var id = r.PathValue("id")
+	if p.Name != nil {
+		if _, err := h.client.GroupSetName(ctx, id, *p.Name); err != nil {
+			return nil, err
+		}
+	}
+	if p.Muted != nil {
+		if _, err := h.client.GroupSetMute(ctx, id, *p.Muted); err != nil {
+			return nil, err
+		}
+	}
+	return h.getGroup(ctx, r, nil)
+}
+
+func (h *Handler) putGroupStream(ctx context.Context, r *http.Request, p *GroupStream) (*snapcast.GroupSetStreamResponse, error) {
+	if p.StreamID == "" {
+		return nil, badRequest("stream_id is empty")
+	}
+	return h.client.GroupSetStream(ctx, r.PathValue("id"), p.StreamID)
+}
+
+func (h *Handler) putGroupClients(ctx context.Context, r *http.Request, p *GroupClients) (*snapcast.Server, error) {
+	if _, err := h.client.GroupSetClients(ctx, r.PathValue("id"), p.Clients); err != nil {
+		return nil, err
+	}
+	// Groups may have been created or removed
+	return h.getServer(ctx, r, nil)
+}
+
+// --- Streams
+
+func (h *Handler) getStreams(ctx context.Context, r *http.Request, _ *none) (*[]snapcast.Stream, error) {
+	server, err := h.getServer(ctx, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	var streams = server.Streams
+	if streams == nil {
+		streams = []snapcast.Stream{}
+	}
+	return &streams, nil
+}
+
+func (h *Handler) getStream(ctx context.Context, r *http.Request, _ *none) (*snapcast.Stream, error) {
+	streams, err := h.getStreams(ctx, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	var id = r.PathValue("id")
+	for _, st := range *streams {
+		if st.ID == id {
+			return &st, nil
+		}
+	}
+	return nil, &snapcast.RPCError{Code: snapcast.CodeInternalError, Message: snapcast.ErrStreamNotFound.Message}
+}
+
+func (h *Handler) postStream(ctx context.Context, _ *http.Request, p *StreamAdd) (*snapcast.StreamAddStreamResponse, error) {
+	if p.StreamURI == "" {
+		return nil, badRequest("streamUri is empty")
+	}
+	uri, err := snapcast.ParseStreamURI(p.StreamURI)
+	if err == nil {
+		err = uri.Validate()
+	}
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	return h.client.StreamAddStreamURI(ctx, uri)
+}
+
+func (h *Handler) deleteStream(ctx context.Context, r *http.Request, _ *none) (*none, error) {
+	_, err := h.client.StreamRemoveStream(ctx, r.PathValue("id"))
+	return nil, err
+}
+
+func (h *Handler) postStreamControl(ctx context.Context, r *http.Request, p *StreamControl) (*snapcast.StreamControlResponse, error) {
+	if p.Command == "" {
+		return nil, badRequest("command is empty")
+	}
+	return h.client.StreamControl(ctx, r.PathValue("id"), p.Command, p.Params)
+}
+
+// --- Responses
+
+// requestError is a problem with the request found before calling snapserver
+type requestError string
+
+func (e requestError) Error() string { return string(e) }
+
+func badRequest(format string, args ...interface{}) error {
+	return requestError(fmt.Sprintf(format, args...))
+}
+
+// status of an error: bad params are the caller's fault, unknown ids are 404, streams refusing a
+// command are 409 and everything else is snapserver's or the connection's problem
+func status(err error) int {
+	var (
+		reqErr requestError
+		rpcErr *snapcast.RPCError
+	)
+	switch {
+	case errors.As(err, &reqErr):
+		return http.StatusBadRequest
+	case errors.Is(err, snapcast.ErrClientNotFound),
+		errors.Is(err, snapcast.ErrGroupNotFound),
+		errors.Is(err, snapcast.ErrStreamNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, snapcast.ErrInvalidParams):
+		return http.StatusBadRequest
+	case errors.As(err, &rpcErr) && rpcErr.Code > 0:
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		body   = &Error{Error: err.Error()}
+		rpcErr *snapcast.RPCError
+	)
+	if errors.As(err, &rpcErr) {
+		body.Code = rpcErr.Code
+	}
+	writeJSON(w, status(err), body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
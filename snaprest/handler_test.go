@@ -0,0 +1,202 @@
+package snaprest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"golang.org/x/time/rate"
+)
+
+const (
+	livingRoom = "00:21:6a:7d:74:fc"
+	kitchen    = "b8:27:eb:5f:1e:77"
+	firstGroup = "4dcc4e3b-c699-a04b-7f0c-8260d23c43e1"
+)
+
+func newTestServer(t *testing.T) (*snapcasttest.Server, *httptest.Server) {
+	t.Helper()
+	var (
+		mock   = snapcasttest.NewServer(nil)
+		client = snapclient.New(&snapclient.Options{
+			Host: mock.Host(),
+			// Patches make several calls, don't wait on the default limit
+			RateLimiter: rate.NewLimiter(rate.Inf, 0),
+		})
+		srv = httptest.NewServer(New(client))
+	)
+	t.Cleanup(func() {
+		srv.Close()
+		client.Close()
+		mock.Close()
+	})
+	return mock, srv
+}
+
+// do sends body as JSON and decodes the response into out, returning the status
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestRoutes(t *testing.T) {
+	mock, srv := newTestServer(t)
+
+	var clients []snapcast.Client
+	if code := do(t, srv, "GET", "/clients", "", &clients); code != 200 || len(clients) != 3 {
+		t.Fatalf("GET /clients %d %d clients", code, len(clients))
+	}
+
+	var c snapcast.Client
+	if code := do(t, srv, "PATCH", "/clients/"+livingRoom, `{"volume": 20, "name": "Lounge", "latency": 15}`, &c); code != 200 {
+		t.Fatalf("PATCH client %d", code)
+	}
+	if c.Config.Volume.Percent != 20 || c.Config.Name != "Lounge" || c.Config.Latency != 15 || c.Config.Volume.Muted {
+		t.Errorf("patched client %+v", c.Config)
+	}
+	// Only muted, the volume stays
+	do(t, srv, "PATCH", "/clients/"+livingRoom, `{"muted": true}`, &c)
+	if v := c.Config.Volume; !v.Muted || v.Percent != 20 {
+		t.Errorf("volume after mute %+v", v)
+	}
+
+	var stream snapcast.GroupSetStreamResponse
+	if code := do(t, srv, "PUT", "/groups/"+firstGroup+"/stream", `{"stream_id": "default"}`, &stream); code != 200 || stream.StreamID != "default" {
+		t.Errorf("PUT stream %d %+v", code, stream)
+	}
+
+	var g snapcast.Group
+	if code := do(t, srv, "PATCH", "/groups/"+firstGroup, `{"name": "Downstairs", "muted": true}`, &g); code != 200 || g.Name != "Downstairs" || !g.Muted {
+		t.Errorf("PATCH group %d %+v", code, g)
+	}
+
+	var server snapcast.Server
+	if code := do(t, srv, "PUT", "/groups/"+firstGroup+"/clients", `{"clients": ["`+livingRoom+`"]}`, &server); code != 200 || len(server.Groups) != 3 {
+		t.Errorf("PUT clients %d %d groups", code, len(server.Groups))
+	}
+
+	if code := do(t, srv, "POST", "/streams/default/control", `{"command": "play"}`, nil); code != 200 {
+		t.Errorf("POST control %d", code)
+	}
+	var st snapcast.Stream
+	if code := do(t, srv, "GET", "/streams/default", "", &st); code != 200 || st.Properties.PlaybackStatus != snapcast.PlaybackPlaying {
+		t.Errorf("GET stream %d %+v", code, st.Properties)
+	}
+
+	var added snapcast.StreamAddStreamResponse
+	if code := do(t, srv, "POST", "/streams", `{"streamUri": "pipe:///tmp/announce?name=Announcements"}`, &added); code != 200 || added.StreamId != "Announcements" {
+		t.Errorf("POST stream %d %+v", code, added)
+	}
+
+	if code := do(t, srv, "DELETE", "/clients/"+kitchen, "", nil); code != 204 {
+		t.Errorf("DELETE client %d", code)
+	}
+	for _, g := range mock.State().Groups {
+		for _, c := range g.Clients {
+			if c.ID == kitchen {
+				t.Error("client not deleted")
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	mock, srv := newTestServer(t)
+	mock.Update(func(state *snapcast.Server) { state.Streams[0].Properties.CanSeek = false })
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"PATCH", "/clients/" + livingRoom, `{"volume": 101}`, 400},
+		{"PATCH", "/clients/" + livingRoom, `{"volume": "loud"}`, 400},
+		{"PATCH", "/clients/" + livingRoom, `{"colour": "red"}`, 400},
+		{"PUT", "/groups/" + firstGroup + "/stream", `{}`, 400},
+		{"POST", "/streams", `{"streamUri": "/tmp/announce"}`, 400},
+		{"POST", "/streams", `{"streamUri": "pipe:///tmp/announce"}`, 400},
+		{"GET", "/clients/nope", "", 404},
+		{"PATCH", "/clients/nope", `{"name": "x"}`, 404},
+		{"GET", "/streams/nope", "", 404},
+		{"PUT", "/groups/" + firstGroup + "/stream", `{"stream_id": "nope"}`, 404},
+		{"POST", "/streams/default/control", `{"command": "seek", "params": {"offset": 5}}`, 409},
+	} {
+		var body Error
+		if code := do(t, srv, tc.method, tc.path, tc.body, &body); code != tc.status {
+			t.Errorf("%s %s %s: %d %+v, want %d", tc.method, tc.path, tc.body, code, body, tc.status)
+		}
+	}
+	if code := do(t, srv, "DELETE", "/groups/"+firstGroup, "", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE group %d", code)
+	}
+
+	mock.Close()
+	if code := do(t, srv, "GET", "/server", "", nil); code != http.StatusBadGateway {
+		t.Errorf("server down: %d", code)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	_, srv := newTestServer(t)
+
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if code := do(t, srv, "GET", "/openapi.json", "", &doc); code != 200 || doc.OpenAPI != openAPIVersion {
+		t.Fatalf("GET /openapi.json %d %q", code, doc.OpenAPI)
+	}
+
+	var h = New(nil)
+	for _, rt := range h.routes {
+		op, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]
+		if !ok {
+			t.Errorf("%s %s missing", rt.method, rt.path)
+			continue
+		}
+		if _, ok := op["responses"].(map[string]interface{})[strconv.Itoa(rt.status)]; !ok {
+			t.Errorf("%s %s has no %d response", rt.method, rt.path, rt.status)
+		}
+	}
+	if op := doc.Paths["/clients/{id}"]["patch"]; op["operationId"] != "patchClientsByID" || op["parameters"] == nil || op["requestBody"] == nil {
+		t.Errorf("PATCH /clients/{id} %v", op)
+	}
+
+	// Schemas come from the snapcast types
+	var client = doc.Components.Schemas["Client"]
+	if _, ok := client.Properties["lastSeen"]; !ok {
+		t.Errorf("Client schema %+v", client)
+	}
+	var props = doc.Components.Schemas["Properties"]
+	if props.Required != nil || props.Properties["playbackStatus"] == nil {
+		t.Errorf("Properties schema %+v", props)
+	}
+	if _, ok := doc.Components.Schemas["ClientPatch"]; !ok {
+		t.Error("no ClientPatch schema")
+	}
+}
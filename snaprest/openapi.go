@@ -0,0 +1,207 @@
+package snaprest
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+const openAPIVersion = "3.0.3"
+
+// Values of the snapcast string types, listed as enums in the schemas
+var enums = map[reflect.Type][]string{
+	reflect.TypeFor[snapcast.StreamStatus]():   {string(snapcast.StreamIdle), string(snapcast.StreamPlaying)},
+	reflect.TypeFor[snapcast.PlaybackStatus](): {string(snapcast.PlaybackPlaying), string(snapcast.PlaybackPaused), string(snapcast.PlaybackStopped)},
+	reflect.TypeFor[snapcast.LoopStatus]():     {string(snapcast.LoopNone), string(snapcast.LoopTrack), string(snapcast.LoopPlaylist)},
+	reflect.TypeFor[snapcast.StreamCommand](): {
+		string(snapcast.StreamCommandPlay), string(snapcast.StreamCommandPause), string(snapcast.StreamCommandPlayPause),
+		string(snapcast.StreamCommandStop), string(snapcast.StreamCommandNext), string(snapcast.StreamCommandPrevious),
+		string(snapcast.StreamCommandSeek), string(snapcast.StreamCommandSetPosition),
+	},
+}
+
+// OpenAPI is the OpenAPI 3 document of the routes. Schemas are generated from the Go types the
+// routes decode and encode, named structs end up in components
+func (h *Handler) OpenAPI() map[string]interface{} {
+	var (
+		s     = &schemas{defs: make(map[string]interface{}), types: make(map[string]reflect.Type)}
+		paths = make(map[string]interface{})
+		errs  = s.of(reflect.TypeFor[Error]())
+	)
+	for _, rt := range h.routes {
+		var op = map[string]interface{}{
+			"operationId": operationID(rt.method, rt.path),
+			"summary":     rt.summary,
+		}
+		if params := pathParams(rt.path); len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.body != reflect.TypeFor[none]() {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(s.of(rt.body)),
+			}
+		}
+		var ok = map[string]interface{}{"description": http.StatusText(rt.status)}
+		if rt.result != reflect.TypeFor[none]() {
+			ok["content"] = jsonContent(s.of(rt.result))
+		}
+		op["responses"] = map[string]interface{}{
+			strconv.Itoa(rt.status): ok,
+			"default": map[string]interface{}{
+				"description": "Error, snapserver's JSON-RPC error code is in code",
+				"content":     jsonContent(errs),
+			},
+		}
+
+		var item, _ = paths[rt.path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "Snapcast",
+			"version": "1",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": s.defs},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// pathParams of a mux pattern, all of them strings
+func pathParams(path string) []interface{} {
+	var params []interface{}
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params = append(params, map[string]interface{}{
+				"name":     strings.Trim(seg, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return params
+}
+
+// operationID of PATCH /clients/{id} is patchClientsByID
+func operationID(method, path string) string {
+	var id = strings.ToLower(method)
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") {
+			seg = "by" + strings.ToUpper(strings.Trim(seg, "{}"))
+		}
+		var r = []rune(seg)
+		r[0] = unicode.ToUpper(r[0])
+		id += string(r)
+	}
+	return id
+}
+
+// schemas generates JSON schemas of Go types, collecting named structs in defs
+type schemas struct {
+	defs  map[string]interface{}
+	types map[string]reflect.Type
+}
+
+func (s *schemas) of(t reflect.Type) map[string]interface{} {
+	if values, ok := enums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.of(t.Elem())
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		var name = s.name(t)
+		if _, ok := s.defs[name]; !ok {
+			// Placeholder first so recursive types end
+			s.defs[name] = nil
+			s.defs[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	panic("snaprest: no schema for " + t.String())
+}
+
+// name of a struct in components, qualified by its package when two packages use the same name
+func (s *schemas) name(t reflect.Type) string {
+	var name = t.Name()
+	if other, ok := s.types[name]; ok && other != t {
+		var pkg = t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	s.types[name] = t
+	return name
+}
+
+// object of a struct's JSON fields. Fields are required unless omitempty
+func (s *schemas) object(t reflect.Type) map[string]interface{} {
+	var (
+		props    = make(map[string]interface{})
+		required []string
+	)
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		var (
+			tag       = f.Tag.Get("json")
+			name, opt = tag, ""
+		)
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opt = tag[:i], tag[i:]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.of(f.Type)
+		if !strings.Contains(opt, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	var obj = map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package snapsource
+
+import "errors"
+
+func mkfifo(path string) error {
+	return errors.New("snapsource: pipes need a unix system")
+}
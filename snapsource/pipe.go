@@ -0,0 +1,18 @@
+package snapsource
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// OpenPipe opens the FIFO of a pipe:// stream for writing, creating it if it doesn't exist.
+// The open blocks until snapserver has the FIFO open for reading
+func OpenPipe(path string) (*os.File, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := mkfifo(path); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
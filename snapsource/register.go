@@ -0,0 +1,46 @@
+package snapsource
+
+import (
+	"context"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// TCPMode of a tcp:// stream is snapserver's side of the connection
+type TCPMode string
+
+const (
+	// snapserver listens and the source dials with DialTCP
+	TCPModeServer TCPMode = "server"
+	// snapserver dials a source listening with ListenTCP
+	TCPModeClient TCPMode = "client"
+)
+
+// PipeURI of a pipe:// stream reading the FIFO at path
+func PipeURI(path, name string, format snapcast.SampleFormat) *snapcast.StreamURI {
+	return snapcast.NewPipeURI(path, name).WithSampleFormat(format)
+}
+
+// TCPURI of a tcp:// stream, addr is where snapserver listens or where it connects to depending on mode
+func TCPURI(addr, name string, mode TCPMode, format snapcast.SampleFormat) *snapcast.StreamURI {
+	return snapcast.NewTCPURI(addr, name).WithSampleFormat(format).Set("mode", string(mode))
+}
+
+// AddPipeStream registers a pipe:// stream with Stream.AddStream and returns its id
+func AddPipeStream(ctx context.Context, c *snapclient.Client, path, name string, format snapcast.SampleFormat) (string, error) {
+	return addStream(ctx, c, PipeURI(path, name, format))
+}
+
+// AddTCPStream registers a tcp:// stream with Stream.AddStream and returns its id
+func AddTCPStream(ctx context.Context, c *snapclient.Client, addr, name string, mode TCPMode, format snapcast.SampleFormat) (string, error) {
+	return addStream(ctx, c, TCPURI(addr, name, mode, format))
+}
+
+func addStream(ctx context.Context, c *snapclient.Client, uri *snapcast.StreamURI) (string, error) {
+	res, err := c.StreamAddStreamURI(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	return res.StreamId, nil
+}
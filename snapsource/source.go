@@ -0,0 +1,173 @@
+// Package snapsource feeds PCM to snapserver stream sources, a pipe:// FIFO or a tcp:// socket.
+// Writes are paced in real time and gaps are filled with silence so the stream never runs dry
+package snapsource
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+)
+
+var (
+	DefaultFormat        = snapcast.SampleFormat{Rate: 48000, Bits: 16, Channels: 2}
+	DefaultChunkDuration = 20 * time.Millisecond
+	DefaultMaxBuffer     = time.Second
+)
+
+var ErrClosed = errors.New("snapsource: closed")
+
+type Options struct {
+	// Sample format snapserver expects, the sampleformat of the stream URI. Defaults to 48000:16:2
+	Format snapcast.SampleFormat
+	// How much audio is written at a time, defaults to 20ms
+	ChunkDuration time.Duration
+	// Audio queued before Write blocks, defaults to 1s
+	MaxBuffer time.Duration
+}
+
+// Source queues PCM from Write and Run hands it to the stream in real time
+type Source struct {
+	w      io.Writer
+	format snapcast.SampleFormat
+	chunk  time.Duration
+	max    int
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	buf       []byte
+	closed    bool
+	underruns int
+}
+
+func New(w io.Writer, opts *Options) *Source {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == (snapcast.SampleFormat{}) {
+		o.Format = DefaultFormat
+	}
+	if o.ChunkDuration <= 0 {
+		o.ChunkDuration = DefaultChunkDuration
+	}
+	if o.MaxBuffer <= 0 {
+		o.MaxBuffer = DefaultMaxBuffer
+	}
+
+	var s = &Source{
+		w:      w,
+		format: o.Format,
+		chunk:  o.ChunkDuration,
+		max:    o.Format.Bytes(o.MaxBuffer),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *Source) Format() snapcast.SampleFormat {
+	return s.format
+}
+
+// Write queues interleaved little endian PCM, blocking while MaxBuffer is queued
+func (s *Source) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for len(p) > 0 {
+		for !s.closed && len(s.buf) >= s.max {
+			s.cond.Wait()
+		}
+		if s.closed {
+			return n, ErrClosed
+		}
+
+		var take = min(len(p), s.max-len(s.buf))
+		s.buf = append(s.buf, p[:take]...)
+		p = p[take:]
+		n += take
+	}
+	return n, nil
+}
+
+// Buffered is how much audio is queued
+func (s *Source) Buffered() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.format.Duration(len(s.buf))
+}
+
+// Underruns counts chunks that were padded with silence, including fully silent ones
+func (s *Source) Underruns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.underruns
+}
+
+// Close stops accepting writes, Run returns once the queue is written
+func (s *Source) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	return nil
+}
+
+// Run writes a chunk every ChunkDuration until ctx is done or the source is closed and drained.
+// Chunks are scheduled from the start time so slow writes don't make it drift
+func (s *Source) Run(ctx context.Context) error {
+	var (
+		size  = s.format.Bytes(s.chunk)
+		chunk = make([]byte, size)
+		start = time.Now()
+		timer = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	for n := 1; ; n++ {
+		done, err := s.next(chunk)
+		if err != nil || done {
+			return err
+		}
+		if _, err := s.w.Write(chunk); err != nil {
+			return err
+		}
+
+		if wait := time.Until(start.Add(time.Duration(n) * s.chunk)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// next fills chunk with whole frames from the queue and silence after them.
+// done is true once closed with nothing left to write
+func (s *Source) next(chunk []byte) (done bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed && len(s.buf) < s.format.FrameSize() {
+		return true, nil
+	}
+
+	var n = min(len(chunk), len(s.buf)/s.format.FrameSize()*s.format.FrameSize())
+	copy(chunk, s.buf[:n])
+	clear(chunk[n:])
+	if n < len(chunk) {
+		s.underruns++
+	}
+
+	s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+	s.cond.Broadcast()
+	return false, nil
+}
@@ -0,0 +1,219 @@
+package snapsource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+var testFormat = snapcast.SampleFormat{Rate: 8000, Bits: 16, Channels: 1}
+
+// recorder keeps every write and when it happened
+type recorder struct {
+	mu     sync.Mutex
+	writes [][]byte
+	times  []time.Time
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes = append(r.writes, append([]byte(nil), p...))
+	r.times = append(r.times, time.Now())
+	return len(p), nil
+}
+
+func TestSource(t *testing.T) {
+	var (
+		rec = &recorder{}
+		src = New(rec, &Options{Format: testFormat, ChunkDuration: 10 * time.Millisecond})
+		// 10ms of mono 16 bit at 8kHz
+		chunk = testFormat.Bytes(10 * time.Millisecond)
+		audio = bytes.Repeat([]byte{1, 0}, chunk/2*3+chunk/4)
+	)
+
+	if _, err := src.Write(audio); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+	if _, err := src.Write(audio); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	var start = time.Now()
+	if err := src.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.writes) != 4 {
+		t.Fatalf("got %d chunks, want 4", len(rec.writes))
+	}
+	for i, w := range rec.writes {
+		if len(w) != chunk {
+			t.Errorf("chunk %d is %d bytes", i, len(w))
+		}
+	}
+	// Paced, the last chunk is written 30ms after the first
+	if elapsed := rec.times[3].Sub(start); elapsed < 25*time.Millisecond {
+		t.Errorf("four chunks in %s", elapsed)
+	}
+
+	// The partial chunk is padded with silence
+	var last = rec.writes[3]
+	if !bytes.Equal(last[:chunk/4*2], audio[3*chunk:]) || !bytes.Equal(last[chunk/2:], make([]byte, chunk/2)) {
+		t.Errorf("last chunk %x", last)
+	}
+	if src.Underruns() != 1 {
+		t.Errorf("underruns = %d", src.Underruns())
+	}
+}
+
+func TestSilence(t *testing.T) {
+	var (
+		rec = &recorder{}
+		src = New(rec, &Options{Format: testFormat, ChunkDuration: 10 * time.Millisecond})
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+
+	if err := src.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run returned %v", err)
+	}
+	if n := len(rec.writes); n < 4 || n > 7 {
+		t.Errorf("%d chunks of silence in 55ms", n)
+	}
+	for _, w := range rec.writes {
+		if !bytes.Equal(w, make([]byte, len(w))) {
+			t.Fatalf("chunk isn't silent %x", w)
+		}
+	}
+	if src.Underruns() != len(rec.writes) {
+		t.Errorf("underruns = %d", src.Underruns())
+	}
+}
+
+func TestTCPServer(t *testing.T) {
+	srv, err := ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// Dropped, nobody is connected
+	if n, err := srv.Write([]byte("lost")); n != 4 || err != nil {
+		t.Errorf("write = %d, %v", n, err)
+	}
+
+	conn, err := DialTCP(context.Background(), srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for !srv.Connected() {
+		time.Sleep(time.Millisecond)
+	}
+
+	srv.Write([]byte("pcm"))
+	var got = make([]byte, 3)
+	if _, err := io.ReadFull(conn, got); err != nil || string(got) != "pcm" {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no fifos")
+	}
+	var path = filepath.Join(t.TempDir(), "snapfifo")
+
+	var opened = make(chan *os.File, 1)
+	go func() {
+		f, err := OpenPipe(path)
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- f
+	}()
+
+	// Stands in for snapserver, wait for the fifo to exist before reading it
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var w = <-opened
+	if w == nil {
+		return
+	}
+	defer w.Close()
+
+	w.Write([]byte{1, 2, 3, 4})
+	var got = make([]byte, 4)
+	if _, err := io.ReadFull(r, got); err != nil || !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Errorf("read %x, %v", got, err)
+	}
+}
+
+func TestStreamURIs(t *testing.T) {
+	for _, tc := range []struct {
+		uri  *snapcast.StreamURI
+		want string
+	}{
+		{PipeURI("/tmp/announce", "Announcements", DefaultFormat), "pipe:///tmp/announce?name=Announcements&sampleformat=48000:16:2"},
+		{TCPURI("0.0.0.0:4953", "Line in", TCPModeServer, DefaultFormat), "tcp://0.0.0.0:4953?name=Line%20in&mode=server&sampleformat=48000:16:2"},
+		{TCPURI("10.0.0.5:4953", "Line in", TCPModeClient, DefaultFormat), "tcp://10.0.0.5:4953?name=Line%20in&mode=client&sampleformat=48000:16:2"},
+	} {
+		if err := tc.uri.Validate(); err != nil {
+			t.Errorf("%s: %v", tc.want, err)
+		}
+		if s := tc.uri.String(); s != tc.want {
+			t.Errorf("uri = %s, want %s", s, tc.want)
+		}
+	}
+}
+
+func TestAddStream(t *testing.T) {
+	var srv = snapcasttest.NewServer(nil)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var c = snapclient.New(&snapclient.Options{Host: srv.Host()})
+	id, err := AddPipeStream(ctx, c, "/tmp/announce", "Announcements", DefaultFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "Announcements" {
+		t.Errorf("stream id = %q", id)
+	}
+
+	var state = srv.State()
+	for _, st := range state.Streams {
+		if st.ID == id {
+			if st.URI.Scheme != "pipe" || st.URI.Path != "/tmp/announce" || st.URI.Query["sampleformat"] != "48000:16:2" {
+				t.Errorf("stream uri = %+v", st.URI)
+			}
+			return
+		}
+	}
+	t.Errorf("stream %q not added", id)
+}
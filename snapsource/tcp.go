@@ -0,0 +1,95 @@
+package snapsource
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// DefaultTCPPort snapserver uses for tcp:// streams
+const DefaultTCPPort = "4953"
+
+// DialTCP connects to a tcp:// stream in mode=server, where snapserver listens for the source
+func DialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultTCPPort)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// TCPServer is the source side of a tcp:// stream in mode=client, where snapserver connects to us.
+// Writes go to the latest connection and are dropped while there is none, so pacing carries on
+type TCPServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	conn net.Conn
+	wg   sync.WaitGroup
+}
+
+func ListenTCP(addr string) (*TCPServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var s = &TCPServer{ln: ln}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *TCPServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *TCPServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.conn = conn
+		s.mu.Unlock()
+	}
+}
+
+// Connected reports if snapserver is connected
+func (s *TCPServer) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+func (s *TCPServer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return len(p), nil
+	}
+	if _, err := s.conn.Write(p); err != nil {
+		// snapserver went away, wait for it to connect again
+		s.conn.Close()
+		s.conn = nil
+	}
+	return len(p), nil
+}
+
+func (s *TCPServer) Close() error {
+	var err = s.ln.Close()
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,393 @@
+// Package snapsse fans snapserver notifications out to any number of Server-Sent Events
+// subscribers over a single Listen connection.
+//
+// Every notification is an event named after its method with the params as data. A subscriber
+// first gets a state event with the whole server, unless it resumes with Last-Event-ID and the
+// events since are still buffered, then they are replayed instead. Another state event follows
+// every resync, e.g. after a reconnect.
+//
+// Query parameters filter the events of a subscriber, repeat them to allow several values:
+//
+//	method   notification method, e.g. Client.OnVolumeChanged
+//	client   client id of Client.* notifications
+//	group    group id of Group.* notifications
+//	stream   stream id of Stream.* notifications and of Group.OnStreamChanged
+//
+// State events and Server.OnUpdate always pass.
+package snapsse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"github.com/ConnorsApps/snapcast-go/snapstate"
+)
+
+const (
+	DefaultBufferSize = 256
+	DefaultQueueSize  = 64
+	DefaultKeepAlive  = 15 * time.Second
+
+	// Name of the events with the whole server
+	EventState = "state"
+)
+
+type Options struct {
+	// Events kept for Last-Event-ID replay, defaults to DefaultBufferSize
+	BufferSize int
+	// Events queued per subscriber, a subscriber falling further behind is disconnected and
+	// catches up by resuming. Defaults to DefaultQueueSize
+	QueueSize int
+	// Interval of comments keeping idle connections open, defaults to DefaultKeepAlive
+	KeepAlive time.Duration
+}
+
+type event struct {
+	seq    uint64
+	name   string
+	data   []byte
+	kind   string // client, group or stream, empty if it always passes filters
+	ids    []string
+	stream string // Group.OnStreamChanged's stream
+}
+
+type subscriber struct {
+	filter filter
+	events chan *event
+}
+
+// Broadcaster is an http.Handler streaming the notifications received while Run is running
+type Broadcaster struct {
+	opts   Options
+	client *snapclient.Client
+	// Kept in step with the events, so a new subscriber's state event and the events after it
+	// line up
+	state *snapstate.State
+	// Part of every event id, a Last-Event-ID of an earlier Broadcaster isn't resumed
+	epoch string
+
+	mu   sync.Mutex
+	seq  uint64
+	ring []*event
+	subs map[*subscriber]struct{}
+}
+
+func New(client *snapclient.Client, opts *Options) *Broadcaster {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	if o.KeepAlive <= 0 {
+		o.KeepAlive = DefaultKeepAlive
+	}
+	return &Broadcaster{
+		opts:   o,
+		client: client,
+		state:  snapstate.New(client),
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:   make([]*event, o.BufferSize),
+		subs:   make(map[*subscriber]struct{}),
+	}
+}
+
+// Run holds the Listen connection and broadcasts until ctx is done or the connection ends.
+// Subscribers are disconnected when it returns
+func (b *Broadcaster) Run(ctx context.Context) error {
+	// Also stops a resync in flight once Run returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var syncer = snapstate.NewSyncer(b.state)
+	var sub = b.client.OnNotification(func(_ context.Context, msg *snapcast.Notification) {
+		if !b.notify(msg) {
+			syncer.Request(ctx)
+		}
+	})
+	defer sub.Unsubscribe()
+
+	var n = &snapclient.Notifications{Connected: make(chan struct{})}
+	closed, err := b.client.Listen(ctx, n)
+	if err != nil {
+		return err
+	}
+	defer b.dropAll()
+
+	syncer.Request(ctx)
+	for {
+		select {
+		case err := <-closed:
+			return err
+		case snap := <-syncer.Snapshots():
+			b.setState(ctx, syncer, snap)
+		case <-n.Connected:
+			syncer.Request(ctx)
+		}
+	}
+}
+
+// notify applies msg to the state and broadcasts it. Returns false if the state needs a resync
+func (b *Broadcaster) notify(msg *snapcast.Notification) bool {
+	if msg.Method == nil {
+		return true
+	}
+	data, err := json.Marshal(msg.Params)
+	if err != nil {
+		return true
+	}
+	var ev = &event{name: string(*msg.Method), data: data}
+
+	var ref struct {
+		ID       string `json:"id"`
+		StreamID string `json:"stream_id"`
+	}
+	json.Unmarshal(data, &ref)
+	switch {
+	case strings.HasPrefix(ev.name, "Client."):
+		ev.kind = "client"
+	case strings.HasPrefix(ev.name, "Group."):
+		ev.kind = "group"
+		ev.stream = ref.StreamID
+	case strings.HasPrefix(ev.name, "Stream."):
+		ev.kind = "stream"
+	}
+	if ev.kind != "" {
+		ev.ids = []string{ref.ID}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	applied, _ := b.state.Apply(msg)
+	b.broadcast(ev)
+	// Like snapstate.Run, a client connecting for the first time isn't in any group yet
+	return applied || *msg.Method != snapcast.MethodClientOnConnect || !b.state.Synced()
+}
+
+// setState applies a snapshot and broadcasts a state event, unless the syncer dropped it
+func (b *Broadcaster) setState(ctx context.Context, syncer *snapstate.Syncer, snap snapstate.Snapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !syncer.Apply(ctx, snap) {
+		return
+	}
+	if ev, ok := b.stateEvent(); ok {
+		b.broadcast(ev)
+	}
+}
+
+// stateEvent with the whole server. Call with mu held
+func (b *Broadcaster) stateEvent() (*event, bool) {
+	var srv = b.state.Server()
+	data, err := json.Marshal(&srv)
+	if err != nil {
+		return nil, false
+	}
+	return &event{seq: b.seq, name: EventState, data: data}, true
+}
+
+// broadcast numbers ev, buffers it and queues it for every subscriber whose filter passes it.
+// Call with mu held
+func (b *Broadcaster) broadcast(ev *event) {
+	b.seq++
+	ev.seq = b.seq
+	b.ring[ev.seq%uint64(len(b.ring))] = ev
+
+	for s := range b.subs {
+		if !s.filter.pass(ev) {
+			continue
+		}
+		select {
+		case s.events <- ev:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// drop disconnects a subscriber. Call with mu held
+func (b *Broadcaster) drop(s *subscriber) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
+
+func (b *Broadcaster) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// since returns the buffered events after seq, false if some of them are gone
+func (b *Broadcaster) since(seq uint64) ([]*event, bool) {
+	if seq > b.seq || b.seq-seq > uint64(len(b.ring)) {
+		return nil, false
+	}
+	var events []*event
+	for i := seq + 1; i <= b.seq; i++ {
+		events = append(events, b.ring[i%uint64(len(b.ring))])
+	}
+	return events, true
+}
+
+func (b *Broadcaster) eventID(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// lastEventID is the seq a subscriber resumes from, false if there is none or it's from
+// another Broadcaster
+func (b *Broadcaster) lastEventID(r *http.Request) (uint64, bool) {
+	var id = r.Header.Get("Last-Event-ID")
+	if id == "" {
+		// EventSource only sends the header when reconnecting on its own
+		id = r.URL.Query().Get("lastEventId")
+	}
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// subscribe registers a subscriber and returns what it gets first: the events since its
+// Last-Event-ID or the current state
+func (b *Broadcaster) subscribe(r *http.Request) (*subscriber, []*event, error) {
+	f, err := parseFilter(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var s = &subscriber{filter: f, events: make(chan *event, b.opts.QueueSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+
+	if seq, ok := b.lastEventID(r); ok {
+		if events, ok := b.since(seq); ok {
+			return s, events, nil
+		}
+	}
+	if !b.state.Synced() {
+		// The state event follows the sync
+		return s, nil, nil
+	}
+	if ev, ok := b.stateEvent(); ok {
+		return s, []*event{ev}, nil
+	}
+	return s, nil, nil
+}
+
+func (b *Broadcaster) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
+
+func (b *Broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, backlog, err := b.subscribe(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer b.unsubscribe(s)
+
+	var rc = http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range backlog {
+		if s.filter.pass(ev) {
+			b.write(w, ev)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	var keepAlive = time.NewTicker(b.opts.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-s.events:
+			if !ok {
+				return
+			}
+			b.write(w, ev)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// write sends an event, JSON data never spans lines
+func (b *Broadcaster) write(w http.ResponseWriter, ev *event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", b.eventID(ev.seq), ev.name, ev.data)
+}
+
+// filter of a subscriber, an empty set lets everything through
+type filter struct {
+	methods map[string]bool
+	ids     map[string]map[string]bool // by kind
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	var (
+		q = r.URL.Query()
+		f = filter{methods: make(map[string]bool), ids: make(map[string]map[string]bool)}
+	)
+	for _, m := range q["method"] {
+		if !strings.Contains(m, ".On") {
+			return f, fmt.Errorf("%q is not a notification method", m)
+		}
+		f.methods[m] = true
+	}
+	for _, kind := range []string{"client", "group", "stream"} {
+		for _, id := range q[kind] {
+			if f.ids[kind] == nil {
+				f.ids[kind] = make(map[string]bool)
+			}
+			f.ids[kind][id] = true
+		}
+	}
+	return f, nil
+}
+
+func (f filter) pass(ev *event) bool {
+	if ev.kind == "" {
+		return true
+	}
+	if len(f.methods) > 0 && !f.methods[ev.name] {
+		return false
+	}
+	if len(f.ids) == 0 {
+		return true
+	}
+	for _, id := range ev.ids {
+		if f.ids[ev.kind][id] {
+			return true
+		}
+	}
+	return ev.stream != "" && f.ids["stream"][ev.stream]
+}
@@ -0,0 +1,341 @@
+package snapsse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+const (
+	livingRoom = "00:21:6a:7d:74:fc"
+	kitchen    = "b8:27:eb:5f:1e:77"
+	firstGroup = "4dcc4e3b-c699-a04b-7f0c-8260d23c43e1"
+)
+
+type sseEvent struct {
+	id, name, data string
+}
+
+// stream reads the events of an SSE connection
+type stream struct {
+	t      *testing.T
+	res    *http.Response
+	events chan sseEvent
+}
+
+func subscribe(t *testing.T, url, lastEventID string) *stream {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	var s = &stream{t: t, res: res, events: make(chan sseEvent, 64)}
+	go func() {
+		defer close(s.events)
+		var (
+			scanner = bufio.NewScanner(res.Body)
+			ev      sseEvent
+		)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			name, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch name {
+			case "id":
+				ev.id = value
+			case "event":
+				ev.name = value
+			case "data":
+				ev.data = value
+			case "":
+				if ev.name != "" {
+					s.events <- ev
+				}
+				ev = sseEvent{}
+			}
+		}
+	}()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *stream) close() { s.res.Body.Close() }
+
+func (s *stream) next() sseEvent {
+	s.t.Helper()
+	select {
+	case ev, ok := <-s.events:
+		if !ok {
+			s.t.Fatal("stream ended")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no event")
+	}
+	return sseEvent{}
+}
+
+func volume(id string, percent int) *snapcast.ClientOnVolumeChanged {
+	return &snapcast.ClientOnVolumeChanged{ID: id, Volume: snapcast.Volume{Percent: percent}}
+}
+
+func setup(t *testing.T, opts *Options) (*snapcasttest.Server, *Broadcaster, string) {
+	var (
+		mock   = snapcasttest.NewServer(nil)
+		client = snapclient.New(&snapclient.Options{Host: mock.Host()})
+		b      = New(client, opts)
+		srv    = httptest.NewServer(b)
+		done   = make(chan error, 1)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		srv.Close()
+		client.Close()
+		mock.Close()
+	})
+
+	// Wait for the first state event, after it notifications reach the broadcaster
+	var deadline = time.Now().Add(5 * time.Second)
+	for !b.state.Synced() {
+		if time.Now().After(deadline) {
+			t.Fatal("never synced")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return mock, b, srv.URL
+}
+
+func TestBroadcast(t *testing.T) {
+	mock, _, url := setup(t, nil)
+
+	var (
+		all         = subscribe(t, url, "")
+		kitchenOnly = subscribe(t, url+"?client="+kitchen+"&method=Client.OnVolumeChanged", "")
+	)
+	for _, s := range []*stream{all, kitchenOnly} {
+		ev := s.next()
+		var srv snapcast.Server
+		if ev.name != EventState || json.Unmarshal([]byte(ev.data), &srv) != nil || len(srv.Groups) != 2 {
+			t.Fatalf("first event %+v", ev)
+		}
+	}
+
+	mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(livingRoom, 10))
+	mock.Notify(snapcast.MethodGroupOnMute, &snapcast.GroupOnMute{ID: firstGroup, Mute: true})
+	mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(kitchen, 20))
+
+	for _, want := range []string{"Client.OnVolumeChanged", "Group.OnMute", "Client.OnVolumeChanged"} {
+		if ev := all.next(); ev.name != want {
+			t.Errorf("got %s, want %s", ev.name, want)
+		}
+	}
+	var p snapcast.ClientOnVolumeChanged
+	ev := kitchenOnly.next()
+	if json.Unmarshal([]byte(ev.data), &p) != nil || p.ID != kitchen || p.Volume.Percent != 20 {
+		t.Errorf("filtered event %+v", ev)
+	}
+}
+
+func TestResume(t *testing.T) {
+	mock, b, url := setup(t, &Options{BufferSize: 4})
+
+	var s = subscribe(t, url, "")
+	var first = s.next()
+	mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(livingRoom, 10))
+	var seen = s.next()
+	s.close()
+
+	mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(livingRoom, 11))
+	mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(livingRoom, 12))
+	// Wait for them to be broadcast
+	var deadline = time.Now().Add(5 * time.Second)
+	for c, _ := b.state.Client(livingRoom); c.Config.Volume.Percent != 12; c, _ = b.state.Client(livingRoom) {
+		if time.Now().After(deadline) {
+			t.Fatal("notifications never arrived")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s = subscribe(t, url, seen.id)
+	for _, want := range []int{11, 12} {
+		var p snapcast.ClientOnVolumeChanged
+		if ev := s.next(); json.Unmarshal([]byte(ev.data), &p) != nil || p.Volume.Percent != want {
+			t.Errorf("replayed %+v, want volume %d", ev, want)
+		}
+	}
+
+	// Too old for the buffer, or from another run: the state instead
+	for i := 0; i < 4; i++ {
+		mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(livingRoom, 20+i))
+	}
+	for i := 0; i < 4; i++ {
+		s.next()
+	}
+	for _, id := range []string{first.id, "x-1"} {
+		if ev := subscribe(t, url, id).next(); ev.name != EventState {
+			t.Errorf("resuming %s got %+v", id, ev)
+		}
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	var b = New(nil, &Options{QueueSize: 1})
+	var s = &subscriber{events: make(chan *event, 1)}
+	b.subs[s] = struct{}{}
+
+	// Nothing reads the queue
+	b.mu.Lock()
+	for i := 0; i < 2; i++ {
+		b.broadcast(&event{name: "Client.OnVolumeChanged", kind: "client", data: []byte("{}")})
+	}
+	b.mu.Unlock()
+
+	if len(b.subs) != 0 {
+		t.Error("subscriber not dropped")
+	}
+	if n := len(s.events); n != 1 {
+		t.Errorf("%d events queued", n)
+	}
+	<-s.events
+	if _, ok := <-s.events; ok {
+		t.Error("queue not closed")
+	}
+}
+
+func TestBadFilter(t *testing.T) {
+	_, _, url := setup(t, nil)
+	res, err := http.Get(url + "?method=volume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d", res.StatusCode)
+	}
+}
+
+// failFirstStatus fails the first Server.GetStatus, like a snapserver still starting up
+type failFirstStatus struct {
+	failed atomic.Bool
+}
+
+func (f *failFirstStatus) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost && !f.failed.Swap(true) {
+		return nil, errors.New("snapserver starting")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestResyncRetry(t *testing.T) {
+	var (
+		mock   = snapcasttest.NewServer(nil)
+		client = snapclient.New(&snapclient.Options{Host: mock.Host(), HTTPClient: &http.Client{Transport: &failFirstStatus{}}})
+		b      = New(client, nil)
+		srv    = httptest.NewServer(b)
+		done   = make(chan error, 1)
+	)
+	defer mock.Close()
+	defer client.Close()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { done <- b.Run(ctx) }()
+
+	// The state event follows the retried sync
+	var s = subscribe(t, srv.URL, "")
+	if ev := s.next(); ev.name != EventState {
+		t.Errorf("first event %+v", ev)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Run ended with %v", err)
+	default:
+	}
+}
+
+// holdStatus holds the first Server.GetStatus back until release is closed
+type holdStatus struct {
+	held    chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (h *holdStatus) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		var first bool
+		h.once.Do(func() { first = true })
+		if first {
+			close(h.held)
+			<-h.release
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSyncUnderTraffic(t *testing.T) {
+	var (
+		mock   = snapcasttest.NewServer(nil)
+		hold   = &holdStatus{held: make(chan struct{}), release: make(chan struct{})}
+		client = snapclient.New(&snapclient.Options{Host: mock.Host(), HTTPClient: &http.Client{Transport: hold}})
+		b      = New(client, nil)
+		srv    = httptest.NewServer(b)
+	)
+	defer mock.Close()
+	defer client.Close()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	var s = subscribe(t, srv.URL, "")
+	<-hold.held
+	// Notifications keep coming while the sync is in flight
+	for percent := 1; percent <= 7; percent++ {
+		mock.Notify(snapcast.MethodClientOnVolumeChanged, volume(kitchen, percent))
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(hold.release)
+
+	// The state event still follows the sync, with the notifications in it
+	for {
+		var ev = s.next()
+		if ev.name != EventState {
+			continue
+		}
+		var srv snapcast.Server
+		if err := json.Unmarshal([]byte(ev.data), &srv); err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range srv.Groups {
+			for _, c := range g.Clients {
+				if c.ID == kitchen && c.Config.Volume.Percent != 7 {
+					t.Errorf("kitchen volume %d in the state event, want 7", c.Config.Volume.Percent)
+				}
+			}
+		}
+		return
+	}
+}
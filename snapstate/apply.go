@@ -0,0 +1,170 @@
+package snapstate
+
+import "github.com/ConnorsApps/snapcast-go/snapcast"
+
+// The Apply functions patch the state with a notification and report whether anything matched
+
+// Returns false for a client that isn't in any group yet, resync to learn its group
+func (s *State) ApplyClientOnConnect(p *snapcast.ClientOnConnect) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		c := findClient(server, p.ID)
+		if c == nil || p.Client == nil {
+			return false
+		}
+		*c = clone(*p.Client)
+		return true
+	})
+}
+
+func (s *State) ApplyClientOnDisconnect(p *snapcast.ClientOnDisconnect) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		c := findClient(server, p.ID)
+		if c == nil {
+			return false
+		}
+		if p.Client != nil {
+			*c = clone(*p.Client)
+		}
+		c.Connected = false
+		return true
+	})
+}
+
+func (s *State) ApplyClientOnVolumeChanged(p *snapcast.ClientOnVolumeChanged) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		c := findClient(server, p.ID)
+		if c == nil {
+			return false
+		}
+		c.Config.Volume = p.Volume
+		return true
+	})
+}
+
+func (s *State) ApplyClientOnLatencyChanged(p *snapcast.ClientOnLatencyChanged) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		c := findClient(server, p.ID)
+		if c == nil {
+			return false
+		}
+		c.Config.Latency = p.Latency
+		return true
+	})
+}
+
+func (s *State) ApplyClientOnNameChanged(p *snapcast.ClientOnNameChanged) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		c := findClient(server, p.ID)
+		if c == nil {
+			return false
+		}
+		c.Config.Name = p.Name
+		return true
+	})
+}
+
+func (s *State) ApplyGroupOnMute(p *snapcast.GroupOnMute) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		g := findGroup(server, p.ID)
+		if g == nil {
+			return false
+		}
+		g.Muted = p.Mute
+		return true
+	})
+}
+
+func (s *State) ApplyGroupOnStreamChanged(p *snapcast.GroupOnStreamChanged) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		g := findGroup(server, p.ID)
+		if g == nil {
+			return false
+		}
+		g.StreamID = p.StreamId
+		return true
+	})
+}
+
+func (s *State) ApplyGroupOnNameChanged(p *snapcast.GroupOnNameChanged) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		g := findGroup(server, p.ID)
+		if g == nil {
+			return false
+		}
+		g.Name = p.Name
+		return true
+	})
+}
+
+// Adds the stream if it is new
+func (s *State) ApplyStreamOnUpdate(p *snapcast.StreamOnUpdate) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		var stream = clone(p.Stream)
+		if stream.ID == "" {
+			stream.ID = p.ID
+		}
+
+		if st := findStream(server, p.ID); st != nil {
+			*st = stream
+			return true
+		}
+		server.Streams = append(server.Streams, stream)
+		return true
+	})
+}
+
+func (s *State) ApplyStreamOnProperties(p *snapcast.StreamOnProperties) bool {
+	return s.update(func(server *snapcast.Server) bool {
+		st := findStream(server, p.ID)
+		if st == nil {
+			return false
+		}
+		var props = clone(p.Properties)
+		st.Properties = &props
+		return true
+	})
+}
+
+// Apply patches the state with a notification of any method, decoding its params.
+// Server.OnUpdate replaces the whole state, unknown methods are ignored
+func (s *State) Apply(msg *snapcast.Notification) (bool, error) {
+	if msg.Method == nil {
+		return false, nil
+	}
+	switch *msg.Method {
+	case snapcast.MethodClientOnConnect:
+		return apply(msg, s.ApplyClientOnConnect)
+	case snapcast.MethodClientOnDisconnect:
+		return apply(msg, s.ApplyClientOnDisconnect)
+	case snapcast.MethodClientOnVolumeChanged:
+		return apply(msg, s.ApplyClientOnVolumeChanged)
+	case snapcast.MethodClientOnLatencyChanged:
+		return apply(msg, s.ApplyClientOnLatencyChanged)
+	case snapcast.MethodClientOnNameChanged:
+		return apply(msg, s.ApplyClientOnNameChanged)
+	case snapcast.MethodGroupOnMute:
+		return apply(msg, s.ApplyGroupOnMute)
+	case snapcast.MethodGroupOnStreamChanged:
+		return apply(msg, s.ApplyGroupOnStreamChanged)
+	case snapcast.MethodGroupOnNameChanged:
+		return apply(msg, s.ApplyGroupOnNameChanged)
+	case snapcast.MethodStreamOnUpdate:
+		return apply(msg, s.ApplyStreamOnUpdate)
+	case snapcast.MethodStreamOnProperties:
+		return apply(msg, s.ApplyStreamOnProperties)
+	case snapcast.MethodServerOnUpdate:
+		return apply(msg, func(p *snapcast.ServerOnUpdate) bool {
+			s.Set(&p.Server)
+			return true
+		})
+	}
+	return false, nil
+}
+
+func apply[T any](msg *snapcast.Notification, fn func(p *T) bool) (bool, error) {
+	p, err := snapcast.ParseResult[T](msg.Params)
+	if err != nil {
+		return false, err
+	}
+	return fn(p), nil
+}
@@ -0,0 +1,324 @@
+// Package snapstate keeps an in-memory copy of the snapserver state in sync using notifications
+package snapstate
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+type State struct {
+	client *snapclient.Client
+
+	mu      sync.RWMutex
+	server  snapcast.Server
+	synced  bool
+	changed chan struct{}
+	// Updates applied since a Syncer requested a snapshot, replayed onto it, see record
+	journal []func(server *snapcast.Server) bool
+	// The request journal belongs to, 0 while not recording
+	recording uint64
+	requests  uint64
+}
+
+func New(client *snapclient.Client) *State {
+	return &State{
+		client:  client,
+		changed: make(chan struct{}),
+	}
+}
+
+// Run listens for notifications, bootstraps from Server.GetStatus and resyncs after every reconnect,
+// retrying failed resyncs. It blocks until ctx is done or the connection ends, see Synced.
+func (s *State) Run(ctx context.Context) error {
+	var n = &snapclient.Notifications{
+		Connected:              make(chan struct{}),
+		Disconnected:           make(chan error),
+		ClientOnConnect:        make(chan *snapcast.ClientOnConnect),
+		ClientOnDisconnect:     make(chan *snapcast.ClientOnDisconnect),
+		ClientOnVolumeChanged:  make(chan *snapcast.ClientOnVolumeChanged),
+		ClientOnLatencyChanged: make(chan *snapcast.ClientOnLatencyChanged),
+		ClientOnNameChanged:    make(chan *snapcast.ClientOnNameChanged),
+		GroupOnMute:            make(chan *snapcast.GroupOnMute),
+		GroupOnStreamChanged:   make(chan *snapcast.GroupOnStreamChanged),
+		GroupOnNameChanged:     make(chan *snapcast.GroupOnNameChanged),
+		StreamOnUpdate:         make(chan *snapcast.StreamOnUpdate),
+		StreamOnProperties:     make(chan *snapcast.StreamOnProperties),
+		ServerOnUpdate:         make(chan *snapcast.ServerOnUpdate),
+	}
+
+	// Also stops a resync in flight once Run returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.unsync()
+
+	closed, err := s.client.Listen(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	var syncer = NewSyncer(s)
+	syncer.Request(ctx)
+
+	for {
+		select {
+		case err := <-closed:
+			return err
+		case snap := <-syncer.Snapshots():
+			syncer.Apply(ctx, snap)
+		case <-n.Disconnected:
+			s.unsync()
+		case <-n.Connected:
+			syncer.Request(ctx)
+
+		case p := <-n.ClientOnConnect:
+			if !s.ApplyClientOnConnect(p) {
+				syncer.Request(ctx)
+			}
+		case p := <-n.ClientOnDisconnect:
+			s.ApplyClientOnDisconnect(p)
+		case p := <-n.ClientOnVolumeChanged:
+			s.ApplyClientOnVolumeChanged(p)
+		case p := <-n.ClientOnLatencyChanged:
+			s.ApplyClientOnLatencyChanged(p)
+		case p := <-n.ClientOnNameChanged:
+			s.ApplyClientOnNameChanged(p)
+
+		case p := <-n.GroupOnMute:
+			s.ApplyGroupOnMute(p)
+		case p := <-n.GroupOnStreamChanged:
+			s.ApplyGroupOnStreamChanged(p)
+		case p := <-n.GroupOnNameChanged:
+			s.ApplyGroupOnNameChanged(p)
+
+		case p := <-n.StreamOnUpdate:
+			s.ApplyStreamOnUpdate(p)
+		case p := <-n.StreamOnProperties:
+			s.ApplyStreamOnProperties(p)
+
+		case p := <-n.ServerOnUpdate:
+			s.Set(&p.Server)
+		}
+	}
+}
+
+// Changed returns a channel that is closed on the next change of the state
+func (s *State) Changed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changed
+}
+
+// Synced reports whether the state was bootstrapped from the server and no notification was missed since,
+// it turns false while Run is disconnected or after it returned
+func (s *State) Synced() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.synced
+}
+
+// Set replaces the whole state, e.g. from Server.GetStatus
+func (s *State) Set(srv *snapcast.Server) {
+	s.update(func(server *snapcast.Server) bool {
+		*server = clone(*srv)
+		s.synced = true
+		return true
+	})
+}
+
+// unsync marks the state as possibly behind the server until the next resync.
+// A snapshot requested before may have missed notifications too, it is dropped
+func (s *State) unsync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recording, s.journal = 0, nil
+	if s.synced {
+		s.synced = false
+		s.signal()
+	}
+}
+
+// update runs fn under the write lock and signals a change if it returns true.
+// Either way fn is kept while a snapshot is requested, to be replayed onto it
+func (s *State) update(fn func(server *snapcast.Server) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.recording != 0 {
+		s.journal = append(s.journal, fn)
+	}
+	if !fn(&s.server) {
+		return false
+	}
+	s.signal()
+	return true
+}
+
+// signal a change, call with mu held
+func (s *State) signal() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// record starts keeping updates for a snapshot about to be requested, dropping those kept for
+// an earlier request. Returns the request to pass to setSnapshot
+func (s *State) record() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	s.recording, s.journal = s.requests, nil
+	return s.recording
+}
+
+// setSnapshot is Set with the updates applied since snap was requested replayed on top,
+// unless the updates of its request weren't kept to the end
+func (s *State) setSnapshot(snap Snapshot) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap.request == 0 || snap.request != s.recording {
+		return false
+	}
+	var server = clone(*snap.Server)
+	for _, fn := range s.journal {
+		fn(&server)
+	}
+	s.server = server
+	s.synced = true
+	s.recording, s.journal = 0, nil
+	s.signal()
+	return true
+}
+
+// Server returns a copy of the whole state
+func (s *State) Server() snapcast.Server {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return clone(s.server)
+}
+
+func (s *State) Client(id string) (snapcast.Client, bool) {
+	return s.findClient(func(c *snapcast.Client) bool { return c.ID == id })
+}
+
+// ClientByName matches the configured name, falling back to the host name like snapweb does
+func (s *State) ClientByName(name string) (snapcast.Client, bool) {
+	if c, ok := s.findClient(func(c *snapcast.Client) bool { return c.Config.Name == name }); ok {
+		return c, true
+	}
+	return s.findClient(func(c *snapcast.Client) bool { return c.Config.Name == "" && c.Host.Name == name })
+}
+
+func (s *State) ClientByMAC(mac string) (snapcast.Client, bool) {
+	return s.findClient(func(c *snapcast.Client) bool { return strings.EqualFold(c.Host.MAC, mac) })
+}
+
+func (s *State) Clients() []snapcast.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []snapcast.Client
+	for _, g := range s.server.Groups {
+		for _, c := range g.Clients {
+			clients = append(clients, clone(c))
+		}
+	}
+	return clients
+}
+
+func (s *State) Group(id string) (snapcast.Group, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if g := findGroup(&s.server, id); g != nil {
+		return clone(*g), true
+	}
+	return snapcast.Group{}, false
+}
+
+// GroupOfClient returns the group containing the client
+func (s *State) GroupOfClient(clientID string) (snapcast.Group, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, g := range s.server.Groups {
+		for _, c := range g.Clients {
+			if c.ID == clientID {
+				return clone(g), true
+			}
+		}
+	}
+	return snapcast.Group{}, false
+}
+
+func (s *State) Stream(id string) (snapcast.Stream, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if st := findStream(&s.server, id); st != nil {
+		return clone(*st), true
+	}
+	return snapcast.Stream{}, false
+}
+
+func (s *State) findClient(match func(c *snapcast.Client) bool) (snapcast.Client, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for gi := range s.server.Groups {
+		for ci := range s.server.Groups[gi].Clients {
+			if c := &s.server.Groups[gi].Clients[ci]; match(c) {
+				return clone(*c), true
+			}
+		}
+	}
+	return snapcast.Client{}, false
+}
+
+func findClient(server *snapcast.Server, id string) *snapcast.Client {
+	for gi := range server.Groups {
+		for ci := range server.Groups[gi].Clients {
+			if c := &server.Groups[gi].Clients[ci]; c.ID == id {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func findGroup(server *snapcast.Server, id string) *snapcast.Group {
+	for gi := range server.Groups {
+		if server.Groups[gi].ID == id {
+			return &server.Groups[gi]
+		}
+	}
+	return nil
+}
+
+func findStream(server *snapcast.Server, id string) *snapcast.Stream {
+	for si := range server.Streams {
+		if server.Streams[si].ID == id {
+			return &server.Streams[si]
+		}
+	}
+	return nil
+}
+
+// clone deep copies through JSON so callers never share slices or pointers with the state
+func clone[T any](v T) T {
+	var out T
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package snapstate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapcasttest"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+	"golang.org/x/time/rate"
+)
+
+const kitchen = "b8:27:eb:5f:1e:77"
+
+// statusTransport intercepts the Server.GetStatus requests of a client
+type statusTransport struct {
+	intercept func(n int32, res *http.Response, err error) (*http.Response, error)
+	calls     atomic.Int32
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if !bytes.Contains(body, []byte(snapcast.MethodServerGetStatus)) {
+		return res, err
+	}
+	return t.intercept(t.calls.Add(1), res, err)
+}
+
+func runState(t *testing.T, srv *snapcasttest.Server, rt *statusTransport) *State {
+	var (
+		client = snapclient.New(&snapclient.Options{
+			Host:        srv.Host(),
+			HTTPClient:  &http.Client{Transport: rt},
+			RateLimiter: rate.NewLimiter(rate.Inf, 0),
+		})
+		s    = New(client)
+		done = make(chan error, 1)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		client.Close()
+	})
+	return s
+}
+
+func waitSynced(t *testing.T, s *State) {
+	var timeout = time.After(5 * time.Second)
+	for {
+		var changed = s.Changed()
+		if s.Synced() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatal("never synced")
+		}
+	}
+}
+
+func testServer() *snapcast.Server {
+	var srv = &snapcast.Server{
+		Groups: []snapcast.Group{
+			{ID: "g1", StreamID: "s1", Clients: []snapcast.Client{{ID: "c1"}, {ID: "c2"}}},
+			{ID: "g2", StreamID: "s1", Clients: []snapcast.Client{{ID: "c3"}}},
+		},
+		Streams: []snapcast.Stream{{ID: "s1", Status: snapcast.StreamIdle}},
+	}
+	srv.Groups[0].Clients[1].Config.Name = "Kitchen"
+	srv.Groups[1].Clients[0].Host.MAC = "00:11:22:33:44:55"
+	return srv
+}
+
+func TestApply(t *testing.T) {
+	var s = New(nil)
+	s.Set(testServer())
+
+	var changed = s.Changed()
+	if !s.ApplyClientOnVolumeChanged(&snapcast.ClientOnVolumeChanged{ID: "c2", Volume: snapcast.Volume{Percent: 42}}) {
+		t.Fatal("volume change not applied")
+	}
+	select {
+	case <-changed:
+	default:
+		t.Error("Changed not signalled")
+	}
+
+	if c, ok := s.ClientByName("Kitchen"); !ok || c.Config.Volume.Percent != 42 {
+		t.Errorf("ClientByName = %+v, %v", c, ok)
+	}
+
+	s.ApplyGroupOnMute(&snapcast.GroupOnMute{ID: "g2", Mute: true})
+	if g, ok := s.GroupOfClient("c3"); !ok || g.ID != "g2" || !g.Muted {
+		t.Errorf("GroupOfClient = %+v, %v", g, ok)
+	}
+
+	if c, ok := s.ClientByMAC("00:11:22:33:44:55"); !ok || c.ID != "c3" {
+		t.Errorf("ClientByMAC = %+v, %v", c, ok)
+	}
+
+	s.ApplyStreamOnProperties(&snapcast.StreamOnProperties{ID: "s1", Properties: snapcast.Properties{CanSeek: true}})
+	s.ApplyStreamOnUpdate(&snapcast.StreamOnUpdate{ID: "s2", Stream: snapcast.Stream{ID: "s2", Status: snapcast.StreamPlaying}})
+	if st, ok := s.Stream("s1"); !ok || st.Properties == nil || !st.Properties.CanSeek {
+		t.Errorf("Stream(s1) = %+v, %v", st, ok)
+	}
+	if st, ok := s.Stream("s2"); !ok || !st.Status.IsPlaying() {
+		t.Errorf("Stream(s2) = %+v, %v", st, ok)
+	}
+
+	if s.ApplyClientOnConnect(&snapcast.ClientOnConnect{ID: "new", Client: &snapcast.Client{ID: "new"}}) {
+		t.Error("unknown client should ask for a resync")
+	}
+
+	// Copies must not alias the state
+	var srv = s.Server()
+	srv.Groups[0].Clients[0].Config.Name = "changed"
+	if c, _ := s.Client("c1"); c.Config.Name != "" {
+		t.Error("Server() returned an aliased copy")
+	}
+}
+
+func TestApplyNotification(t *testing.T) {
+	var s = New(nil)
+	s.Set(testServer())
+
+	var method = snapcast.MethodGroupOnNameChanged
+	ok, err := s.Apply(&snapcast.Notification{Method: &method, Params: map[string]interface{}{"id": "g2", "name": "Upstairs"}})
+	if err != nil || !ok {
+		t.Fatalf("Apply = %v, %v", ok, err)
+	}
+	if g, _ := s.Group("g2"); g.Name != "Upstairs" {
+		t.Errorf("group name %q", g.Name)
+	}
+
+	method = snapcast.MethodServerOnUpdate
+	if ok, _ := s.Apply(&snapcast.Notification{Method: &method, Params: map[string]interface{}{"server": map[string]interface{}{}}}); !ok || len(s.Server().Groups) != 0 {
+		t.Error("Server.OnUpdate didn't replace the state")
+	}
+
+	method = snapcast.MethodClientOnVolumeChanged
+	if _, err := s.Apply(&snapcast.Notification{Method: &method, Params: "garbage"}); err == nil {
+		t.Error("bad params didn't fail")
+	}
+}
+
+func TestRunRetriesResync(t *testing.T) {
+	resyncRetry = &snapclient.ReconnectPolicy{InitialDelay: time.Millisecond}
+	defer func() { resyncRetry = &snapclient.ReconnectPolicy{} }()
+
+	var (
+		srv = snapcasttest.NewServer(nil)
+		rt  = &statusTransport{intercept: func(n int32, res *http.Response, err error) (*http.Response, error) {
+			if n < 3 {
+				return nil, errors.New("snapserver restarting")
+			}
+			return res, err
+		}}
+		s = runState(t, srv, rt)
+	)
+	defer srv.Close()
+
+	waitSynced(t, s)
+	if n := rt.calls.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
+
+func TestRunReplaysOnSnapshot(t *testing.T) {
+	var (
+		srv     = snapcasttest.NewServer(nil)
+		held    = make(chan struct{})
+		release = make(chan struct{})
+		rt      = &statusTransport{intercept: func(n int32, res *http.Response, err error) (*http.Response, error) {
+			// The first answer is held back until after changes it doesn't include
+			if n == 1 {
+				close(held)
+				<-release
+			}
+			return res, err
+		}}
+		s     = runState(t, srv, rt)
+		other = snapclient.New(&snapclient.Options{Host: srv.Host(), RateLimiter: rate.NewLimiter(rate.Inf, 0)})
+		ctx   = context.Background()
+	)
+	defer srv.Close()
+
+	<-held
+	// Steady traffic while the request is in flight
+	for percent := 1; percent <= 7; percent++ {
+		if _, err := other.ClientSetVolume(ctx, kitchen, snapcast.Volume{Percent: percent}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let Run receive the notifications before the old snapshot
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	waitSynced(t, s)
+	if c, _ := s.Client(kitchen); c.Config.Volume.Percent != 7 {
+		t.Errorf("volume %d, the old snapshot overwrote the notifications", c.Config.Volume.Percent)
+	}
+	if n := rt.calls.Load(); n != 1 {
+		t.Errorf("%d requests, want the snapshot kept with the notifications replayed", n)
+	}
+}
@@ -0,0 +1,100 @@
+package snapstate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ConnorsApps/snapcast-go/snapcast"
+	"github.com/ConnorsApps/snapcast-go/snapclient"
+)
+
+// Backoff between failed Server.GetStatus requests of a Syncer
+var resyncRetry = &snapclient.ReconnectPolicy{}
+
+// Snapshot is a Server.GetStatus result fetched by a Syncer, see Syncer.Apply
+type Snapshot struct {
+	Server *snapcast.Server
+	// See State.record
+	request uint64
+}
+
+// Syncer fetches Server.GetStatus for a State in the background, so a loop applying
+// notifications keeps draining them while a request is in flight. Failed requests are retried
+// with backoff until ctx is done
+type Syncer struct {
+	state     *State
+	snapshots chan Snapshot
+
+	mu       sync.Mutex
+	fetching bool
+	// Requested again while fetching, the snapshot in flight may predate the request
+	stale bool
+}
+
+func NewSyncer(state *State) *Syncer {
+	return &Syncer{state: state, snapshots: make(chan Snapshot)}
+}
+
+// Snapshots receives the results of Request, pass them to Apply
+func (y *Syncer) Snapshots() <-chan Snapshot {
+	return y.snapshots
+}
+
+// Request a snapshot, at most one request is in flight at a time
+func (y *Syncer) Request(ctx context.Context) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.fetching {
+		y.stale = true
+		return
+	}
+	y.fetching = true
+	go y.fetch(ctx)
+}
+
+func (y *Syncer) fetch(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		var request = y.state.record()
+		res, err := y.state.client.ServerGetStatus(ctx)
+		if err != nil {
+			select {
+			case <-time.After(resyncRetry.Delay(attempt)):
+				continue
+			case <-ctx.Done():
+				y.mu.Lock()
+				y.fetching = false
+				y.mu.Unlock()
+				return
+			}
+		}
+
+		y.mu.Lock()
+		if y.stale {
+			y.stale = false
+			y.mu.Unlock()
+			attempt = -1
+			continue
+		}
+		// Before sending, so a stale snapshot can be requested again right away
+		y.fetching = false
+		y.mu.Unlock()
+
+		select {
+		case y.snapshots <- Snapshot{Server: &res.Server, request: request}:
+		case <-ctx.Done():
+		}
+		return
+	}
+}
+
+// Apply sets the state to snap and replays the notifications applied since it was requested on top,
+// as the snapshot may be older than them. A snapshot that can't be trusted, e.g. requested before
+// a disconnect or superseded by a later request, is dropped, another one is requested and Apply returns false
+func (y *Syncer) Apply(ctx context.Context, snap Snapshot) bool {
+	if y.state.setSnapshot(snap) {
+		return true
+	}
+	y.Request(ctx)
+	return false
+}